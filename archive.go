@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"finalwork/internal/archive"
+	"finalwork/internal/config"
+	"finalwork/internal/countries"
+	"finalwork/internal/fetch"
+	"finalwork/internal/incident"
+	"finalwork/internal/input"
+	"finalwork/internal/providers"
+	"finalwork/internal/rules"
+	"finalwork/internal/voicecall"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type inputs struct { // откуда берутся данные систем при одном сборе
+	files input.Files  // файлы SMS, Voice, Email и Billing
+	api   fetch.Source // API MMS, Support и Incident
+}
+
+type archivedConfig struct { // настройки, от которых зависит результат сбора. Учетные данные (заголовки, ключи) не записываются
+	Sources        config.Sources          `json:"sources"` // имена файлов и адреса - ключи записанных данных
+	Providers      []providers.Provider    `json:"providers"`
+	VoiceQuality   voicecall.QualityConfig `json:"voice_quality"`
+	Incidents      incident.Config         `json:"incidents"`
+	StatusRules    []rules.Rule            `json:"status_rules"`
+	MaxBodySize    int64                   `json:"max_body_size"`
+	ContentTypes   []string                `json:"content_types"`
+	StrictDecoding bool                    `json:"strict_decoding"`
+}
+
+func newArchivedConfig(c config.Config) archivedConfig {
+	return archivedConfig{
+		Sources:        c.Sources,
+		Providers:      c.Providers,
+		VoiceQuality:   c.VoiceQuality,
+		Incidents:      c.Incidents,
+		StatusRules:    c.StatusRules,
+		MaxBodySize:    c.Upstream.MaxBodySize,
+		ContentTypes:   c.Upstream.ContentTypes,
+		StrictDecoding: c.Upstream.StrictDecoding,
+	}
+}
+
+func (ac archivedConfig) apply(c *config.Config) { // переносит записанные настройки поверх действующих
+	c.Sources = ac.Sources
+	c.Providers = ac.Providers
+	c.VoiceQuality = ac.VoiceQuality
+	c.Incidents = ac.Incidents
+	c.StatusRules = ac.StatusRules
+	c.Upstream.MaxBodySize = ac.MaxBodySize
+	c.Upstream.ContentTypes = ac.ContentTypes
+	c.Upstream.StrictDecoding = ac.StrictDecoding
+}
+
+func saveArchive(rec *archive.Recorder, loc countries.Locale, rT ResultT) { // функция сохранения входных данных, настроек и результата сбора
+	a, err := rec.Archive(loc.Tag.String(), rT)
+	if err == nil {
+		a.Countries = countryRepo.Data()
+		a.Config, err = json.Marshal(newArchivedConfig(cfg))
+	}
+	if err == nil {
+		var name string
+		if name, err = archive.Save(cfg.Archive.Dir, a, cfg.Archive.Keep); err == nil {
+			fmt.Println("Archive saved:", name)
+			return
+		}
+	}
+	fmt.Printf("Error saving archive: %v\n", err)
+}
+
+// initReplay - функция перехода в режим воспроизведения: данные систем, список стран и настройки обработки берутся из архива
+func initReplay(fileName string) error {
+	a, err := archive.Load(fileName)
+	if err != nil {
+		return err
+	}
+	if len(a.Config) > 0 {
+		var ac archivedConfig
+		if err := json.Unmarshal(a.Config, &ac); err != nil {
+			return fmt.Errorf("archive: %s: config: %w", fileName, err)
+		}
+		c := cfg
+		ac.apply(&c)
+		if err := useConfig(c); err != nil {
+			return fmt.Errorf("archive: %s: config: %w", fileName, err)
+		}
+	} else {
+		fmt.Println("Replay: archive has no config, using the current one")
+	}
+	if len(a.Countries) > 0 {
+		repo, err := countries.RepositoryFromData(a.Countries)
+		if err != nil {
+			return fmt.Errorf("archive: %s: %w", fileName, err)
+		}
+		useCountryRepository(repo)
+	}
+	replayArchive = a
+	dataFiles = a
+	apiSource.Client = a
+	fmt.Printf("Replay mode: %s (collected at %s, locale %s)\n", fileName, a.Time.Format("2006-01-02 15:04:05"), a.Locale)
+	return nil
+}
+
+func checkReplay() error { // функция сбора по архиву на языке записи и сравнения с записанным результатом
+	return compareReplay(getResultT(countries.MatchLocale(replayArchive.Locale, "")))
+}
+
+func compareReplay(rT ResultT) error { // функция сравнения результата воспроизведения с записанным в архиве
+	data, err := json.Marshal(rT)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(data, replayArchive.Result) {
+		return nil
+	}
+	fields := diffFields(data, replayArchive.Result)
+	if len(fields) == 0 { // одинаковые поля в другом порядке или другом форматировании
+		return nil
+	}
+	return errors.New("result differs from the archive in " + strings.Join(fields, ", "))
+}
+
+func diffFields(a, b []byte) []string { // поля ResultT (и его data) с разными значениями
+	var top [2]map[string]json.RawMessage
+	var data [2]map[string]json.RawMessage
+	for i, raw := range [][]byte{a, b} {
+		if json.Unmarshal(raw, &top[i]) != nil {
+			return []string{"result"}
+		}
+		json.Unmarshal(top[i]["data"], &data[i]) // data может быть null
+	}
+	var fields []string
+	for _, key := range unionKeys(top[0], top[1]) {
+		if key != "data" && !bytes.Equal(top[0][key], top[1][key]) {
+			fields = append(fields, key)
+		}
+	}
+	for _, key := range unionKeys(data[0], data[1]) {
+		if !jsonEqual(data[0][key], data[1][key]) {
+			fields = append(fields, "data."+key)
+		}
+	}
+	return fields
+}
+
+func unionKeys(a, b map[string]json.RawMessage) []string {
+	seen := make(map[string]struct{})
+	var keys []string
+	for _, m := range []map[string]json.RawMessage{a, b} {
+		for key := range m {
+			if _, ok := seen[key]; !ok {
+				seen[key] = struct{}{}
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func jsonEqual(a, b json.RawMessage) bool { // сравнение без учета пробелов
+	var ca, cb bytes.Buffer
+	if json.Compact(&ca, a) != nil || json.Compact(&cb, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
+}
@@ -0,0 +1,1113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"finalwork/internal/auth"
+	"finalwork/internal/countries"
+	"finalwork/internal/rules"
+	"finalwork/simulator/skillbox-diploma/simulator"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Сквозные тесты: симулятор работает в том же процессе (обработчики через httptest, файлы данных во временной директории),
+// сервис настраивается на эти источники, проверяется собранная структура ResultT.
+
+type harness struct {
+	t   *testing.T
+	sim *httptest.Server
+	dir string // файлы данных симулятора и состояние сервиса
+}
+
+// newHarness генерирует данные симулятора с фиксированным seed (simArgs - флаги симулятора) и направляет на них сервис
+func newHarness(t *testing.T, simArgs ...string) *harness {
+	t.Helper()
+	dir := t.TempDir()
+	if err := simulator.Setup(append([]string{"-seed", "1"}, simArgs...), dir); err != nil {
+		t.Fatalf("simulator setup: %v", err)
+	}
+	sim := httptest.NewServer(simulator.Handler())
+	t.Cleanup(sim.Close)
+
+	config := map[string]interface{}{
+		"sources": map[string]string{
+			"sms_file":     filepath.Join(dir, "sms.data"),
+			"voice_file":   filepath.Join(dir, "voice.data"),
+			"email_file":   filepath.Join(dir, "email.data"),
+			"billing_file": filepath.Join(dir, "billing.data"),
+			"mms_url":      sim.URL + "/mms",
+			"support_url":  sim.URL + "/support",
+			"incident_url": sim.URL + "/accendent",
+		},
+		"history":     map[string]string{"dir": filepath.Join(dir, "history"), "interval": "0s"},
+		"maintenance": map[string]string{"file": filepath.Join(dir, "maintenance.json")},
+		"upstream":    map[string]interface{}{"retries": 0, "read_timeout": "500ms", "breaker_failures": 0},
+	}
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configFile := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(configFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := initConfig(configFile); err != nil {
+		t.Fatalf("service config: %v", err)
+	}
+	if err := initCountryRepositories(""); err != nil {
+		t.Fatalf("service countries: %v", err)
+	}
+	return &harness{t: t, sim: sim, dir: dir}
+}
+
+// control выполняет запрос к управляющему API симулятора (данные, сбои)
+func (h *harness) control(method, path string, body interface{}) {
+	h.t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	req, err := http.NewRequest(method, h.sim.URL+path, bytes.NewReader(data))
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		h.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		h.t.Fatalf("%s %s: status %d", method, path, resp.StatusCode)
+	}
+}
+
+// configure дополняет файл настроек сервиса разделом section и перечитывает настройки
+func (h *harness) configure(section string, value interface{}) {
+	h.t.Helper()
+	configFile := filepath.Join(h.dir, "config.json")
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	config := make(map[string]interface{})
+	if err := json.Unmarshal(data, &config); err != nil {
+		h.t.Fatal(err)
+	}
+	config[section] = value
+	if data, err = json.Marshal(config); err != nil {
+		h.t.Fatal(err)
+	}
+	if err := os.WriteFile(configFile, data, 0644); err != nil {
+		h.t.Fatal(err)
+	}
+	if err := initConfig(configFile); err != nil {
+		h.t.Fatalf("service config: %v", err)
+	}
+}
+
+func (h *harness) collect() ResultT {
+	return getResultT(countries.DefaultLocale)
+}
+
+const testAPIKey = "e2e-internal-key"
+
+// serve выполняет запрос к обработчикам сервиса от имени клиента со scope internal
+func (h *harness) serve(method, target string, body interface{}) *httptest.ResponseRecorder {
+	h.t.Helper()
+	a, err := auth.New(auth.Config{APIKeys: []auth.APIKey{{Name: "test", Key: testAPIKey, Scopes: []string{auth.ScopeInternal}}}}, nil)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			h.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, target, reader)
+	req.Header.Set("X-API-Key", testAPIKey)
+	rec := httptest.NewRecorder()
+	a.Middleware(newRouter()).ServeHTTP(rec, req)
+	return rec
+}
+
+// get запрашивает /systemsstatus
+func (h *harness) get(query string) (int, ResultT) {
+	h.t.Helper()
+	rec := h.serve(http.MethodGet, "/systemsstatus?"+query, nil)
+	var rT ResultT
+	if err := json.Unmarshal(rec.Body.Bytes(), &rT); err != nil {
+		h.t.Fatalf("response %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, rT
+}
+
+var exactSMS = []simulator.SMSRow{
+	{Country: "RU", Provider: "Topolo", Bandwidth: 10, ResponseTime: 100},
+	{Country: "US", Provider: "Rond", Bandwidth: 20, ResponseTime: 200},
+	{Country: "GB", Provider: "Kildy", Bandwidth: 30, ResponseTime: 300},
+}
+
+var exactEmail = []simulator.EmailRow{
+	{Country: "RU", Provider: "Gmail", DeliveryTime: 40},
+	{Country: "RU", Provider: "Yahoo", DeliveryTime: 10},
+	{Country: "RU", Provider: "MSN", DeliveryTime: 30},
+	{Country: "RU", Provider: "AOL", DeliveryTime: 20},
+	{Country: "US", Provider: "Gmail", DeliveryTime: 5},
+}
+
+func TestEndToEndExactData(t *testing.T) {
+	h := newHarness(t)
+	h.control(http.MethodPut, "/data/sms", exactSMS)
+	h.control(http.MethodPut, "/data/mms", exactSMS)
+	h.control(http.MethodPut, "/data/email", exactEmail)
+	h.control(http.MethodPut, "/data/billing", "111110")
+	h.control(http.MethodPut, "/data/support", []simulator.SupportItem{{Topic: "SMS", ActiveTickets: 5}, {Topic: "MMS", ActiveTickets: 7}})
+	h.control(http.MethodPut, "/data/accendent", []simulator.AccendentItem{{Topic: "B", Status: "closed"}, {Topic: "A", Status: "active"}})
+
+	rT := h.collect()
+	if !rT.Status || rT.Error != "" {
+		t.Fatalf("status %v, error %q", rT.Status, rT.Error)
+	}
+	for name, got := range map[string][][]string{
+		"sms": {smsColumn(rT.Data.SMS[0], "provider"), smsColumn(rT.Data.SMS[1], "country")},
+		"mms": {mmsColumn(rT.Data.MMS[0], "provider"), mmsColumn(rT.Data.MMS[1], "country")},
+	} {
+		if want := []string{"Kildy", "Rond", "Topolo"}; !equalStrings(got[0], want) {
+			t.Errorf("%s by provider: got %v, want %v", name, got[0], want)
+		}
+		if want := []string{"Russia", "United Kingdom", "United States"}; !equalStrings(got[1], want) {
+			t.Errorf("%s by country: got %v, want %v", name, got[1], want)
+		}
+	}
+	ru := rT.Data.Email["RU"]
+	if len(ru) != 2 || !equalStrings(emailProviders(ru[0]), []string{"Yahoo", "AOL", "MSN"}) || !equalStrings(emailProviders(ru[1]), []string{"AOL", "MSN", "Gmail"}) {
+		t.Errorf("email RU: got %+v, want fastest Yahoo, AOL, MSN and slowest AOL, MSN, Gmail", ru)
+	}
+	if us := rT.Data.Email["US"]; len(us) != 2 || len(us[0]) != 1 || len(us[1]) != 1 {
+		t.Errorf("email US with one provider: got %+v", us)
+	}
+	want := BillingData{CreateCustomer: false, Purchase: true, Payout: true, Recurring: true, FraudControl: true, CheckoutPage: true}
+	if rT.Data.Billing != want {
+		t.Errorf("billing: got %+v, want %+v", rT.Data.Billing, want)
+	}
+	if !equalInts(rT.Data.Support, []int{2, 36}) {
+		t.Errorf("support: got %v, want [2 36]", rT.Data.Support)
+	}
+	if len(rT.Data.Incidents) != 2 || rT.Data.Incidents[0].Status != "active" {
+		t.Errorf("incidents: got %+v, want the active one first", rT.Data.Incidents)
+	}
+}
+
+func TestEndToEndDefaultAuth(t *testing.T) { // настройки по умолчанию: main.js получает полную структуру без учетных данных
+	h := newHarness(t)
+	h.control(http.MethodPut, "/data/sms", exactSMS)
+	handler := newHandler()
+	request := func(target, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := request("/systemsstatus", "")
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &raw); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("anonymous /systemsstatus: %d %q", rec.Code, rec.Body.String())
+	}
+	for _, key := range []string{"status", "data", "error"} { // checkJsonScheme в main.js
+		if _, ok := raw[key]; !ok {
+			t.Errorf("response has no %q", key)
+		}
+	}
+	var rT ResultT
+	if err := json.Unmarshal(rec.Body.Bytes(), &rT); err != nil {
+		t.Fatal(err)
+	}
+	wantOK(t, rT)
+	if len(rT.Data.SMS) != 2 || len(rT.Data.SMS[0]) != len(exactSMS) || len(rT.Data.VoiceCall) == 0 || len(rT.Data.Email) == 0 {
+		t.Errorf("anonymous client got no full ResultSetT: %s", rec.Body.String())
+	}
+
+	if rec := request("/providers", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("anonymous /providers: got %d, want 403", rec.Code)
+	}
+	key := authenticator.GeneratedKey()
+	if key == "" {
+		t.Fatal("default config has no generated internal key")
+	}
+	if rec := request("/providers", key); rec.Code != http.StatusOK {
+		t.Errorf("/providers with the generated key: got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestEndToEndDefaultCORS(t *testing.T) { // настройки по умолчанию разрешают из браузера все методы API, включая DELETE
+	newHarness(t)
+	handler := newHandler()
+	for _, tc := range []struct{ method, target string }{
+		{http.MethodGet, "/systemsstatus"},
+		{http.MethodPost, "/maintenance/windows"},
+		{http.MethodDelete, "/maintenance/windows/1"},
+		{http.MethodDelete, "/maintenance/overrides/1"},
+	} {
+		req := httptest.NewRequest(http.MethodOptions, tc.target, nil)
+		req.Header.Set("Origin", "https://dash.example.com")
+		req.Header.Set("Access-Control-Request-Method", tc.method)
+		req.Header.Set("Access-Control-Request-Headers", "X-API-Key, Content-Type")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if allowed := rec.Header().Get("Access-Control-Allow-Methods"); rec.Code != http.StatusNoContent || !strings.Contains(allowed, tc.method) {
+			t.Errorf("preflight %s %s: got %d, Access-Control-Allow-Methods %q", tc.method, tc.target, rec.Code, allowed)
+		}
+	}
+}
+
+func TestEndToEndRateLimit(t *testing.T) { // неудачные попытки аутентификации тоже ограничиваются, по IP
+	h := newHarness(t)
+	h.configure("rate_limit", map[string]interface{}{"enabled": true, "rate": 0.5, "burst": 2})
+	handler := newHandler()
+	request := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/maintenance/windows", nil)
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		if rec := request("wrong"); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d with a wrong key: got %d, want 401", i, rec.Code)
+		}
+	}
+	rec := request("wrong")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "2" {
+		t.Fatalf("third wrong key: got %d, Retry-After %q, want 429 and 2", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if rec := request(authenticator.GeneratedKey()); rec.Code != http.StatusOK { // у клиента с действующим ключом своя корзина
+		t.Errorf("valid key from the same IP: got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestEndToEndCollectCoalescing(t *testing.T) { // одновременные запросы получают результат одного сбора
+	h := newHarness(t)
+	h.control(http.MethodPut, "/faults/support", json.RawMessage(`{"latency": "200ms"}`))
+	const n = 5
+	times := make(chan time.Time, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			_, at := collectResultT(countries.DefaultLocale)
+			times <- at
+		}()
+	}
+	first := <-times
+	for i := 1; i < n; i++ {
+		if at := <-times; !at.Equal(first) { // без объединения сборы идут друг за другом (collectMu) и заканчиваются в разное время
+			t.Errorf("collection finished at %v and at %v", first, at)
+		}
+	}
+}
+
+func TestEndToEndStaleSource(t *testing.T) { // отключенный источник: данные последнего успешного ответа с пометкой stale
+	h := newHarness(t)
+	h.configure("upstream", map[string]interface{}{"retries": 0, "read_timeout": "500ms", "breaker_failures": 1, "breaker_cooldown": "1h"})
+	h.control(http.MethodPut, "/data/mms", exactSMS)
+	if rT := h.collect(); !rT.Status || len(rT.Data.MMS) != 2 || rT.Data.Stale != nil {
+		t.Fatalf("healthy source: %+v", rT)
+	}
+	h.control(http.MethodPut, "/faults/mms", simulator.Fault{Status: http.StatusInternalServerError})
+	if rT := h.collect(); len(rT.Data.MMS) != 0 || rT.Data.Stale != nil { // ошибка отключает источник
+		t.Fatalf("failing source: %+v", rT.Data)
+	}
+	rT := h.collect()
+	if !rT.Status || len(rT.Data.MMS) != 2 || len(rT.Data.MMS[0]) != len(exactSMS) || !reflect.DeepEqual(rT.Data.Stale, []string{"mms"}) {
+		t.Errorf("disabled source: status %v, mms %v, stale %v", rT.Status, rT.Data.MMS, rT.Data.Stale)
+	}
+}
+
+func TestEndToEndArchiveReplay(t *testing.T) { // запись сбора и воспроизведение с другими настройками, списком стран и данными симулятора
+	h := newHarness(t)
+	t.Cleanup(func() { replayArchive = nil })
+	countriesFile := filepath.Join(h.dir, "countries.csv")
+	if err := os.WriteFile(countriesFile, []byte("Russia;RU\nUnited States;US\nGreat Britain;GB\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := initCountryRepositories(countriesFile); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(h.dir, "archive")
+	h.configure("archive", map[string]interface{}{"enabled": true, "dir": dir})
+	h.configure("voice_quality", map[string]interface{}{"threshold": 4.4})
+	h.control(http.MethodPut, "/data/sms", exactSMS)
+	recorded := h.collect()
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("archives: %v, %v", files, err)
+	}
+
+	h.configure("archive", map[string]interface{}{"enabled": false})
+	h.configure("voice_quality", map[string]interface{}{"threshold": 1.5}) // все это воспроизведение берет из архива
+	h.configure("providers", []map[string]interface{}{{"name": "Topolo", "channels": []string{"sms"}}})
+	if err := initCountryRepositories(""); err != nil {
+		t.Fatal(err)
+	}
+	h.control(http.MethodPut, "/data/sms", []simulator.SMSRow{{Country: "FR", Provider: "Rond", Bandwidth: 1, ResponseTime: 1}})
+	h.sim.Close() // симулятор не нужен
+
+	if err := initReplay(files[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkReplay(); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if replayed := h.collect(); !reflect.DeepEqual(replayed, recorded) {
+		t.Errorf("replayed result differs:\n%+v\n%+v", replayed, recorded)
+	}
+	if cfg.VoiceQuality.Threshold != 4.4 || countryRepo.Len() != 3 {
+		t.Errorf("replay uses threshold %v and %d countries instead of the archived ones", cfg.VoiceQuality.Threshold, countryRepo.Len())
+	}
+
+	changed := *replayArchive // результат, записанный другой версией обработки
+	var rT ResultT
+	if err := json.Unmarshal(changed.Result, &rT); err != nil {
+		t.Fatal(err)
+	}
+	rT.Data.SMS = nil
+	rT.Data.Overall.State = "major_outage"
+	if changed.Result, err = json.Marshal(rT); err != nil {
+		t.Fatal(err)
+	}
+	replayArchive = &changed
+	if err := checkReplay(); err == nil || err.Error() != "result differs from the archive in data.overall, data.sms" {
+		t.Errorf("changed archive: got %v", err)
+	}
+}
+
+func TestEndToEndProviders(t *testing.T) { // страны провайдеров берутся из последнего сбора, источники повторно не опрашиваются
+	h := newHarness(t)
+	h.control(http.MethodPut, "/data/sms", exactSMS)
+	providersOf := func() ProvidersResponse {
+		t.Helper()
+		rec := h.serve(http.MethodGet, "/providers", nil)
+		var resp ProvidersResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK || resp.Errors != nil {
+			t.Fatalf("/providers: %d %s", rec.Code, rec.Body.String())
+		}
+		return resp
+	}
+	smsCountries := func(resp ProvidersResponse, provider string) []string {
+		for _, p := range resp.Providers {
+			if p.Name == provider {
+				return p.Countries["sms"]
+			}
+		}
+		return nil
+	}
+
+	first := providersOf() // сборов еще не было - выполняется сбор
+	if got := smsCountries(first, "Rond"); !reflect.DeepEqual(got, []string{"US"}) || first.CollectedAt.IsZero() {
+		t.Fatalf("first call: Rond in %v, collected at %v", got, first.CollectedAt)
+	}
+	h.control(http.MethodPut, "/data/sms", []simulator.SMSRow{{Country: "FR", Provider: "Rond", Bandwidth: 1, ResponseTime: 1}})
+	if again := providersOf(); !again.CollectedAt.Equal(first.CollectedAt) || !reflect.DeepEqual(smsCountries(again, "Rond"), []string{"US"}) {
+		t.Errorf("second call refetched the sources: %+v", again)
+	}
+	h.collect()
+	if got := smsCountries(providersOf(), "Rond"); !reflect.DeepEqual(got, []string{"FR"}) {
+		t.Errorf("after a collection: Rond in %v, want [FR]", got)
+	}
+}
+
+func TestEndToEndPatchRows(t *testing.T) {
+	h := newHarness(t)
+	rows := append(exactSMS, simulator.SMSRow{Country: "RU", Provider: "Topolo", Bandwidth: 90, ResponseTime: 900})
+	h.control(http.MethodPut, "/data/sms", rows)
+	patch := func(body interface{}) int {
+		data, _ := json.Marshal(body)
+		req, _ := http.NewRequest(http.MethodPatch, h.sim.URL+"/data/sms", bytes.NewReader(data))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := patch([]simulator.SMSRow{{Country: "US", Provider: "Rond", Bandwidth: 1, ResponseTime: 1}, {Country: "RU", Provider: "Topolo", Bandwidth: 2, ResponseTime: 2}}); code != http.StatusBadRequest {
+		t.Errorf("patch of a pair with two rows: got %d, want 400", code)
+	}
+	if code := patch([]simulator.SMSRow{{Country: "US", Provider: "Rond", Bandwidth: 1, ResponseTime: 1}}); code != http.StatusNoContent {
+		t.Errorf("patch of a single row: got %d, want 204", code)
+	}
+	rT := h.collect()
+	wantOK(t, rT)
+	var got []string
+	for _, v := range rT.Data.SMS[1] {
+		got = append(got, v.Provider+":"+v.Bandwidth)
+	}
+	sort.Strings(got) // отклоненный PATCH ничего не изменил
+	if want := []string{"Kildy:30", "Rond:1", "Topolo:10", "Topolo:90"}; !equalStrings(got, want) {
+		t.Errorf("sms after patches: got %v, want %v", got, want)
+	}
+}
+
+func TestEndToEndIncidents(t *testing.T) {
+	h := newHarness(t)
+	h.control(http.MethodPut, "/data/billing", "111111")
+	h.control(http.MethodPut, "/data/support", []simulator.SupportItem{{Topic: "SMS", ActiveTickets: 1}})
+	h.control(http.MethodPut, "/data/accendent", []simulator.AccendentItem{
+		{Topic: "SMS delivery in EU", Status: "active"},
+		{Topic: "Buy phone number not working in US and CA", Status: "active"},
+		{Topic: "Checkout page is down", Status: "closed"},
+		{Topic: "API Slow latency", Status: "active", Severity: "Critical", Updates: []simulator.AccendentUpdate{
+			{At: time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC), Text: "Investigating"},
+			{At: time.Date(2026, 10, 19, 11, 0, 0, 0, time.UTC), Text: "Fix deployed"},
+			{At: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC), Text: " "},
+		}},
+	})
+
+	rT := h.collect()
+	wantOK(t, rT)
+	got := make(map[string]IncidentData)
+	for _, inc := range rT.Data.Incidents {
+		got[inc.Topic] = inc
+	}
+	if inc := got["SMS delivery in EU"]; inc.System != "sms" || inc.Severity != "major" || !equalStrings(inc.Regions, []string{"EU"}) || len(inc.Countries) != 27 {
+		t.Errorf("sms incident: got %+v", inc)
+	}
+	if inc := got["Buy phone number not working in US and CA"]; inc.System != "billing" || inc.Severity != "major" || inc.Regions != nil || !equalStrings(inc.Countries, []string{"US", "CA"}) {
+		t.Errorf("billing incident: got %+v", inc)
+	}
+	if inc := got["Checkout page is down"]; inc.System != "billing" || inc.Severity != "critical" || inc.Countries != nil {
+		t.Errorf("checkout incident: got %+v", inc)
+	}
+	if inc := got["API Slow latency"]; inc.System != "" || inc.Severity != "critical" || len(inc.Updates) != 2 || inc.Updates[0].Text != "Fix deployed" {
+		t.Errorf("severity and updates from the source: got %+v", inc)
+	}
+
+	pr := publicResult(rT)
+	if pr.Data.SMS != healthDegraded || pr.Data.Billing != healthDegraded || pr.Data.Support != healthOK || pr.Data.ActiveIncidents != 3 {
+		t.Errorf("aggregated: got %+v, want sms and billing degraded by active incidents", pr.Data)
+	}
+}
+
+func TestEndToEndOverallState(t *testing.T) {
+	h := newHarness(t)
+	h.control(http.MethodPut, "/data/support", []simulator.SupportItem{{Topic: "SMS", ActiveTickets: 1}})
+	h.control(http.MethodPut, "/data/accendent", []simulator.AccendentItem{{Topic: "SMS delivery in EU", Status: "active"}})
+	h.control(http.MethodPut, "/data/billing", "111111")
+	rT := h.collect()
+	wantOK(t, rT)
+	if o := rT.Data.Overall; o.State != rules.Degraded || o.Components["sms"] != rules.Degraded || o.Components["billing"] != rules.Operational ||
+		len(o.Reasons) != 1 || o.Reasons[0].Rule != "component.incidents > 0" {
+		t.Errorf("active sms incident: got %+v", o)
+	}
+
+	h.control(http.MethodPut, "/data/billing", "111110") // checkout_page выключен
+	h.control(http.MethodPut, "/data/support", []simulator.SupportItem{{Topic: "SMS", ActiveTickets: 100}})
+	rT = h.collect()
+	wantOK(t, rT)
+	o := rT.Data.Overall
+	if o.State != rules.PartialOutage || o.Components["billing"] != rules.PartialOutage || o.Components["support"] != rules.Degraded || len(o.Reasons) != 3 {
+		t.Errorf("billing flag off and support overloaded: got %+v", o)
+	}
+	pr := publicResult(rT)
+	if pr.Data.Overall.State != rules.PartialOutage || pr.Data.Overall.Reasons[0].Rule != "" || pr.Data.Overall.Reasons[0].Message == "" {
+		t.Errorf("public overall state must keep messages and hide conditions: got %+v", pr.Data.Overall)
+	}
+	if o.Reasons[0].Rule == "" {
+		t.Error("Public changed the full result")
+	}
+}
+
+func TestEndToEndMaintenance(t *testing.T) {
+	h := newHarness(t)
+	h.control(http.MethodPut, "/data/sms", exactSMS)
+	h.control(http.MethodPut, "/data/billing", "111110")
+	h.control(http.MethodPut, "/data/support", []simulator.SupportItem{{Topic: "SMS", ActiveTickets: 1}})
+	h.control(http.MethodPut, "/data/accendent", []simulator.AccendentItem{{Topic: "SMS delivery in EU", Status: "active"}})
+	now := time.Now()
+
+	rec := h.serve(http.MethodPost, "/maintenance/windows", map[string]interface{}{"providers": []string{"kildy"}, "end": now.Add(time.Hour), "message": "Kildy maintenance"})
+	var window struct {
+		ID        string   `json:"id"`
+		Providers []string `json:"providers"`
+	}
+	if rec.Code != http.StatusCreated || json.Unmarshal(rec.Body.Bytes(), &window) != nil || window.ID == "" || !equalStrings(window.Providers, []string{"Kildy"}) {
+		t.Fatalf("add window: %d %s", rec.Code, rec.Body)
+	}
+	h.serve(http.MethodPost, "/maintenance/windows", map[string]interface{}{"systems": []string{"email"}, "start": now.Add(time.Hour), "end": now.Add(2 * time.Hour), "message": "later"})
+	for _, body := range []map[string]interface{}{
+		{"providers": []string{"Nobody"}, "end": now.Add(time.Hour), "message": "x"},
+		{"countries": []string{"XX"}, "end": now.Add(time.Hour), "message": "x"},
+		{"systems": []string{"sms"}, "end": now.Add(-time.Hour), "message": "x"},
+		{"end": now.Add(time.Hour), "message": "x"},
+		{"systems": []string{"sms"}, "end": now.Add(time.Hour)},
+	} {
+		if rec := h.serve(http.MethodPost, "/maintenance/windows", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%v: got %d %s, want 400", body, rec.Code, rec.Body)
+		}
+	}
+
+	_, rT := h.get("")
+	o := rT.Data.Overall
+	if o.Components["sms"] != rules.Degraded || o.Components["mms"] != rules.Operational || o.State != rules.PartialOutage {
+		t.Errorf("kildy window: got %+v, want the states of other providers kept", o)
+	}
+	gb := countryRepo.NameIn("GB", countries.DefaultLocale)
+	var flagged []string
+	for _, m := range rT.Data.Maintenance {
+		if m.WindowID != window.ID || m.Provider != "Kildy" {
+			t.Errorf("row outside the kildy window flagged: %+v", m)
+		}
+		if m.System == "sms" {
+			flagged = append(flagged, m.Country)
+		}
+	}
+	if !equalStrings(flagged, []string{gb}) {
+		t.Errorf("flagged sms rows: got %q, want %q", flagged, gb)
+	}
+
+	rec = h.serve(http.MethodPost, "/maintenance/windows", map[string]interface{}{"systems": []string{"sms"}, "end": now.Add(time.Hour), "message": "SMS gateway upgrade"})
+	var smsWindow struct {
+		ID string `json:"id"`
+	}
+	if rec.Code != http.StatusCreated || json.Unmarshal(rec.Body.Bytes(), &smsWindow) != nil {
+		t.Fatalf("add sms window: %d %s", rec.Code, rec.Body)
+	}
+	_, rT = h.get("")
+	o = rT.Data.Overall
+	if o.Components["sms"] != rules.UnderMaintenance || o.Components["mms"] != rules.Operational || o.State != rules.PartialOutage {
+		t.Errorf("sms window: got %+v, want sms under maintenance and the overall state from billing", o)
+	}
+	for _, r := range o.Reasons {
+		if r.Component == "sms" && r.Kind == "" && !r.Suppressed {
+			t.Errorf("sms incident rule must be suppressed during maintenance: %+v", r)
+		}
+	}
+	if pr := publicResult(rT); pr.Data.SMS != healthMaintenance || pr.Data.Billing != healthDegraded {
+		t.Errorf("aggregated: got %+v", pr.Data)
+	}
+
+	rec = h.serve(http.MethodPost, "/maintenance/overrides", map[string]interface{}{"component": "billing", "state": "degraded", "message": "Fix in progress", "expires_at": now.Add(time.Hour)})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("add override: %d %s", rec.Code, rec.Body)
+	}
+	if rec := h.serve(http.MethodPost, "/maintenance/overrides", map[string]interface{}{"state": "red", "message": "x", "expires_at": now.Add(time.Hour)}); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown state: got %d", rec.Code)
+	}
+	if _, rT = h.get(""); rT.Data.Overall.State != rules.Degraded || rT.Data.Overall.Components["billing"] != rules.Degraded {
+		t.Errorf("billing override: got %+v", rT.Data.Overall)
+	}
+	rec = h.serve(http.MethodPost, "/maintenance/overrides", map[string]interface{}{"component": "mms", "state": "major_outage", "message": "Gateway down", "expires_at": now.Add(time.Hour)})
+	var mmsOverride struct {
+		ID string `json:"id"`
+	}
+	if rec.Code != http.StatusCreated || json.Unmarshal(rec.Body.Bytes(), &mmsOverride) != nil {
+		t.Fatalf("add mms override: %d %s", rec.Code, rec.Body)
+	}
+	if _, rT = h.get(""); publicResult(rT).Data.MMS != healthUnavailable { // агрегированное состояние следует за overall
+		t.Errorf("mms override in the aggregated state: got %+v", publicResult(rT).Data)
+	}
+	if rec := h.serve(http.MethodDelete, "/maintenance/overrides/"+mmsOverride.ID, nil); rec.Code != http.StatusNoContent {
+		t.Errorf("delete override: %d", rec.Code)
+	}
+
+	// окна и состояния сохраняются в файле и переживают перезапуск
+	if err := initConfig(filepath.Join(h.dir, "config.json")); err != nil {
+		t.Fatal(err)
+	}
+	rec = h.serve(http.MethodGet, "/maintenance/windows", nil)
+	var list struct {
+		Windows []struct {
+			ID     string `json:"id"`
+			Active bool   `json:"active"`
+		} `json:"windows"`
+	}
+	if json.Unmarshal(rec.Body.Bytes(), &list) != nil || len(list.Windows) != 3 || list.Windows[0].ID != window.ID ||
+		!list.Windows[0].Active || !list.Windows[1].Active || list.Windows[2].Active {
+		t.Fatalf("windows after restart: %s", rec.Body)
+	}
+	if overrides := maintenanceStore.Overrides(time.Now()); len(overrides) != 1 || overrides[0].Message != "Fix in progress" {
+		t.Errorf("overrides after restart: %+v", overrides)
+	}
+
+	if rec := h.serve(http.MethodDelete, "/maintenance/windows/"+smsWindow.ID, nil); rec.Code != http.StatusNoContent {
+		t.Errorf("delete window: %d", rec.Code)
+	}
+	if rec := h.serve(http.MethodDelete, "/maintenance/windows/"+smsWindow.ID, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("delete twice: %d", rec.Code)
+	}
+	if _, rT = h.get(""); rT.Data.Overall.Components["sms"] != rules.Degraded {
+		t.Errorf("after the window: got %+v", rT.Data.Overall)
+	}
+}
+
+func TestEndToEndProviderMaintenance(t *testing.T) { // плановые работы Kildy: страница не краснеет, письма не уходят
+	h := newHarness(t)
+	l, err := simulator.ListenSMTP("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	h.configure("notifications", map[string]interface{}{
+		"enabled":  true,
+		"file":     filepath.Join(h.dir, "subscribers.json"),
+		"base_url": "https://status.example.com",
+		"smtp":     map[string]string{"addr": l.Addr().String(), "from": "status@example.com"},
+	})
+	t.Cleanup(func() { notifier = nil })
+	simulator.ClearMailbox()
+	if rec := h.serve(http.MethodPost, "/subscriptions", map[string]interface{}{"email": "ops@example.com"}); rec.Code != http.StatusAccepted {
+		t.Fatalf("subscribe: %d %s", rec.Code, rec.Body)
+	}
+	notifier.Wait()
+	mail := simulator.Mailbox()
+	if len(mail) != 1 {
+		t.Fatalf("confirmation mail: %+v", mail)
+	}
+	confirm := regexp.MustCompile(`token=([0-9a-f]+)`).FindStringSubmatch(mail[0].Body)[1]
+	if rec := h.serve(http.MethodPost, "/subscriptions/confirm?token="+confirm, nil); rec.Code != http.StatusOK {
+		t.Fatalf("confirm: %d %s", rec.Code, rec.Body)
+	}
+
+	h.control(http.MethodPut, "/data/sms", exactSMS)
+	h.control(http.MethodPut, "/data/mms", []simulator.SMSRow{{Country: "GB", Provider: "Kildy", Bandwidth: 1, ResponseTime: 1}, {Country: "DE", Provider: "Kildy", Bandwidth: 2, ResponseTime: 2}})
+	h.control(http.MethodPut, "/data/billing", "111111")
+	h.control(http.MethodPut, "/data/support", []simulator.SupportItem{{Topic: "SMS", ActiveTickets: 1}})
+	h.control(http.MethodPut, "/data/accendent", []simulator.AccendentItem{{Topic: "Kildy SMS outage in GB", Status: "closed"}})
+	h.collect() // первый сбор только запоминает статусы инцидентов
+
+	rec := h.serve(http.MethodPost, "/maintenance/windows", map[string]interface{}{"providers": []string{"Kildy"}, "end": time.Now().Add(time.Hour), "message": "Kildy maintenance"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("add window: %d %s", rec.Code, rec.Body)
+	}
+	notifier.Wait()
+	simulator.ClearMailbox()
+	h.control(http.MethodPut, "/data/accendent", []simulator.AccendentItem{{Topic: "Kildy SMS outage in GB", Status: "active"}})
+	h.collect()
+	notifier.Wait()
+	if mail := simulator.Mailbox(); len(mail) != 0 {
+		t.Errorf("incident of a provider under maintenance must not be mailed: %+v", mail)
+	}
+
+	_, rT := h.get("")
+	o := rT.Data.Overall
+	if o.State != rules.UnderMaintenance && o.State != rules.Operational || o.Components["sms"] != rules.Operational || o.Components["mms"] != rules.UnderMaintenance {
+		t.Errorf("kildy window: got %+v, want sms operational without the kildy incident and mms under maintenance", o)
+	}
+	var flagged []string
+	for _, m := range rT.Data.Maintenance {
+		flagged = append(flagged, m.System+":"+m.Provider)
+	}
+	sort.Strings(flagged)
+	if want := []string{"mms:Kildy", "mms:Kildy", "sms:Kildy"}; !equalStrings(flagged, want) {
+		t.Errorf("flagged rows: got %q, want %q", flagged, want)
+	}
+	if pr := publicResult(rT); pr.Data.SMS != healthOK || pr.Data.MMS != healthMaintenance {
+		t.Errorf("aggregated: got %+v", pr.Data)
+	}
+
+	simulator.ClearMailbox() // инцидент без упоминания Kildy окно не покрывает
+	h.control(http.MethodPut, "/data/accendent", []simulator.AccendentItem{{Topic: "Kildy SMS outage in GB", Status: "active"}, {Topic: "SMS delivery in US", Status: "active"}})
+	h.collect()
+	notifier.Wait()
+	if mail := simulator.Mailbox(); len(mail) != 1 || mail[0].Subject != "[Major incident] SMS delivery in US" {
+		t.Errorf("incident outside the window: %+v", mail)
+	}
+	if _, rT = h.get(""); rT.Data.Overall.Components["sms"] != rules.Degraded {
+		t.Errorf("incident outside the window: got %+v", rT.Data.Overall)
+	}
+}
+
+func TestEndToEndNotifications(t *testing.T) {
+	h := newHarness(t)
+	if rec := h.serve(http.MethodPost, "/subscriptions", map[string]string{"email": "a@example.com"}); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("notifications disabled: got %d", rec.Code)
+	}
+	l, err := simulator.ListenSMTP("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	simulator.ClearMailbox()
+	h.configure("notifications", map[string]interface{}{
+		"enabled":  true,
+		"file":     filepath.Join(h.dir, "subscribers.json"),
+		"base_url": "https://status.example.com/",
+		"smtp":     map[string]string{"addr": l.Addr().String(), "from": "status@example.com"},
+	})
+	t.Cleanup(func() { notifier = nil })
+	h.control(http.MethodPut, "/data/accendent", []simulator.AccendentItem{{Topic: "Billing isn't allowed in US", Status: "closed"}})
+	h.collect() // первый сбор только запоминает статусы инцидентов
+
+	token := regexp.MustCompile(`token=([0-9a-f]+)`)
+	subscribe := func(email string, components ...string) string { // возвращает токен из письма подтверждения
+		t.Helper()
+		simulator.ClearMailbox()
+		if rec := h.serve(http.MethodPost, "/subscriptions", map[string]interface{}{"email": email, "components": components}); rec.Code != http.StatusAccepted {
+			t.Fatalf("subscribe %s: %d %s", email, rec.Code, rec.Body)
+		}
+		notifier.Wait()
+		mail := simulator.Mailbox()
+		if len(mail) != 1 || mail[0].To[0] != strings.ToLower(email) || !strings.Contains(mail[0].Body, "https://status.example.com/subscriptions/confirm?token=") {
+			t.Fatalf("confirmation mail: %+v", mail)
+		}
+		return token.FindStringSubmatch(mail[0].Body)[1]
+	}
+	all := subscribe("All@Example.com")
+	smsOnly := subscribe("sms@example.com", "sms")
+	subscribe("pending@example.com") // без подтверждения писем нет
+	for _, body := range []map[string]interface{}{{"email": "not an address"}, {"email": "x@example.com", "components": []string{"fax"}}} {
+		if rec := h.serve(http.MethodPost, "/subscriptions", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%v: got %d, want 400", body, rec.Code)
+		}
+	}
+	rec := h.serve(http.MethodGet, "/subscriptions/confirm?token="+all, nil) // переход по ссылке только показывает кнопку
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `method="post"`) || !strings.Contains(rec.Body.String(), all) {
+		t.Fatalf("confirmation page: %d %s", rec.Code, rec.Body)
+	}
+	if s := notifier.Subscribers(); s[0].Confirmed {
+		t.Fatal("GET must not confirm the subscription")
+	}
+	for _, tok := range []string{all, smsOnly} {
+		if rec := h.serve(http.MethodPost, "/subscriptions/confirm?token="+tok, nil); rec.Code != http.StatusOK {
+			t.Fatalf("confirm: %d %s", rec.Code, rec.Body)
+		}
+	}
+	if rec := h.serve(http.MethodPost, "/subscriptions/confirm?token="+all, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("confirm twice: got %d", rec.Code)
+	}
+
+	simulator.ClearMailbox()
+	h.control(http.MethodPut, "/data/accendent", []simulator.AccendentItem{
+		{Topic: "Billing isn't allowed in US", Status: "active", Severity: "critical"},
+		{Topic: "SMS delivery in EU", Status: "closed"},
+	})
+	h.collect()
+	notifier.Wait()
+	mail := simulator.Mailbox()
+	if len(mail) != 1 || mail[0].To[0] != "all@example.com" || mail[0].Subject != "[Critical incident] Billing isn't allowed in US" ||
+		!strings.Contains(mail[0].Body, "System: billing") || !strings.HasPrefix(mail[0].Headers["List-Unsubscribe"], "<https://status.example.com/subscriptions/unsubscribe?token=") {
+		t.Fatalf("billing incident opened: %+v", mail)
+	}
+	unsubscribeAll := token.FindStringSubmatch(mail[0].Headers["List-Unsubscribe"])[1]
+
+	simulator.ClearMailbox()
+	h.control(http.MethodPut, "/data/accendent", []simulator.AccendentItem{
+		{Topic: "Billing isn't allowed in US", Status: "closed"},
+		{Topic: "SMS delivery in EU", Status: "active"},
+	})
+	h.collect()
+	h.collect() // статусы не изменились - повторных писем нет
+	notifier.Wait()
+	var subjects []string
+	for _, m := range simulator.Mailbox() {
+		subjects = append(subjects, m.To[0]+": "+m.Subject)
+	}
+	sort.Strings(subjects)
+	if want := []string{
+		"all@example.com: [Major incident] SMS delivery in EU",
+		"all@example.com: [Resolved] Billing isn't allowed in US",
+		"sms@example.com: [Major incident] SMS delivery in EU",
+	}; !equalStrings(subjects, want) {
+		t.Errorf("incident transitions: got %q, want %q", subjects, want)
+	}
+
+	if rec := h.serve(http.MethodGet, "/subscriptions/unsubscribe?token="+unsubscribeAll, nil); rec.Code != http.StatusOK || len(notifier.Subscribers()) != 3 {
+		t.Fatalf("GET must only show the unsubscribe page: %d", rec.Code)
+	}
+	if rec := h.serve(http.MethodPost, "/subscriptions/unsubscribe?token="+unsubscribeAll, nil); rec.Code != http.StatusOK {
+		t.Fatalf("unsubscribe: %d %s", rec.Code, rec.Body)
+	}
+	simulator.ClearMailbox()
+	h.serve(http.MethodPost, "/maintenance/windows", map[string]interface{}{"systems": []string{"sms"}, "end": time.Now().Add(time.Hour), "message": "SMS gateway upgrade"})
+	h.serve(http.MethodPost, "/maintenance/windows", map[string]interface{}{"systems": []string{"email"}, "end": time.Now().Add(time.Hour), "message": "Mail relay upgrade"})
+	notifier.Wait()
+	if mail := simulator.Mailbox(); len(mail) != 1 || mail[0].To[0] != "sms@example.com" || mail[0].Subject != "Scheduled maintenance: SMS gateway upgrade" {
+		t.Errorf("maintenance announcement: %+v", mail)
+	}
+	simulator.ClearMailbox()
+	h.control(http.MethodPut, "/data/accendent", []simulator.AccendentItem{{Topic: "SMS delivery in EU", Status: "closed"}})
+	h.collect()
+	notifier.Wait()
+	if mail := simulator.Mailbox(); len(mail) != 0 {
+		t.Errorf("incidents of systems under maintenance must not be mailed: %+v", mail)
+	}
+
+	// подписчики сохраняются в файле и переживают перезапуск
+	h.configure("notifications", map[string]interface{}{
+		"enabled":  true,
+		"file":     filepath.Join(h.dir, "subscribers.json"),
+		"base_url": "https://status.example.com",
+		"smtp":     map[string]string{"addr": l.Addr().String(), "from": "status@example.com"},
+	})
+	rec = h.serve(http.MethodGet, "/subscriptions", nil)
+	var list struct {
+		Subscribers []subscriberInfo `json:"subscribers"`
+	}
+	if json.Unmarshal(rec.Body.Bytes(), &list) != nil || len(list.Subscribers) != 2 || list.Subscribers[0].Email != "sms@example.com" ||
+		!list.Subscribers[0].Confirmed || list.Subscribers[1].Confirmed || strings.Contains(rec.Body.String(), "token") {
+		t.Errorf("subscribers after restart: %s", rec.Body)
+	}
+}
+
+func TestEndToEndSortParameter(t *testing.T) {
+	h := newHarness(t)
+	rows := append(exactSMS, simulator.SMSRow{Country: "RU", Provider: "Rond", Bandwidth: 30, ResponseTime: 100})
+	h.control(http.MethodPut, "/data/sms", rows)
+	h.control(http.MethodPut, "/data/mms", rows)
+
+	code, rT := h.get("")
+	if code != http.StatusOK || !rT.Status {
+		t.Fatalf("status %d, %+v", code, rT)
+	}
+	if got, want := smsColumn(rT.Data.SMS[1], "provider"), []string{"Rond", "Topolo", "Kildy", "Rond"}; !equalStrings(got, want) {
+		t.Errorf("default order by country, then provider: got %v, want %v", got, want)
+	}
+
+	code, rT = h.get("sort=sms:-bandwidth&sort=mms:response_time,-bandwidth")
+	if code != http.StatusOK || !rT.Status {
+		t.Fatalf("status %d, %+v", code, rT)
+	}
+	if got, want := smsColumn(rT.Data.SMS[0], "bandwidth"), []string{"30", "30", "20", "10"}; !equalStrings(got, want) {
+		t.Errorf("sms by provider, then bandwidth descending: got %v, want %v", got, want)
+	}
+	if got, want := smsColumn(rT.Data.SMS[1], "bandwidth"), []string{"30", "10", "30", "20"}; !equalStrings(got, want) {
+		t.Errorf("sms by country, then bandwidth descending: got %v, want %v", got, want)
+	}
+	if got, want := mmsColumn(rT.Data.MMS[1], "provider"), []string{"Rond", "Topolo", "Kildy", "Rond"}; !equalStrings(got, want) {
+		t.Errorf("mms by country, then response time: got %v, want %v", got, want)
+	}
+
+	for _, query := range []string{"sort=sms:speed", "sort=fax:country", "sort=sms"} {
+		if code, rT := h.get(query); code != http.StatusBadRequest || rT.Status || !strings.Contains(rT.Error, "sort") {
+			t.Errorf("%s: got status %d, %+v", query, code, rT)
+		}
+	}
+}
+
+func TestEndToEndScenarios(t *testing.T) {
+	tests := []struct {
+		name    string
+		simArgs []string
+		setup   func(h *harness)
+		check   func(t *testing.T, rT ResultT)
+	}{
+		{
+			name:    "corrupt rows are dropped",
+			simArgs: []string{"-countries", "RU,US,GB,FR,DE,ES", "-corrupt-rows", "2", "-corrupt-kinds", "separators,truncate,garbage"},
+			check: func(t *testing.T, rT ResultT) {
+				wantOK(t, rT)
+				if got := len(rT.Data.SMS[0]); got != 4 {
+					t.Errorf("sms rows: got %d, want 4 of 6", got)
+				}
+				if got := len(rT.Data.VoiceCall); got != 4 {
+					t.Errorf("voice rows: got %d, want 4 of 6", got)
+				}
+				if got := len(rT.Data.MMS[0]); got != 6 {
+					t.Errorf("mms rows: got %d, want all 6 (the API is not corrupted)", got)
+				}
+			},
+		},
+		{
+			name: "unknown countries and providers are dropped",
+			setup: func(h *harness) {
+				h.control(http.MethodPut, "/data/sms", append([]simulator.SMSRow{
+					{Country: "XX", Provider: "Topolo", Bandwidth: 1, ResponseTime: 1},
+					{Country: "RU", Provider: "Nokia", Bandwidth: 1, ResponseTime: 1},
+				}, exactSMS...))
+			},
+			check: func(t *testing.T, rT ResultT) {
+				wantOK(t, rT)
+				if got := len(rT.Data.SMS[0]); got != len(exactSMS) {
+					t.Errorf("sms rows: got %d, want %d", got, len(exactSMS))
+				}
+			},
+		},
+		{
+			name: "mms server error",
+			setup: func(h *harness) {
+				h.control(http.MethodPut, "/faults/mms", simulator.Fault{Status: http.StatusInternalServerError})
+			},
+			check: func(t *testing.T, rT ResultT) {
+				wantOK(t, rT) // код, отличный от 200, не считается ошибкой сбора: система MMS просто пустая
+				if rT.Data.MMS != nil {
+					t.Errorf("mms: got %v, want nil", rT.Data.MMS)
+				}
+			},
+		},
+		{
+			name: "mms malformed body",
+			setup: func(h *harness) {
+				h.control(http.MethodPut, "/faults/mms", simulator.Fault{Body: "malformed"})
+			},
+			check: wantError("/mms"),
+		},
+		{
+			name: "support truncated body",
+			setup: func(h *harness) {
+				h.control(http.MethodPut, "/faults/support", simulator.Fault{Body: "truncated"})
+			},
+			check: wantError("/support"),
+		},
+		{
+			name: "incident connection reset",
+			setup: func(h *harness) {
+				h.control(http.MethodPut, "/faults/accendent", simulator.Fault{Reset: true})
+			},
+			check: wantError("/accendent"),
+		},
+		{
+			name: "mms unexpected content type",
+			setup: func(h *harness) {
+				h.control(http.MethodPut, "/faults/mms", simulator.Fault{ContentType: "text/html"})
+			},
+			check: wantError("content type"),
+		},
+		{
+			name: "support slower than the read timeout",
+			setup: func(h *harness) {
+				h.control(http.MethodPut, "/faults/support", json.RawMessage(`{"latency": "2s"}`))
+			},
+			check: wantError("/support"),
+		},
+		{
+			name: "empty api data",
+			setup: func(h *harness) {
+				h.control(http.MethodPut, "/data/mms", []simulator.SMSRow{})
+				h.control(http.MethodPut, "/data/support", []simulator.SupportItem{})
+				h.control(http.MethodPut, "/data/accendent", []simulator.AccendentItem{})
+			},
+			check: func(t *testing.T, rT ResultT) {
+				wantOK(t, rT)
+				if len(rT.Data.MMS) != 2 || len(rT.Data.MMS[0]) != 0 || len(rT.Data.MMS[1]) != 0 {
+					t.Errorf("mms: got %v, want two empty lists", rT.Data.MMS)
+				}
+				if !equalInts(rT.Data.Support, []int{1, 0}) {
+					t.Errorf("support: got %v, want [1 0]", rT.Data.Support)
+				}
+				if len(rT.Data.Incidents) != 0 {
+					t.Errorf("incidents: got %v, want none", rT.Data.Incidents)
+				}
+			},
+		},
+		{
+			name: "empty sms, voice and email files",
+			setup: func(h *harness) {
+				h.control(http.MethodPut, "/data/sms", []simulator.SMSRow{})
+				h.control(http.MethodPut, "/data/voice", []simulator.VoiceRow{})
+				h.control(http.MethodPut, "/data/email", []simulator.EmailRow{})
+			},
+			check: func(t *testing.T, rT ResultT) {
+				wantOK(t, rT)
+				if len(rT.Data.SMS) != 2 || len(rT.Data.SMS[0]) != 0 || len(rT.Data.VoiceCall) != 0 || len(rT.Data.Email) != 0 {
+					t.Errorf("sms %v, voice %v, email %v: want no data", rT.Data.SMS, rT.Data.VoiceCall, rT.Data.Email)
+				}
+				if o := rT.Data.Overall; o.Components["sms"] != rules.MajorOutage || o.Components["voice_call"] != rules.MajorOutage || o.Components["email"] != rules.MajorOutage {
+					t.Errorf("empty files must show the systems without data: got %+v", o.Components)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t, tt.simArgs...)
+			if tt.setup != nil {
+				tt.setup(h)
+			}
+			tt.check(t, h.collect())
+		})
+	}
+}
+
+func wantOK(t *testing.T, rT ResultT) {
+	t.Helper()
+	if !rT.Status || rT.Error != "" {
+		t.Fatalf("status %v, error %q: want a successful collection", rT.Status, rT.Error)
+	}
+}
+
+func wantError(substr string) func(t *testing.T, rT ResultT) {
+	return func(t *testing.T, rT ResultT) {
+		t.Helper()
+		if rT.Status || !strings.Contains(rT.Error, substr) {
+			t.Fatalf("status %v, error %q: want a failed collection with %q in the error", rT.Status, rT.Error, substr)
+		}
+	}
+}
+
+func smsColumn(rows []SMSData, field string) []string {
+	var list []string
+	for _, r := range rows {
+		switch field {
+		case "provider":
+			list = append(list, r.Provider)
+		case "bandwidth":
+			list = append(list, r.Bandwidth)
+		default:
+			list = append(list, r.Country)
+		}
+	}
+	return list
+}
+
+func mmsColumn(rows []MMSData, field string) []string {
+	var list []string
+	for _, r := range rows {
+		switch field {
+		case "provider":
+			list = append(list, r.Provider)
+		case "bandwidth":
+			list = append(list, r.Bandwidth)
+		default:
+			list = append(list, r.Country)
+		}
+	}
+	return list
+}
+
+func emailProviders(rows []EmailData) []string {
+	var list []string
+	for _, r := range rows {
+		list = append(list, r.Provider)
+	}
+	return list
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -2,4 +2,10 @@ module finalwork
 
 go 1.19
 
-require github.com/gorilla/mux v1.8.0
+require (
+	github.com/andybalholm/brotli v1.1.1
+	github.com/golang-jwt/jwt/v5 v5.2.3
+	github.com/gorilla/mux v1.8.0
+	golang.org/x/sync v0.9.0
+	golang.org/x/text v0.14.0
+)
@@ -0,0 +1,62 @@
+package main
+
+// Агрегированное состояние систем для клиентов без scope internal: без имен провайдеров, стран, задержек и флагов биллинга.
+
+import "finalwork/internal/rules"
+
+const (
+	healthOK          = "ok"
+	healthDegraded    = "degraded"
+	healthUnavailable = "unavailable"
+	healthMaintenance = "maintenance"
+)
+
+type PublicResultT struct {
+	Status bool    `json:"status"`
+	Data   HealthT `json:"data"`
+	Error  string  `json:"error"`
+}
+
+type HealthT struct {
+	SMS             string `json:"sms"`
+	MMS             string `json:"mms"`
+	VoiceCall       string `json:"voice_call"`
+	Email           string `json:"email"`
+	Billing         string `json:"billing"`
+	Support         string `json:"support"`
+	ActiveIncidents int    `json:"active_incidents"`
+
+	Overall rules.Result `json:"overall"` // общее состояние по правилам, пояснения без условий
+}
+
+func publicResult(rT ResultT) PublicResultT { // функция сведения полной структуры к агрегированному состоянию
+	pr := PublicResultT{Status: rT.Status}
+	if !rT.Status {
+		pr.Error = "data collection failed" // текст ошибки может содержать внутренние адреса, наружу его не отдаем
+		return pr
+	}
+	d := rT.Data
+	pr.Data.Overall = d.Overall.Public()
+	systems := map[string]*string{"sms": &pr.Data.SMS, "mms": &pr.Data.MMS, "voice_call": &pr.Data.VoiceCall, "email": &pr.Data.Email, "billing": &pr.Data.Billing, "support": &pr.Data.Support}
+	for c, h := range systems { // состояние системы - то же, что в overall (правила, плановые работы и ручные состояния)
+		*h = componentHealth(d.Overall.Components[c])
+	}
+	for _, inc := range d.Incidents {
+		if inc.Status == "active" {
+			pr.Data.ActiveIncidents++
+		}
+	}
+	return pr
+}
+
+func componentHealth(state string) string { // состояние компонента по правилам -> агрегированное состояние системы
+	switch state {
+	case rules.Operational:
+		return healthOK
+	case rules.MajorOutage:
+		return healthUnavailable
+	case rules.UnderMaintenance:
+		return healthMaintenance
+	}
+	return healthDegraded // degraded и partial_outage: система работает с проблемами
+}
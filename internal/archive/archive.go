@@ -0,0 +1,190 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"finalwork/internal/fetch"
+	"finalwork/internal/input"
+	"finalwork/internal/upstream"
+)
+
+type File struct { // содержимое файла системы или ошибка его чтения
+	Data  []byte `json:"data,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type Response struct { // ответ API или ошибка запроса
+	StatusCode  int         `json:"status_code,omitempty"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+	Stale       bool        `json:"stale,omitempty"`
+	Error       string      `json:"error,omitempty"`
+	ErrorStatus int         `json:"error_status,omitempty"` // код ответа, если ошибка - *upstream.StatusError
+}
+
+// Archive - все входные данные одного сбора и его результат.
+// Реализует input.Files и fetch.Getter, поэтому может заменить диск и API при воспроизведении
+type Archive struct {
+	Time      time.Time           `json:"time"`
+	Locale    string              `json:"locale"`
+	Files     map[string]File     `json:"files"`               // по имени файла
+	Responses map[string]Response `json:"responses"`           // по адресу
+	Countries []byte              `json:"countries,omitempty"` // CSV списка стран, по которому проверялись строки
+	Config    json.RawMessage     `json:"config,omitempty"`    // настройки, от которых зависит результат (без учетных данных)
+	Result    json.RawMessage     `json:"result"`              // ResultT, полученный из этих данных
+}
+
+var ErrNotRecorded = errors.New("archive: not recorded")
+
+func (a *Archive) ReadFile(name string) ([]byte, error) {
+	f, ok := a.Files[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: file %s", ErrNotRecorded, name)
+	}
+	if f.Error != "" {
+		return nil, errors.New(f.Error)
+	}
+	return f.Data, nil
+}
+
+func (a *Archive) Get(ctx context.Context, url string, header http.Header, maxBody int64) (*upstream.Response, error) {
+	r, ok := a.Responses[url]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotRecorded, url)
+	}
+	if r.ErrorStatus != 0 {
+		return nil, &upstream.StatusError{URL: url, StatusCode: r.ErrorStatus}
+	}
+	if r.Error != "" {
+		return nil, errors.New(r.Error)
+	}
+	return &upstream.Response{StatusCode: r.StatusCode, Header: r.Header, Body: r.Body, FetchedAt: a.Time, Stale: r.Stale}, nil
+}
+
+// Recorder читает данные из files и client и запоминает все, что было прочитано
+type Recorder struct {
+	files  input.Files
+	client fetch.Getter
+	mu     sync.Mutex
+	a      Archive
+}
+
+func NewRecorder(files input.Files, client fetch.Getter) *Recorder {
+	return &Recorder{
+		files:  files,
+		client: client,
+		a:      Archive{Time: time.Now().UTC(), Files: make(map[string]File), Responses: make(map[string]Response)},
+	}
+}
+
+func (rec *Recorder) ReadFile(name string) ([]byte, error) {
+	data, err := rec.files.ReadFile(name)
+	f := File{Data: data}
+	if err != nil {
+		f.Error = err.Error()
+	}
+	rec.mu.Lock()
+	rec.a.Files[name] = f
+	rec.mu.Unlock()
+	return data, err
+}
+
+func (rec *Recorder) Get(ctx context.Context, url string, header http.Header, maxBody int64) (*upstream.Response, error) {
+	resp, err := rec.client.Get(ctx, url, header, maxBody)
+	var r Response
+	if err != nil {
+		r.Error = err.Error()
+		r.ErrorStatus = upstream.StatusCode(err)
+	} else {
+		r = Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: resp.Body, Stale: resp.Stale}
+	}
+	rec.mu.Lock()
+	rec.a.Responses[url] = r
+	rec.mu.Unlock()
+	return resp, err
+}
+
+// Archive возвращает записанные данные с результатом сбора result
+func (rec *Recorder) Archive(locale string, result interface{}) (*Archive, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("archive: %w", err)
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	a := rec.a
+	a.Locale = locale
+	a.Result = data
+	return &a, nil
+}
+
+const fileLayout = "20060102T150405.000Z" // имя файла архива - время сбора (UTC)
+
+// Save записывает архив в dir и удаляет самые старые архивы сверх keep (0 - хранить все). Возвращает путь к файлу
+func Save(dir string, a *Archive, keep int) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("archive: %w", err)
+	}
+	data, err := json.Marshal(a)
+	if err != nil {
+		return "", fmt.Errorf("archive: %w", err)
+	}
+	name := filepath.Join(dir, a.Time.UTC().Format(fileLayout)+".json")
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return "", fmt.Errorf("archive: %w", err)
+	}
+	if err := os.Rename(tmp, name); err != nil { // файл появляется целиком
+		return "", fmt.Errorf("archive: %w", err)
+	}
+	if keep > 0 {
+		if err := prune(dir, keep); err != nil {
+			return name, err
+		}
+	}
+	return name, nil
+}
+
+func prune(dir string, keep int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("archive: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names) // имена - время, поэтому лексикографический порядок совпадает с хронологическим
+	for len(names) > keep {
+		if err := os.Remove(filepath.Join(dir, names[0])); err != nil {
+			return fmt.Errorf("archive: %w", err)
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+// Load читает архив из файла
+func Load(fileName string) (*Archive, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("archive: %w", err)
+	}
+	var a Archive
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("archive: %s: %w", fileName, err)
+	}
+	return &a, nil
+}
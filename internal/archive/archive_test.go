@@ -0,0 +1,120 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"finalwork/internal/upstream"
+)
+
+type files map[string]string // содержимое файлов; отсутствующий файл - ошибка чтения
+
+func (f files) ReadFile(name string) ([]byte, error) {
+	data, ok := f[name]
+	if !ok {
+		return nil, errors.New("no such file " + name)
+	}
+	return []byte(data), nil
+}
+
+type client map[string]int // код ответа по адресу
+
+func (c client) Get(ctx context.Context, url string, header http.Header, maxBody int64) (*upstream.Response, error) {
+	if c[url] != http.StatusOK {
+		return nil, &upstream.StatusError{URL: url, StatusCode: c[url]}
+	}
+	return &upstream.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": {"application/json"}}, Body: []byte(`[]`), Stale: true}, nil
+}
+
+func TestRoundTrip(t *testing.T) {
+	rec := NewRecorder(files{"sms.data": "RU;41;1;Topolo"}, client{"/mms": http.StatusOK, "/support": http.StatusInternalServerError})
+	type read struct {
+		data []byte
+		err  string
+	}
+	var want []interface{} // что увидел сбор при записи
+	for _, name := range []string{"sms.data", "voice.data"} {
+		data, err := rec.ReadFile(name)
+		want = append(want, read{data, errString(err)})
+	}
+	for _, url := range []string{"/mms", "/support"} {
+		resp, err := rec.Get(context.Background(), url, nil, 0)
+		want = append(want, resp, upstream.StatusCode(err))
+	}
+	a, err := rec.Archive("ru", map[string]bool{"status": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Countries = []byte("Russia;RU\n")
+	dir := t.TempDir()
+	name, err := Save(dir, a, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Locale != "ru" || string(loaded.Result) != `{"status":true}` || string(loaded.Countries) != "Russia;RU\n" || !loaded.Time.Equal(a.Time) {
+		t.Errorf("loaded archive: %+v", loaded)
+	}
+
+	var got []interface{} // что видит воспроизведение
+	for _, name := range []string{"sms.data", "voice.data"} {
+		data, err := loaded.ReadFile(name)
+		got = append(got, read{data, errString(err)})
+	}
+	for _, url := range []string{"/mms", "/support"} {
+		resp, err := loaded.Get(context.Background(), url, nil, 0)
+		if resp != nil {
+			resp.FetchedAt = time.Time{} // при воспроизведении - время сбора
+		}
+		got = append(got, resp, upstream.StatusCode(err))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("replay:\n%+v\nrecorded:\n%+v", got, want)
+	}
+	if _, err := loaded.ReadFile("email.data"); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("unrecorded file: got %v", err)
+	}
+	if _, err := loaded.Get(context.Background(), "/accendent", nil, 0); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("unrecorded url: got %v", err)
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func TestSaveKeep(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		if _, err := Save(dir, &Archive{Time: start.Add(time.Duration(i) * time.Minute)}, 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if want := []string{"20261019T100200.000Z.json", "20261019T100300.000Z.json"}; !reflect.DeepEqual(names, want) { // остаются самые новые
+		t.Errorf("got %v, want %v", names, want)
+	}
+	if _, err := Load(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("missing archive loaded")
+	}
+}
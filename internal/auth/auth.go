@@ -0,0 +1,250 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	ScopePublic   = "public"   // только агрегированное состояние систем
+	ScopeFull     = "full"     // полная структура ResultSetT без служебных обработчиков
+	ScopeInternal = "internal" // полная структура ResultSetT и служебные обработчики
+)
+
+type APIKey struct {
+	Name   string   `json:"name"` // имя клиента для логов
+	Key    string   `json:"key"`
+	Scopes []string `json:"scopes"`
+}
+
+type JWTConfig struct {
+	HMACSecret       string `json:"hmac_secret"`         // секрет для токенов HS256/HS384/HS512. Пустая строка - не принимать
+	RSAPublicKeyFile string `json:"rsa_public_key_file"` // PEM-файл открытого ключа для RS256/RS384/RS512. Пустая строка - не принимать
+	Issuer           string `json:"issuer"`              // если задан, поле iss токена должно совпадать
+	Audience         string `json:"audience"`            // если задан, поле aud токена должно его содержать
+}
+
+type Config struct {
+	Enabled        bool      `json:"enabled"`  // false - запросы без учетных данных не отклоняются, а получают scope public и full (как до появления аутентификации)
+	APIKeys        []APIKey  `json:"api_keys"` // статические ключи (заголовок X-API-Key или Authorization: Bearer)
+	JWT            JWTConfig `json:"jwt"`
+	AnonymousScope string    `json:"anonymous_scope"` // scope запросов без учетных данных при enabled: true (не internal). Пустая строка - такие запросы отклоняются
+}
+
+type Principal struct { // кто выполнил запрос
+	Name   string
+	Scopes []string
+}
+
+// HasScope проверяет наличие scope у клиента
+func (p Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	ErrNoCredentials = errors.New("auth: no credentials")
+	ErrInvalidToken  = errors.New("auth: invalid credentials")
+)
+
+type Authenticator struct {
+	cfg          Config
+	rsaKey       *rsa.PublicKey
+	clientCNs    map[string]struct{} // CN клиентских сертификатов со scope internal
+	generatedKey string              // ключ со scope internal, созданный при запуске, если в настройках его нет
+}
+
+var (
+	generatedMu sync.Mutex
+	generated   string // один ключ на все перечитывания настроек в пределах запуска
+)
+
+func generatedAPIKey() (string, error) {
+	generatedMu.Lock()
+	defer generatedMu.Unlock()
+	if generated == "" {
+		b := make([]byte, 24)
+		if _, err := rand.Read(b); err != nil {
+			return "", fmt.Errorf("auth: generate api key: %w", err)
+		}
+		generated = hex.EncodeToString(b)
+	}
+	return generated, nil
+}
+
+// New создает Authenticator. clientCNs - CN проверенных при TLS-рукопожатии клиентских сертификатов, которым выдается scope internal
+func New(cfg Config, clientCNs []string) (*Authenticator, error) {
+	a := &Authenticator{cfg: cfg, clientCNs: make(map[string]struct{})}
+	for _, cn := range clientCNs {
+		a.clientCNs[cn] = struct{}{}
+	}
+	if cfg.JWT.RSAPublicKeyFile != "" {
+		data, err := os.ReadFile(cfg.JWT.RSAPublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("auth: %w", err)
+		}
+		key, err := jwt.ParseRSAPublicKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("auth: %s: %w", cfg.JWT.RSAPublicKeyFile, err)
+		}
+		a.rsaKey = key
+	}
+	if cfg.AnonymousScope == ScopeInternal { // scope internal выдается только по ключу, JWT или сертификату
+		return nil, fmt.Errorf("auth: anonymous_scope must not be %q", ScopeInternal)
+	}
+	internal := len(clientCNs) > 0 || cfg.JWT.HMACSecret != "" || a.rsaKey != nil
+	for _, k := range cfg.APIKeys {
+		if k.Key == "" {
+			return nil, fmt.Errorf("auth: api key %q is empty", k.Name)
+		}
+		for _, s := range k.Scopes {
+			internal = internal || s == ScopeInternal
+		}
+	}
+	if !internal { // иначе до служебных обработчиков не добраться: ключ создается при запуске и выводится в лог
+		key, err := generatedAPIKey()
+		if err != nil {
+			return nil, err
+		}
+		a.generatedKey = key
+		a.cfg.APIKeys = append(append([]APIKey(nil), cfg.APIKeys...), APIKey{Name: "generated", Key: a.generatedKey, Scopes: []string{ScopePublic, ScopeInternal}})
+	}
+	return a, nil
+}
+
+// GeneratedKey возвращает ключ со scope internal, созданный при запуске, или пустую строку, если в настройках есть свой способ получить internal
+func (a *Authenticator) GeneratedKey() string {
+	return a.generatedKey
+}
+
+// Authenticate определяет клиента по заголовкам запроса. Scope internal выдается только по ключу, JWT или сертификату,
+// в том числе при выключенной аутентификации: анонимные клиенты тогда получают полную структуру, но не служебные обработчики
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	if p, ok := a.clientCertificate(r); ok {
+		return p, nil
+	}
+	credential := r.Header.Get("X-API-Key")
+	if credential == "" {
+		if h := r.Header.Get("Authorization"); len(h) > 7 && strings.EqualFold(h[:7], "Bearer ") {
+			credential = strings.TrimSpace(h[7:])
+		}
+	}
+	if credential == "" {
+		switch {
+		case !a.cfg.Enabled:
+			return Principal{Name: "anonymous", Scopes: []string{ScopePublic, ScopeFull}}, nil
+		case a.cfg.AnonymousScope != "":
+			return Principal{Name: "anonymous", Scopes: []string{a.cfg.AnonymousScope}}, nil
+		}
+		return Principal{}, ErrNoCredentials
+	}
+	for _, k := range a.cfg.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(k.Key), []byte(credential)) == 1 {
+			return Principal{Name: k.Name, Scopes: k.Scopes}, nil
+		}
+	}
+	return a.parseJWT(credential)
+}
+
+func (a *Authenticator) clientCertificate(r *http.Request) (Principal, bool) { // клиентский сертификат из разрешенного списка CN
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return Principal{}, false // сертификата нет или он не проверен по client_ca_file
+	}
+	cn := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	if _, ok := a.clientCNs[cn]; !ok {
+		return Principal{}, false
+	}
+	return Principal{Name: "cert:" + cn, Scopes: []string{ScopePublic, ScopeInternal}}, true
+}
+
+type claims struct {
+	jwt.RegisteredClaims
+	Scope  string   `json:"scope"`  // scope через пробел (как в OAuth 2.0)
+	Scopes []string `json:"scopes"` // или списком
+}
+
+func (a *Authenticator) parseJWT(token string) (Principal, error) {
+	var methods []string
+	if a.cfg.JWT.HMACSecret != "" {
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+	if a.rsaKey != nil {
+		methods = append(methods, "RS256", "RS384", "RS512")
+	}
+	if len(methods) == 0 {
+		return Principal{}, ErrInvalidToken
+	}
+	opts := []jwt.ParserOption{jwt.WithValidMethods(methods), jwt.WithExpirationRequired()}
+	if a.cfg.JWT.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(a.cfg.JWT.Issuer))
+	}
+	if a.cfg.JWT.Audience != "" {
+		opts = append(opts, jwt.WithAudience(a.cfg.JWT.Audience))
+	}
+	var c claims
+	_, err := jwt.ParseWithClaims(token, &c, func(t *jwt.Token) (interface{}, error) {
+		switch t.Method.(type) { // ключ выбирается по алгоритму, указанному в токене, но только из разрешенных
+		case *jwt.SigningMethodHMAC:
+			return []byte(a.cfg.JWT.HMACSecret), nil
+		case *jwt.SigningMethodRSA:
+			return a.rsaKey, nil
+		}
+		return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+	}, opts...)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	p := Principal{Name: c.Subject, Scopes: c.Scopes}
+	if c.Scope != "" {
+		p.Scopes = append(p.Scopes, strings.Fields(c.Scope)...)
+	}
+	return p, nil
+}
+
+type contextKey struct{}
+
+// Middleware определяет клиента и кладет его в контекст запроса. Запросы без действительных учетных данных получают 401
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := a.Authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="statuspage"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, p)))
+	})
+}
+
+// FromContext возвращает клиента, определенного Middleware
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(Principal)
+	return p, ok
+}
+
+// RequireScope пропускает только запросы клиентов с указанным scope, остальным отвечает 403
+func RequireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := FromContext(r.Context())
+		if !ok || !p.HasScope(scope) {
+			http.Error(w, "auth: scope "+scope+" required", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,241 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestDisabledNeverGrantsInternal(t *testing.T) {
+	a, err := New(Config{APIKeys: []APIKey{{Name: "ops", Key: "secret", Scopes: []string{ScopeInternal}}}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := a.Authenticate(httptest.NewRequest("GET", "/", nil))
+	if err != nil || p.HasScope(ScopeInternal) || !p.HasScope(ScopePublic) || !p.HasScope(ScopeFull) {
+		t.Errorf("anonymous with auth disabled: got %+v, %v; want public and full", p, err)
+	}
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-API-Key", "secret")
+	if p, err := a.Authenticate(r); err != nil || !p.HasScope(ScopeInternal) {
+		t.Errorf("api key with auth disabled: got %+v, %v", p, err)
+	}
+	r.Header.Set("X-API-Key", "wrong")
+	if _, err := a.Authenticate(r); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("wrong key with auth disabled: got %v", err)
+	}
+	if _, err := New(Config{AnonymousScope: ScopeInternal}, nil); err == nil {
+		t.Error("anonymous_scope internal must be rejected")
+	}
+	if a.GeneratedKey() != "" {
+		t.Errorf("generated a key although %q has scope internal", "ops")
+	}
+}
+
+func TestGeneratedKey(t *testing.T) {
+	a, err := New(Config{APIKeys: []APIKey{{Name: "widget", Key: "widget-key", Scopes: []string{ScopePublic}}}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := a.GeneratedKey()
+	if len(key) < 32 {
+		t.Fatalf("no internal credential configured: generated key %q", key)
+	}
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-API-Key", key)
+	if p, err := a.Authenticate(r); err != nil || !p.HasScope(ScopeInternal) {
+		t.Errorf("generated key: got %+v, %v", p, err)
+	}
+	b, err := New(Config{Enabled: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.GeneratedKey() != key {
+		t.Error("config reload changed the generated key")
+	}
+	for name, c := range map[string]Config{
+		"hmac": {JWT: JWTConfig{HMACSecret: "secret"}},
+		"key":  {APIKeys: []APIKey{{Name: "ops", Key: "ops-key", Scopes: []string{ScopeInternal}}}},
+	} {
+		if a, err := New(c, nil); err != nil || a.GeneratedKey() != "" {
+			t.Errorf("%s: generated a key although internal is configured", name)
+		}
+	}
+	if a, err := New(Config{}, []string{"monitoring"}); err != nil || a.GeneratedKey() != "" {
+		t.Error("client CN: generated a key although internal is configured")
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	a, err := New(Config{Enabled: true, APIKeys: []APIKey{
+		{Name: "ops", Key: "ops-key", Scopes: []string{ScopePublic, ScopeInternal}},
+		{Name: "widget", Key: "widget-key", Scopes: []string{ScopePublic}},
+	}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		header, value string
+		name          string
+		internal      bool
+		err           error
+	}{
+		{"X-API-Key", "ops-key", "ops", true, nil},
+		{"Authorization", "Bearer widget-key", "widget", false, nil},
+		{"Authorization", "bearer  ops-key ", "ops", true, nil},
+		{"X-API-Key", "ops-key2", "", false, ErrInvalidToken},
+		{"Authorization", "Basic ops-key", "", false, ErrNoCredentials},
+		{"", "", "", false, ErrNoCredentials},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tc.header != "" {
+			r.Header.Set(tc.header, tc.value)
+		}
+		p, err := a.Authenticate(r)
+		if !errors.Is(err, tc.err) || p.Name != tc.name || p.HasScope(ScopeInternal) != tc.internal {
+			t.Errorf("%s: %q: got %+v, %v", tc.header, tc.value, p, err)
+		}
+	}
+	if _, err := New(Config{APIKeys: []APIKey{{Name: "empty"}}}, nil); err == nil {
+		t.Error("an empty api key must be rejected")
+	}
+}
+
+func TestAnonymousScope(t *testing.T) {
+	for _, scope := range []string{ScopePublic, ScopeFull} {
+		a, err := New(Config{Enabled: true, AnonymousScope: scope}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p, err := a.Authenticate(httptest.NewRequest("GET", "/", nil)); err != nil || len(p.Scopes) != 1 || !p.HasScope(scope) {
+			t.Errorf("%s: got %+v, %v; want %s only", scope, p, err, scope)
+		}
+	}
+}
+
+func TestClientCertificate(t *testing.T) {
+	a, err := New(Config{Enabled: true}, []string{"monitoring"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := func(cn string, verified bool) *http.Request {
+		r := httptest.NewRequest("GET", "/", nil)
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+		r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+		if verified {
+			r.TLS.VerifiedChains = [][]*x509.Certificate{{cert}}
+		}
+		return r
+	}
+	if p, err := a.Authenticate(request("monitoring", true)); err != nil || p.Name != "cert:monitoring" || !p.HasScope(ScopeInternal) {
+		t.Errorf("allowed CN: got %+v, %v", p, err)
+	}
+	if _, err := a.Authenticate(request("stranger", true)); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("unknown CN: got %v", err)
+	}
+	if _, err := a.Authenticate(request("monitoring", false)); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("unverified certificate: got %v", err)
+	}
+}
+
+func TestJWT(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	keyFile := filepath.Join(t.TempDir(), "jwt.pem")
+	if err := os.WriteFile(keyFile, pemData, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	a, err := New(Config{Enabled: true, JWT: JWTConfig{HMACSecret: "hmac-secret", RSAPublicKeyFile: keyFile, Issuer: "sso", Audience: "statuspage"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaOnly, err := New(Config{Enabled: true, JWT: JWTConfig{RSAPublicKeyFile: keyFile}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{"sub": "alice", "iss": "sso", "aud": "statuspage", "exp": time.Now().Add(time.Hour).Unix(), "scope": "public internal"}
+	}
+	with := func(name string, value interface{}) jwt.MapClaims {
+		c := valid()
+		if value == nil {
+			delete(c, name)
+		} else {
+			c[name] = value
+		}
+		return c
+	}
+	sign := func(method jwt.SigningMethod, c jwt.MapClaims, key interface{}) string {
+		s, err := jwt.NewWithClaims(method, c).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	for _, tc := range []struct {
+		name  string
+		a     *Authenticator
+		token string
+		ok    bool
+	}{
+		{"HS256", a, sign(jwt.SigningMethodHS256, valid(), []byte("hmac-secret")), true},
+		{"HS512", a, sign(jwt.SigningMethodHS512, valid(), []byte("hmac-secret")), true},
+		{"RS256", a, sign(jwt.SigningMethodRS256, valid(), key), true},
+		{"RS384 without issuer check", rsaOnly, sign(jwt.SigningMethodRS384, with("iss", "other"), key), true},
+		{"no scope claim", a, sign(jwt.SigningMethodHS256, with("scope", nil), []byte("hmac-secret")), true},
+		{"wrong secret", a, sign(jwt.SigningMethodHS256, valid(), []byte("other")), false},
+		{"wrong issuer", a, sign(jwt.SigningMethodHS256, with("iss", "other"), []byte("hmac-secret")), false},
+		{"no issuer", a, sign(jwt.SigningMethodHS256, with("iss", nil), []byte("hmac-secret")), false},
+		{"wrong audience", a, sign(jwt.SigningMethodHS256, with("aud", "billing"), []byte("hmac-secret")), false},
+		{"audience in a list", a, sign(jwt.SigningMethodHS256, with("aud", []string{"billing", "statuspage"}), []byte("hmac-secret")), true},
+		{"expired", a, sign(jwt.SigningMethodHS256, with("exp", time.Now().Add(-time.Minute).Unix()), []byte("hmac-secret")), false},
+		{"no expiration", a, sign(jwt.SigningMethodHS256, with("exp", nil), []byte("hmac-secret")), false},
+		{"alg none", a, sign(jwt.SigningMethodNone, valid(), jwt.UnsafeAllowNoneSignatureType), false},
+		{"HS256 without hmac_secret", rsaOnly, sign(jwt.SigningMethodHS256, valid(), pemData), false}, // подпись открытым ключом RSA как секретом
+		{"PS256 outside the allowed methods", a, sign(jwt.SigningMethodPS256, valid(), key), false},
+		{"not a token", a, "not.a.token", false},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", "Bearer "+tc.token)
+		p, err := tc.a.Authenticate(r)
+		switch {
+		case tc.ok && (err != nil || p.Name != "alice"):
+			t.Errorf("%s: got %+v, %v", tc.name, p, err)
+		case !tc.ok && !errors.Is(err, ErrInvalidToken):
+			t.Errorf("%s: got %+v, %v; want ErrInvalidToken", tc.name, p, err)
+		}
+	}
+
+	c := with("scope", nil)
+	c["scopes"] = []string{ScopeInternal}
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer "+sign(jwt.SigningMethodHS256, c, []byte("hmac-secret")))
+	if p, err := a.Authenticate(r); err != nil || !p.HasScope(ScopeInternal) || p.HasScope(ScopePublic) {
+		t.Errorf("scopes claim: got %+v, %v", p, err)
+	}
+	r.Header.Set("Authorization", "Bearer "+sign(jwt.SigningMethodHS256, valid(), []byte("hmac-secret")))
+	if p, err := a.Authenticate(r); err != nil || !p.HasScope(ScopeInternal) || !p.HasScope(ScopePublic) {
+		t.Errorf("scope claim: got %+v, %v", p, err)
+	}
+}
@@ -1,9 +1,10 @@
 package billing
 
 import (
-	"io"
-	"os"
+	"finalwork/internal/input"
+	"fmt"
 	"strconv"
+	"strings"
 )
 
 type BillingData struct {
@@ -15,20 +16,20 @@ type BillingData struct {
 	CheckoutPage   bool `json:"checkout_page"`
 }
 
-func GetBillingData(fileName string) (BillingData, error) { // функция сбора данных о системе Billing
+func GetBillingData(files input.Files, fileName string) (BillingData, error) { // функция сбора данных о системе Billing
 	var billingDataStruct BillingData
-	file, err := os.Open(fileName) // открываем файл
-	if err != nil {
-		return billingDataStruct, err
-	}
-	defer file.Close()
-	bytes, err := io.ReadAll(file) // читаем, получаем слайс байтов (используем вместо ioutil)
+	bytes, err := files.ReadFile(fileName) // читаем файл, получаем слайс байтов
 	if err != nil {
 		return billingDataStruct, err
 	}
 	//fmt.Println(string(bytes)) // печатает маску в виде строки
 
-	number, err := strconv.ParseInt(string(bytes), 2, 8) // интерпретируем строку в число
+	// маска - не больше 6 символов 0 и 1 (ParseInt принял бы и знак, и седьмой бит); перевод строки в конце файла допустим
+	mask := strings.TrimSpace(string(bytes))
+	if len(mask) == 0 || len(mask) > 6 || strings.Trim(mask, "01") != "" {
+		return billingDataStruct, fmt.Errorf("billing: invalid mask %q", mask)
+	}
+	number, err := strconv.ParseInt(mask, 2, 8) // интерпретируем строку в число
 	if err != nil {
 		return billingDataStruct, err
 	}
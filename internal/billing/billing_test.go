@@ -0,0 +1,30 @@
+package billing
+
+import (
+	"finalwork/internal/input/inputtest"
+	"strings"
+	"testing"
+)
+
+func FuzzGetBillingData(f *testing.F) {
+	for _, mask := range []string{"100100", "111111", "000000", "1", "111110\n", "-1", "+101", "1111111", "11111111", "10a101", ""} {
+		f.Add([]byte(mask))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		got, err := GetBillingData(inputtest.Bytes(data), "billing.data")
+		if err != nil {
+			return
+		}
+		mask := strings.TrimSpace(string(data)) // маска - от 1 до 6 символов 0 и 1, первым идет checkout_page
+		if len(mask) == 0 || len(mask) > 6 || strings.Trim(mask, "01") != "" {
+			t.Fatalf("accepted invalid mask %q as %+v", data, got)
+		}
+		flags := []bool{got.CreateCustomer, got.Purchase, got.Payout, got.Recurring, got.FraudControl, got.CheckoutPage}
+		for i, flag := range flags {
+			want := i < len(mask) && mask[len(mask)-1-i] == '1'
+			if flag != want {
+				t.Fatalf("mask %q: flag %d is %v, want %v", mask, i, flag, want)
+			}
+		}
+	})
+}
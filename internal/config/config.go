@@ -0,0 +1,172 @@
+package config
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"finalwork/internal/auth"
+	"finalwork/internal/cors"
+	"finalwork/internal/incident"
+	"finalwork/internal/notify"
+	"finalwork/internal/providers"
+	"finalwork/internal/ratelimit"
+	"finalwork/internal/rules"
+	"finalwork/internal/voicecall"
+)
+
+//go:embed default.json
+var defaultConfig []byte // настройки по умолчанию. Файл из флага -config накладывается поверх них
+
+type Config struct {
+	Sources      Sources                 `json:"sources"`       // файлы и адреса API систем
+	Providers    []providers.Provider    `json:"providers"`     // каталог допустимых провайдеров по каналам
+	VoiceQuality voicecall.QualityConfig `json:"voice_quality"` // веса и границы метрик для оценки качества звонков
+	Incidents    incident.Config         `json:"incidents"`     // правила определения системы, серьезности и стран инцидента по теме
+	StatusRules  []rules.Rule            `json:"status_rules"`  // правила вывода общего состояния и состояния систем из собранных данных
+	History      History                 `json:"history"`       // хранение снимков для карточек провайдеров
+	Archive      Archive                 `json:"archive"`       // запись входных данных каждого сбора для воспроизведения
+	Maintenance  Maintenance             `json:"maintenance"`   // плановые работы и ручные состояния систем
+	Notify       notify.Config           `json:"notifications"` // подписки на письма об инцидентах и плановых работах
+	Upstream     Upstream                `json:"upstream"`      // клиент для систем, данные которых получаются через API
+	Auth         auth.Config             `json:"auth"`          // API-ключи и JWT
+	RateLimit    ratelimit.Config        `json:"rate_limit"`    // ограничение частоты запросов по клиенту
+	Server       Server                  `json:"server"`        // адрес и TLS
+	CORS         cors.Config             `json:"cors"`          // запросы со страниц других источников (браузерные панели)
+}
+
+type Sources struct { // откуда берутся данные систем (по умолчанию - файлы и API симулятора)
+	SMSFile     string `json:"sms_file"`
+	VoiceFile   string `json:"voice_file"`
+	EmailFile   string `json:"email_file"`
+	BillingFile string `json:"billing_file"`
+	MMSURL      string `json:"mms_url"`
+	SupportURL  string `json:"support_url"`
+	IncidentURL string `json:"incident_url"`
+}
+
+type Server struct {
+	Addr string `json:"addr"` // адрес для прослушивания
+	TLS  TLS    `json:"tls"`
+}
+
+type TLS struct {
+	Enabled           bool     `json:"enabled"`
+	CertFile          string   `json:"cert_file"`           // PEM-файл сертификата сервера (с цепочкой)
+	KeyFile           string   `json:"key_file"`            // PEM-файл закрытого ключа
+	ClientCAFile      string   `json:"client_ca_file"`      // PEM-файл CA для проверки клиентских сертификатов. Пустая строка - без mTLS
+	RequireClientCert bool     `json:"require_client_cert"` // true - соединения без действительного клиентского сертификата отклоняются
+	AllowedCNs        []string `json:"allowed_cns"`         // CN клиентских сертификатов, получающих scope internal
+	ReloadInterval    Duration `json:"reload_interval"`     // период проверки изменения файлов. 0 - только по SIGHUP
+}
+
+type Upstream struct {
+	ConnectTimeout  Duration `json:"connect_timeout"`
+	ReadTimeout     Duration `json:"read_timeout"`
+	Retries         int      `json:"retries"`          // повторы после неудачной попытки (только для GET)
+	BackoffBase     Duration `json:"backoff_base"`     // пауза перед первым повтором, далее удваивается
+	BackoffMax      Duration `json:"backoff_max"`      // предел паузы
+	BreakerFailures int      `json:"breaker_failures"` // неудачных запросов подряд до отключения источника. 0 - не отключать
+	BreakerCooldown Duration `json:"breaker_cooldown"` // время отключения источника
+
+	MaxBodySize    int64             `json:"max_body_size"`   // предел размера ответа в байтах (в том числе после распаковки gzip)
+	ContentTypes   []string          `json:"content_types"`   // допустимые типы содержимого ответа
+	StrictDecoding bool              `json:"strict_decoding"` // true - неизвестные поля JSON считаются ошибкой
+	Headers        map[string]string `json:"headers"`         // заголовки, добавляемые к каждому запросу
+}
+
+type History struct {
+	Dir      string   `json:"dir"`      // директория для файлов со снимками
+	Interval Duration `json:"interval"` // период фонового сбора данных, снимок которого записывается в историю. 0 - история не пополняется
+}
+
+type Maintenance struct {
+	File string `json:"file"` // JSON-файл, в котором окна работ и ручные состояния хранятся между перезапусками
+}
+
+type Archive struct {
+	Enabled bool   `json:"enabled"`
+	Dir     string `json:"dir"`  // директория для архивов (файл на каждый сбор)
+	Keep    int    `json:"keep"` // сколько последних архивов хранить. 0 - все
+}
+
+type Duration struct { // time.Duration, который в JSON записывается строкой вида "5m" или "1h30m"
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"5m\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// Load читает настройки по умолчанию и, если fileName не пустой, накладывает на них настройки из файла.
+// Поля, отсутствующие в файле, сохраняют значения по умолчанию; списки заменяются целиком.
+func Load(fileName string) (Config, error) {
+	var cfg Config
+	if err := decode(defaultConfig, &cfg); err != nil {
+		return cfg, fmt.Errorf("config: default config: %w", err)
+	}
+	if fileName == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return cfg, fmt.Errorf("config: %w", err)
+	}
+	var sections map[string]json.RawMessage
+	if json.Unmarshal(data, &sections) == nil {
+		if _, ok := sections["providers"]; ok {
+			cfg.Providers = nil // элементы списка - структуры: без сброса json заполнил бы их поверх провайдеров по умолчанию (с их псевдонимами)
+		}
+		if _, ok := sections["status_rules"]; ok {
+			cfg.StatusRules = nil
+		}
+		var incidents map[string]json.RawMessage
+		if json.Unmarshal(sections["incidents"], &incidents) == nil {
+			if _, ok := incidents["rules"]; ok {
+				cfg.Incidents.Rules = nil // то же для правил: поля, не указанные в правиле из файла, не должны браться из правила по умолчанию
+			}
+		}
+	}
+	if err := decode(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("config: %s: %w", fileName, err)
+	}
+	if err := cfg.Server.TLS.validate(); err != nil {
+		return cfg, fmt.Errorf("config: %w", err)
+	}
+	return cfg, nil
+}
+
+func (t TLS) validate() error {
+	if !t.Enabled {
+		return nil
+	}
+	if t.CertFile == "" || t.KeyFile == "" {
+		return fmt.Errorf("tls: cert_file and key_file are required")
+	}
+	if (t.RequireClientCert || len(t.AllowedCNs) > 0) && t.ClientCAFile == "" {
+		return fmt.Errorf("tls: client_ca_file is required to verify client certificates")
+	}
+	return nil
+}
+
+func decode(data []byte, cfg *Config) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields() // опечатка в имени параметра не должна молча игнорироваться
+	return dec.Decode(cfg)
+}
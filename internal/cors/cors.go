@@ -0,0 +1,129 @@
+package cors
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type Config struct {
+	Enabled          bool     `json:"enabled"`
+	AllowedOrigins   []string `json:"allowed_origins"`   // источники вида "https://dash.example.com". "*" - любой источник
+	AllowedMethods   []string `json:"allowed_methods"`   // методы, разрешенные в предварительных запросах
+	AllowedHeaders   []string `json:"allowed_headers"`   // заголовки запроса, разрешенные в предварительных запросах
+	ExposedHeaders   []string `json:"exposed_headers"`   // заголовки ответа, доступные скриптам браузера
+	AllowCredentials bool     `json:"allow_credentials"` // разрешить запросы с cookie и заголовком Authorization от браузера
+	MaxAge           int      `json:"max_age"`           // сколько секунд браузер может кешировать ответ на предварительный запрос. 0 - не кешировать
+}
+
+// Policy - проверка источника и заголовки CORS по настройкам
+type Policy struct {
+	cfg     Config
+	any     bool                // разрешен любой источник
+	origins map[string]struct{} // разрешенные источники в нижнем регистре
+}
+
+func New(cfg Config) *Policy {
+	p := &Policy{cfg: cfg, origins: make(map[string]struct{})}
+	for _, o := range cfg.AllowedOrigins {
+		if o == "*" {
+			p.any = true
+			continue
+		}
+		p.origins[strings.ToLower(strings.TrimSuffix(o, "/"))] = struct{}{}
+	}
+	return p
+}
+
+// Allowed сообщает, разрешены ли запросы со страниц источника origin
+func (p *Policy) Allowed(origin string) bool {
+	if p.any {
+		return true
+	}
+	_, ok := p.origins[strings.ToLower(origin)]
+	return ok
+}
+
+// Handler добавляет заголовки CORS к ответам next и сам отвечает на предварительные запросы (OPTIONS).
+// Оборачивает роутер целиком, чтобы предварительные запросы не доходили до проверки учетных данных и ограничений методов
+func (p *Policy) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !p.cfg.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+		origin := r.Header.Get("Origin")
+		w.Header().Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if origin == "" || !p.Allowed(origin) {
+			if preflight { // без заголовков CORS браузер не выполнит основной запрос
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		p.setOrigin(w, origin)
+		if !preflight {
+			if len(p.cfg.ExposedHeaders) > 0 {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(p.cfg.ExposedHeaders, ", "))
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		if !p.methodAllowed(r.Header.Get("Access-Control-Request-Method")) || !p.headersAllowed(r.Header.Get("Access-Control-Request-Headers")) {
+			w.WriteHeader(http.StatusNoContent) // без Allow-Methods/Allow-Headers браузер отклонит запрос сам
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(p.cfg.AllowedMethods, ", "))
+		if len(p.cfg.AllowedHeaders) > 0 {
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(p.cfg.AllowedHeaders, ", "))
+		}
+		if p.cfg.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(p.cfg.MaxAge))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (p *Policy) setOrigin(w http.ResponseWriter, origin string) {
+	if p.any && !p.cfg.AllowCredentials { // "*" нельзя сочетать с учетными данными, тогда возвращаем сам источник
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.cfg.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (p *Policy) methodAllowed(method string) bool {
+	for _, m := range p.cfg.AllowedMethods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) headersAllowed(requested string) bool { // все запрошенные заголовки должны быть в списке (без учета регистра)
+	for _, h := range strings.Split(requested, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		ok := false
+		for _, allowed := range p.cfg.AllowedHeaders {
+			if strings.EqualFold(allowed, h) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
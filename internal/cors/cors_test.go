@@ -0,0 +1,117 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var testConfig = Config{
+	Enabled:        true,
+	AllowedOrigins: []string{"https://dash.example.com/"},
+	AllowedMethods: []string{"GET", "POST"},
+	AllowedHeaders: []string{"Authorization", "Content-Type"},
+	ExposedHeaders: []string{"ETag"},
+	MaxAge:         600,
+}
+
+// serve выполняет запрос через Handler и сообщает, дошел ли он до обработчика
+func serve(p *Policy, method, origin string, header map[string]string) (*httptest.ResponseRecorder, bool) {
+	reached := false
+	h := p.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusOK)
+	}))
+	r := httptest.NewRequest(method, "/api", nil)
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec, reached
+}
+
+func TestPreflight(t *testing.T) {
+	p := New(testConfig)
+	rec, reached := serve(p, "OPTIONS", "https://Dash.Example.com", map[string]string{
+		"Access-Control-Request-Method":  "post",
+		"Access-Control-Request-Headers": "authorization, content-type",
+	})
+	if reached || rec.Code != http.StatusNoContent {
+		t.Fatalf("preflight reached the handler or got %d", rec.Code)
+	}
+	for k, want := range map[string]string{
+		"Access-Control-Allow-Origin":  "https://Dash.Example.com",
+		"Access-Control-Allow-Methods": "GET, POST",
+		"Access-Control-Allow-Headers": "Authorization, Content-Type",
+		"Access-Control-Max-Age":       "600",
+	} {
+		if got := rec.Header().Get(k); got != want {
+			t.Errorf("%s: got %q, want %q", k, got, want)
+		}
+	}
+	if got := rec.Header().Values("Vary"); len(got) != 3 {
+		t.Errorf("Vary: got %q", got)
+	}
+
+	for name, header := range map[string]map[string]string{ // запрещенные метод или заголовок: ответ без Allow-Methods
+		"method": {"Access-Control-Request-Method": "DELETE"},
+		"header": {"Access-Control-Request-Method": "GET", "Access-Control-Request-Headers": "Content-Type, X-Debug"},
+	} {
+		rec, reached := serve(p, "OPTIONS", "https://dash.example.com", header)
+		if reached || rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Methods") != "" {
+			t.Errorf("disallowed %s: reached %v, code %d, headers %v", name, reached, rec.Code, rec.Header())
+		}
+	}
+
+	rec, reached = serve(p, "OPTIONS", "https://dash.example.com", nil) // OPTIONS без Access-Control-Request-Method - обычный запрос
+	if !reached || rec.Header().Get("Access-Control-Allow-Methods") != "" {
+		t.Errorf("plain OPTIONS: reached %v, headers %v", reached, rec.Header())
+	}
+}
+
+func TestOriginRejected(t *testing.T) {
+	p := New(testConfig)
+	for _, origin := range []string{"https://evil.example.com", "http://dash.example.com", "https://dash.example.com.evil.net", ""} {
+		rec, reached := serve(p, "GET", origin, nil)
+		if !reached || rec.Header().Get("Access-Control-Allow-Origin") != "" || rec.Header().Get("Access-Control-Expose-Headers") != "" {
+			t.Errorf("%q: reached %v, headers %v", origin, reached, rec.Header())
+		}
+		rec, reached = serve(p, "OPTIONS", origin, map[string]string{"Access-Control-Request-Method": "GET"})
+		if reached || rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("%q preflight: reached %v, code %d, headers %v", origin, reached, rec.Code, rec.Header())
+		}
+	}
+
+	rec, reached := serve(p, "GET", "https://dash.example.com", nil)
+	if !reached || rec.Header().Get("Access-Control-Allow-Origin") != "https://dash.example.com" || rec.Header().Get("Access-Control-Expose-Headers") != "ETag" {
+		t.Errorf("allowed origin: reached %v, headers %v", reached, rec.Header())
+	}
+}
+
+func TestAnyOrigin(t *testing.T) {
+	c := testConfig
+	c.AllowedOrigins = []string{"*"}
+	rec, _ := serve(New(c), "GET", "https://anywhere.example.org", nil)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("without credentials: got %q, want *", got)
+	}
+
+	c.AllowCredentials = true // с учетными данными "*" недопустим, возвращается сам источник
+	rec, _ = serve(New(c), "GET", "https://anywhere.example.org", nil)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://anywhere.example.org" || rec.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Errorf("with credentials: got %q, headers %v", got, rec.Header())
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	c := testConfig
+	c.Enabled = false
+	rec, reached := serve(New(c), "OPTIONS", "https://dash.example.com", map[string]string{"Access-Control-Request-Method": "GET"})
+	if !reached || len(rec.Header()) != 0 {
+		t.Errorf("disabled policy: reached %v, headers %v", reached, rec.Header())
+	}
+}
@@ -1,55 +1,155 @@
 package countries
 
 import (
+	_ "embed"
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 )
 
+//go:embed ISOCountries.csv
+var embeddedCountries []byte // список стран, вшитый в бинарный файл (используется, если файл переопределения не задан)
+
 type Code string
 
-type country struct {
+type Country struct {
 	Name   string
 	Alpha2 string
 }
 
 type CountryRepository struct {
-	CountryByCode map[Code]*country
+	*countryStore // общее хранилище. Обёртки (SmsCountryRepository и др.) копируют указатель, поэтому видят результат Reload
+}
+
+type countryStore struct {
+	mu            sync.RWMutex
+	overrideFile  string // путь к файлу переопределения. Пустая строка - используются вшитые данные
+	fixed         []byte // данные, заданные при создании (RepositoryFromData). Не nil - файл и вшитые данные не читаются
+	data          []byte // CSV, из которого построено текущее содержимое
+	countryByCode map[Code]*Country
 }
 
-func ISOCountryRepository() CountryRepository {
-	fileName := "internal/countries/ISOCountries.csv"
-	countryMap := CountryRepository{
-		CountryByCode: make(map[Code]*country),
+// ISOCountryRepository создает хранилище стран. Если overrideFile не пустой, данные берутся из него, иначе из вшитого CSV.
+func ISOCountryRepository(overrideFile string) (CountryRepository, error) {
+	repo := CountryRepository{
+		countryStore: &countryStore{overrideFile: overrideFile},
+	}
+	if err := repo.Reload(); err != nil {
+		return repo, err
 	}
-	countryMap.fileDataTake(fileName)
-	return countryMap
+	return repo, nil
 }
 
-func (r *CountryRepository) fileDataTake(fileName string) {
-	fileString := string(openFile(fileName))
-	fileStringSlice := strings.Split(fileString, "\n")
-	for _, v := range fileStringSlice {
-		var country country
-		workString := strings.TrimSpace(v)
-		workStringSplit := strings.Split(workString, ";")
-		country.Name = workStringSplit[0]
-		country.Alpha2 = workStringSplit[1]
-		r.CountryByCode[Code(country.Alpha2)] = &country
+// RepositoryFromData создает хранилище стран из CSV вида "Название;alpha2" (например, записанного в архиве сбора)
+func RepositoryFromData(data []byte) (CountryRepository, error) {
+	repo := CountryRepository{
+		countryStore: &countryStore{fixed: data},
 	}
+	if err := repo.Reload(); err != nil {
+		return repo, err
+	}
+	return repo, nil
 }
 
-func openFile(fileName string) []byte {
-	file, err := os.Open(fileName)
+// Reload перечитывает данные стран и атомарно подменяет содержимое хранилища. При ошибке старые данные сохраняются.
+func (s *countryStore) Reload() error {
+	data := embeddedCountries
+	if s.fixed != nil {
+		data = s.fixed
+	} else if s.overrideFile != "" {
+		fileData, err := os.ReadFile(s.overrideFile)
+		if err != nil {
+			return fmt.Errorf("countries: reading %s: %w", s.overrideFile, err)
+		}
+		data = fileData
+	}
+	countryByCode, err := fileDataTake(data)
 	if err != nil {
-		panic(err)
+		return err
+	}
+	s.mu.Lock()
+	s.data = data
+	s.countryByCode = countryByCode
+	s.mu.Unlock()
+	return nil
+}
+
+// Data возвращает CSV, из которого построено текущее содержимое хранилища
+func (s *countryStore) Data() []byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.data
+}
+
+// Lookup возвращает страну по коду alpha-2
+func (s *countryStore) Lookup(code Code) (Country, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.countryByCode[code]
+	if !ok {
+		return Country{}, false
+	}
+	return *c, true
+}
+
+// NameByCode возвращает название страны по коду alpha-2 или пустую строку, если код неизвестен
+func (s *countryStore) NameByCode(code Code) string {
+	c, _ := s.Lookup(code)
+	return c.Name
+}
+
+// Len возвращает количество стран в хранилище
+func (s *countryStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.countryByCode)
+}
+
+func fileDataTake(data []byte) (map[Code]*Country, error) { // разбор CSV вида "Название;alpha2"
+	list, err := parseCountries(data)
+	if err != nil {
+		return nil, err
+	}
+	countryByCode := make(map[Code]*Country, len(list))
+	for i := range list {
+		countryByCode[Code(list[i].Alpha2)] = &list[i]
+	}
+	return countryByCode, nil
+}
+
+// Embedded возвращает вшитый список стран в порядке файла (например, для генерации тестовых данных симулятором)
+func Embedded() []Country {
+	list, err := parseCountries(embeddedCountries)
+	if err != nil {
+		panic(err) // вшитый файл проверяется тестами
+	}
+	return list
+}
+
+func parseCountries(data []byte) ([]Country, error) { // строки CSV в порядке файла
+	var list []Country
+	fileStringSlice := strings.Split(string(data), "\n")
+	for i, v := range fileStringSlice {
+		workString := strings.TrimSpace(v)
+		if workString == "" { // пустые строки пропускаем
+			continue
+		}
+		workStringSplit := strings.Split(workString, ";")
+		if len(workStringSplit) < 2 {
+			return nil, fmt.Errorf("countries: line %d: expected \"name;alpha2\", got %q", i+1, workString)
+		}
+		country := Country{
+			Name:   strings.TrimSpace(workStringSplit[0]),
+			Alpha2: strings.TrimSpace(workStringSplit[1]),
+		}
+		if country.Name == "" || country.Alpha2 == "" {
+			return nil, fmt.Errorf("countries: line %d: empty name or code in %q", i+1, workString)
+		}
+		list = append(list, country)
 	}
-	defer file.Close()
-	fi, _ := file.Stat()
-	buf := make([]byte, fi.Size())
-	if _, err = file.Read(buf); err != nil {
-		fmt.Println("File reading error:", err)
-		panic(err)
+	if len(list) == 0 {
+		return nil, fmt.Errorf("countries: no countries found")
 	}
-	return buf
+	return list, nil
 }
@@ -0,0 +1,46 @@
+package countries
+
+import (
+	"strings"
+	"testing"
+)
+
+func FuzzFileDataTake(f *testing.F) {
+	f.Add(embeddedCountries)
+	for _, data := range []string{"Russia;RU\nUnited States;US\n", "\n\n", "Russia", ";RU", "Russia;", " Russia ; RU \r\n", "Åland Islands;AX"} {
+		f.Add([]byte(data))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		byCode, err := fileDataTake(data)
+		if err != nil {
+			return
+		}
+		if len(byCode) == 0 {
+			t.Fatal("no error for a list without countries")
+		}
+		for code, c := range byCode {
+			if string(code) != c.Alpha2 {
+				t.Fatalf("country %+v stored under code %q", c, code)
+			}
+			if c.Name == "" || c.Alpha2 == "" || c.Name != strings.TrimSpace(c.Name) || c.Alpha2 != strings.TrimSpace(c.Alpha2) {
+				t.Fatalf("invalid country %+v", c)
+			}
+		}
+	})
+}
+
+func TestEmbedded(t *testing.T) {
+	list := Embedded()
+	repo, err := ISOCountryRepository("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != repo.Len() || list[0].Alpha2 == "" {
+		t.Fatalf("embedded list: %d countries, repository: %d", len(list), repo.Len())
+	}
+	for _, c := range list {
+		if got, ok := repo.Lookup(Code(c.Alpha2)); !ok || got != c {
+			t.Errorf("%+v: repository has %+v", c, got)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package countries
+
+import (
+	_ "embed"
+	"encoding/json"
+	"strings"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+//go:embed countries.json
+var embeddedCountryInfo []byte // справочник стран: локали, языки, alpha3 и т.д.
+
+//go:embed translations.json
+var embeddedTranslations []byte // названия стран на поддерживаемых языках: язык -> alpha2 -> название (сгенерированы из CLDR)
+
+type countryInfo struct {
+	Alpha2        string   `json:"alpha2"`
+	Alpha3        string   `json:"alpha3"`
+	Locales       []string `json:"locales"`
+	DefaultLocale string   `json:"default_locale"`
+	Languages     []string `json:"languages"`
+}
+
+// Locale - язык отображения названий стран и правила сортировки для него
+type Locale struct {
+	Tag  language.Tag // полный тег (например es-MX), по нему выбираются правила сортировки
+	Lang string       // базовый язык (en, ru, fr, es), по нему выбираются названия из таблицы переводов
+}
+
+var (
+	translations  map[string]map[Code]string // язык -> alpha2 -> название
+	supportedTags []language.Tag             // теги, которые умеем отдавать. Первый - язык по умолчанию
+	matcher       language.Matcher
+)
+
+var DefaultLocale = Locale{Tag: language.English, Lang: "en"} // английские названия из ISOCountries.csv
+
+func init() {
+	if err := json.Unmarshal(embeddedTranslations, &translations); err != nil {
+		panic("countries: broken embedded translations.json: " + err.Error()) // вшитые данные проверяются при сборке, ошибка здесь - ошибка разработчика
+	}
+	var info []countryInfo
+	if err := json.Unmarshal(embeddedCountryInfo, &info); err != nil {
+		panic("countries: broken embedded countries.json: " + err.Error())
+	}
+	supportedTags = []language.Tag{language.English}
+	seen := map[string]struct{}{"en": {}}
+	addTag := func(s string) { // добавляем тег, если для его языка есть переводы (или это английский)
+		tag, err := language.Parse(strings.ReplaceAll(s, "_", "-")) // в countries.json локали записаны как ru_RU
+		if err != nil {
+			return
+		}
+		base, _ := tag.Base()
+		if _, ok := translations[base.String()]; !ok && base.String() != "en" {
+			return
+		}
+		if _, ok := seen[tag.String()]; ok {
+			return
+		}
+		seen[tag.String()] = struct{}{}
+		supportedTags = append(supportedTags, tag)
+	}
+	for lang := range translations {
+		addTag(lang)
+	}
+	for _, c := range info {
+		for _, l := range c.Languages {
+			addTag(l)
+		}
+		for _, l := range c.Locales {
+			addTag(l)
+		}
+	}
+	matcher = language.NewMatcher(supportedTags)
+}
+
+// MatchLocale выбирает локаль по параметру lang= (имеет приоритет) или заголовку Accept-Language
+func MatchLocale(langParam, acceptLanguage string) Locale {
+	var tags []language.Tag
+	if langParam != "" {
+		if tag, err := language.Parse(strings.ReplaceAll(langParam, "_", "-")); err == nil {
+			tags = append(tags, tag)
+		}
+	}
+	if acceptLanguage != "" {
+		if accepted, _, err := language.ParseAcceptLanguage(acceptLanguage); err == nil {
+			tags = append(tags, accepted...)
+		}
+	}
+	if len(tags) == 0 {
+		return DefaultLocale
+	}
+	_, idx, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return DefaultLocale
+	}
+	tag := supportedTags[idx]
+	base, _ := tag.Base()
+	return Locale{Tag: tag, Lang: base.String()}
+}
+
+// Collator создает объект сравнения строк по правилам локали. Collator не потокобезопасен, поэтому создается на каждый запрос
+func (l Locale) Collator() *collate.Collator {
+	return collate.New(l.Tag)
+}
+
+// NameIn возвращает название страны на языке локали. Если перевода нет, возвращается английское название
+func (s *countryStore) NameIn(code Code, loc Locale) string {
+	if names, ok := translations[loc.Lang]; ok {
+		if name, ok := names[code]; ok {
+			return name
+		}
+	}
+	return s.NameByCode(code)
+}
@@ -0,0 +1,68 @@
+package countries
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestMatchLocale(t *testing.T) {
+	for _, tc := range []struct {
+		param, accept string
+		tag, lang     string
+	}{
+		{"", "", "en", "en"},
+		{"ru", "fr", "ru", "ru"},               // lang= важнее заголовка
+		{"", "fr-CH, ru;q=0.5", "fr-CH", "fr"}, // регион сохраняется в теге, названия - по базовому языку
+		{"es_MX", "", "es-MX", "es"},           // локаль в виде es_MX, как в countries.json
+		{"zz", "de, ru;q=0.8", "ru", "ru"},     // неизвестные языки пропускаются
+		{"de", "", "en", "en"},                 // переводов нет - язык по умолчанию
+		{"xx", "", "en", "en"},
+		{"", "garbage;;", "en", "en"},
+	} {
+		got := MatchLocale(tc.param, tc.accept)
+		if got.Tag.String() != tc.tag || got.Lang != tc.lang {
+			t.Errorf("MatchLocale(%q, %q) = %v %s, want %s %s", tc.param, tc.accept, got.Tag, got.Lang, tc.tag, tc.lang)
+		}
+	}
+}
+
+func TestNameIn(t *testing.T) {
+	repo, err := ISOCountryRepository("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		code Code
+		loc  Locale
+		want string
+	}{
+		{"RU", MatchLocale("ru", ""), "Россия"},
+		{"BE", MatchLocale("es-MX", ""), "Bélgica"},
+		{"BE", DefaultLocale, repo.NameByCode("BE")},
+		{"BE", Locale{Lang: "de"}, repo.NameByCode("BE")}, // нет переводов на язык - английское название
+	} {
+		if got := repo.NameIn(tc.code, tc.loc); got != tc.want {
+			t.Errorf("NameIn(%s, %s) = %q, want %q", tc.code, tc.loc.Lang, got, tc.want)
+		}
+	}
+}
+
+func TestCollation(t *testing.T) {
+	for _, tc := range []struct {
+		lang  string
+		names []string
+		want  []string
+	}{
+		{"es", []string{"Benín", "Bélgica", "Belice"}, []string{"Bélgica", "Belice", "Benín"}}, // побайтово Bélgica оказалась бы последней
+		{"es", []string{"oso", "ñu", "nube"}, []string{"nube", "ñu", "oso"}},                   // ñ - отдельная буква между n и o
+		{"ru", []string{"жук", "ёлка", "дом"}, []string{"дом", "ёлка", "жук"}},                 // ё сортируется вместе с е, а не после я
+	} {
+		col := MatchLocale(tc.lang, "").Collator()
+		got := append([]string(nil), tc.names...)
+		sort.Slice(got, func(i, j int) bool { return col.CompareString(got[i], got[j]) < 0 })
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.lang, got, tc.want)
+		}
+	}
+}
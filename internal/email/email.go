@@ -2,12 +2,10 @@ package email
 
 import (
 	"finalwork/internal/countries"
-	"io"
+	"finalwork/internal/input"
+	"finalwork/internal/providers"
 	"strconv"
 	"strings"
-
-	"log"
-	"os"
 )
 
 type EmailData struct {
@@ -18,32 +16,11 @@ type EmailData struct {
 
 type EmailCountryRepository countries.CountryRepository // локальная обёртка над типом countries.CountryRepository
 
-var validProviders = map[string]struct{}{ // создадим мап с ключами, которые соответствуют названиям допустимых провайдеров
-	"Gmail":       {},
-	"Yahoo":       {},
-	"Hotmail":     {},
-	"MSN":         {},
-	"Orange":      {},
-	"Comcast":     {},
-	"AOL":         {},
-	"Live":        {},
-	"RediffMail":  {},
-	"GMX":         {},
-	"Proton Mail": {},
-	"Yandex":      {},
-	"Mail.ru":     {},
-}
-
-func (r *EmailCountryRepository) GetEmailData(fileName string) ([]EmailData, error) { // функция сбора данных о системе Email
+func (r *EmailCountryRepository) GetEmailData(files input.Files, fileName string, catalog *providers.Catalog, stats *providers.RowStats) ([]EmailData, error) { // функция сбора данных о системе Email
 	var emailDataSlice []EmailData
-	file, err := os.Open(fileName) // открываем файл
-	if err != nil {
-		log.Panic(err)
-	}
-	defer file.Close()
-	bytes, err := io.ReadAll(file) // читаем, получаем слайс байтов (используем вместо ioutil)
+	bytes, err := files.ReadFile(fileName) // читаем файл, получаем слайс байтов
 	if err != nil {
-		log.Panic(err)
+		return emailDataSlice, err
 	}
 	sep := "\n"                                      // создаём сепаратор
 	stringSplit := strings.Split(string(bytes), sep) // разделяем весь текст на слайс подстрок по "\n"
@@ -51,11 +28,15 @@ func (r *EmailCountryRepository) GetEmailData(fileName string) ([]EmailData, err
 		sep := ";"                                 // создаём сепаратор
 		singleStringSlice := strings.Split(v, sep) // разделяем подстроку по разделителю ";"
 		if len(singleStringSlice) < 3 {            // проверяем, что кол-во элементов не меньше 3
+			stats.RejectMalformed(v)
 			continue
 		} else {
-			emailDataStruct, ok := r.parseStringleSlice(singleStringSlice) // парсинг в структуру и проверка требованиям
-			if ok {                                                        //если true
+			emailDataStruct, ok := r.parseStringleSlice(singleStringSlice, catalog) // парсинг в структуру и проверка требованиям
+			if ok {                                                                 //если true
 				emailDataSlice = append(emailDataSlice, emailDataStruct) // добавление структуры в результирующий срез
+				stats.Accept(emailDataStruct.Provider)
+			} else {
+				stats.Reject(catalog, singleStringSlice[1])
 			}
 		}
 	}
@@ -63,19 +44,23 @@ func (r *EmailCountryRepository) GetEmailData(fileName string) ([]EmailData, err
 }
 
 // функция создания структуры из слайса строк и проверки поля Country по коду alpha-2
-func (r *EmailCountryRepository) parseStringleSlice(s []string) (EmailData, bool) {
+func (r *EmailCountryRepository) parseStringleSlice(s []string, catalog *providers.Catalog) (EmailData, bool) {
 	var emailDataStruct EmailData
+	if len(s) < 3 { // строка без времени доставки
+		return emailDataStruct, false
+	}
 	emailDataStruct.Country = s[0]
 	emailDataStruct.Provider = s[1]
 	eDt, err := strconv.Atoi(s[2]) // конвертируем строку в int
-	if err != nil {
+	if err != nil || eDt < 0 {     // время доставки не бывает отрицательным
 		return emailDataStruct, false
 	}
 	emailDataStruct.DeliveryTime = eDt // присваиваем значение числовому полю структуры EmailData
 
-	if _, ok := r.CountryByCode[countries.Code(emailDataStruct.Country)]; ok { // проверяем по alpha-2, обращаясь к хранилищу emailCountryRepository по ключу = значению поля Country у полученной структуры
-		if _, ok := validProviders[emailDataStruct.Provider]; ok { // проверяем провайдера, обращаясь к мап по ключу = значению поля Provider у полученной структуры
-			return emailDataStruct, true // если значения по ключам в мап имеются, возвращаем структуру и true
+	if _, ok := r.Lookup(countries.Code(emailDataStruct.Country)); ok { // проверяем по alpha-2, обращаясь к хранилищу emailCountryRepository по ключу = значению поля Country у полученной структуры
+		if name, ok := catalog.Canonical(providers.Email, emailDataStruct.Provider); ok { // проверяем провайдера по каталогу (с учетом псевдонимов и без учета регистра)
+			emailDataStruct.Provider = name // приводим имя к каноническому
+			return emailDataStruct, true    // если страна и провайдер допустимы, возвращаем структуру и true
 		}
 	}
 	return emailDataStruct, false // возвращаем структуру и false
@@ -0,0 +1,102 @@
+package email
+
+import (
+	"finalwork/internal/countries"
+	"finalwork/internal/input/inputtest"
+	"finalwork/internal/providers"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testProviders = []providers.Provider{
+	{Name: "Gmail", Channels: []providers.Channel{providers.Email}},
+	{Name: "Yahoo", Channels: []providers.Channel{providers.Email}},
+	{Name: "Proton Mail", Aliases: []string{"Protonmail"}, Channels: []providers.Channel{providers.Email}},
+}
+
+func TestParseStringleSlice(t *testing.T) {
+	repo, catalog := inputtest.Setup(t, testProviders...)
+	r := EmailCountryRepository(repo)
+	for _, tc := range []struct {
+		line string
+		want EmailData
+		ok   bool
+	}{
+		{"RU;Gmail;195", EmailData{Country: "RU", Provider: "Gmail", DeliveryTime: 195}, true},
+		{"US;protonmail;0", EmailData{Country: "US", Provider: "Proton Mail", DeliveryTime: 0}, true},
+		{"RU;Gmail;-1", EmailData{}, false}, // время доставки не бывает отрицательным
+		{"RU;Gmail;fast", EmailData{}, false},
+		{"XX;Gmail;1", EmailData{}, false},
+		{"RU;Topolo;1", EmailData{}, false},
+		{"RU;Yahoo", EmailData{}, false},
+		{"", EmailData{}, false},
+	} {
+		row, ok := r.parseStringleSlice(strings.Split(tc.line, ";"), catalog)
+		if ok != tc.ok || ok && row != tc.want {
+			t.Errorf("%q: got %+v, %v, want %+v, %v", tc.line, row, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestGetEmailData(t *testing.T) {
+	repo, catalog := inputtest.Setup(t, testProviders...)
+	r := EmailCountryRepository(repo)
+	for _, tc := range []struct {
+		data  string
+		rows  []EmailData
+		stats *providers.RowStats
+	}{
+		{
+			"RU;Gmail;195\nBL;Yndex;423\nRU;Yahoo;-5\nESYahoo122\n\n",
+			[]EmailData{{Country: "RU", Provider: "Gmail", DeliveryTime: 195}},
+			&providers.RowStats{Accepted: map[string]int{"Gmail": 1}, Rejected: map[string]int{"Yahoo": 1}, Unattributed: 2},
+		},
+		{"", nil, &providers.RowStats{Accepted: map[string]int{}, Rejected: map[string]int{}}},
+	} {
+		stats := providers.NewRowStats()
+		rows, err := r.GetEmailData(inputtest.Bytes(tc.data), "email.data", catalog, stats)
+		if err != nil || !reflect.DeepEqual(rows, tc.rows) || !reflect.DeepEqual(stats, tc.stats) {
+			t.Errorf("%q: got %+v, %+v, %v, want %+v, %+v", tc.data, rows, stats, err, tc.rows, tc.stats)
+		}
+	}
+}
+
+// checkRow проверяет строку, которую разбор признал допустимой
+func checkRow(t *testing.T, r EmailCountryRepository, catalog *providers.Catalog, row EmailData) {
+	t.Helper()
+	inputtest.CheckRow(t, countries.CountryRepository(r), catalog, providers.Email, row.Country, row.Provider)
+	if row.DeliveryTime < 0 {
+		t.Fatalf("accepted negative delivery time in %+v", row)
+	}
+}
+
+func FuzzParseStringleSlice(f *testing.F) {
+	for _, line := range []string{"RU;Gmail;195", "US;Protonmail;0", "CA;#?;381", "ESYahoo122", ";;", "RU;Yahoo"} {
+		f.Add(line)
+	}
+	repo, catalog := inputtest.Setup(f, testProviders...)
+	r := EmailCountryRepository(repo)
+	f.Fuzz(func(t *testing.T, line string) {
+		row, ok := r.parseStringleSlice(strings.Split(line, ";"), catalog)
+		if ok {
+			checkRow(t, r, catalog, row)
+		}
+	})
+}
+
+func FuzzGetEmailData(f *testing.F) {
+	f.Add([]byte("RU;Gmail;195\nBL;Yndex;423\nCA;#?;381\nESYahoo122\n"))
+	f.Add([]byte(""))
+	repo, catalog := inputtest.Setup(f, testProviders...)
+	r := EmailCountryRepository(repo)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		rows, err := r.GetEmailData(inputtest.Bytes(data), "email.data", catalog, providers.NewRowStats())
+		if err != nil {
+			t.Fatalf("error for file contents: %v", err)
+		}
+		for _, row := range rows {
+			checkRow(t, r, catalog, row)
+		}
+	})
+}
@@ -0,0 +1,203 @@
+package fetch
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"finalwork/internal/upstream"
+)
+
+type Options struct {
+	MaxBodySize  int64       // предел размера тела ответа (и после распаковки gzip). 0 - без ограничения
+	ContentTypes []string    // допустимые типы содержимого (без параметров). Пустой список - любой тип
+	Strict       bool        // true - неизвестные поля в JSON считаются ошибкой, false - только попадают в отчет
+	Header       http.Header // дополнительные заголовки запроса
+}
+
+type ContentTypeError struct { // источник вернул недопустимый тип содержимого
+	URL         string
+	ContentType string
+}
+
+func (e *ContentTypeError) Error() string {
+	return fmt.Sprintf("fetch: %s: unexpected content type %q", e.URL, e.ContentType)
+}
+
+type DecodeError struct { // тело ответа не удалось разобрать
+	URL string
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("fetch: %s: %v", e.URL, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+type Result[T any] struct {
+	Items         []T      // элементы, прошедшие проверку
+	Rejected      int      // элементы, не прошедшие проверку
+	StatusCode    int      // код ответа источника (0, если ответа не было)
+	UnknownFields []string // поля JSON, которых нет в типе T
+	Stale         bool     // источник отключен, использован последний успешный ответ
+}
+
+// Getter выполняет GET-запрос к источнику (*upstream.Client, запись или воспроизведение архива)
+type Getter interface {
+	Get(ctx context.Context, url string, header http.Header, maxBody int64) (*upstream.Response, error)
+}
+
+type Source struct { // откуда и с какими ограничениями получать данные
+	Client  Getter
+	Options Options
+}
+
+// Fetch запрашивает url и разбирает ответ - JSON-массив элементов T.
+// validate (может быть nil) может изменить элемент и решает, оставить ли его.
+// Ответ с кодом, отличным от 200, возвращается как *upstream.StatusError
+func Fetch[T any](ctx context.Context, src Source, url string, validate func(item *T) bool) (Result[T], error) {
+	var res Result[T]
+	header := make(http.Header)
+	for k, v := range src.Options.Header {
+		header[k] = v
+	}
+	header.Set("Accept", "application/json")
+	header.Set("Accept-Encoding", "gzip") // заголовок задан явно, поэтому распаковываем сами
+	resp, err := src.Client.Get(ctx, url, header, src.Options.MaxBodySize)
+	if err != nil {
+		res.StatusCode = upstream.StatusCode(err)
+		return res, err
+	}
+	res.StatusCode = resp.StatusCode
+	res.Stale = resp.Stale
+	if err := checkContentType(src.Options, url, resp.Header.Get("Content-Type")); err != nil {
+		return res, err
+	}
+	body, err := decompress(src.Options, url, resp)
+	if err != nil {
+		return res, err
+	}
+	items, unknown, err := decode[T](body, src.Options.Strict)
+	if err != nil {
+		return res, &DecodeError{URL: url, Err: err}
+	}
+	res.UnknownFields = unknown // что с ними делать, решает вызывающий код
+	for i := range items {
+		if validate != nil && !validate(&items[i]) {
+			res.Rejected++
+			continue
+		}
+		res.Items = append(res.Items, items[i])
+	}
+	return res, nil
+}
+
+func checkContentType(opts Options, url, contentType string) error {
+	if len(opts.ContentTypes) == 0 {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil {
+		for _, allowed := range opts.ContentTypes {
+			if strings.EqualFold(mediaType, allowed) {
+				return nil
+			}
+		}
+	}
+	return &ContentTypeError{URL: url, ContentType: contentType}
+}
+
+func decompress(opts Options, url string, resp *upstream.Response) ([]byte, error) {
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return resp.Body, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(resp.Body))
+	if err != nil {
+		return nil, &DecodeError{URL: url, Err: err}
+	}
+	defer zr.Close()
+	var r io.Reader = zr
+	if opts.MaxBodySize > 0 {
+		r = io.LimitReader(zr, opts.MaxBodySize+1)
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, &DecodeError{URL: url, Err: err}
+	}
+	if opts.MaxBodySize > 0 && int64(len(body)) > opts.MaxBodySize {
+		return nil, fmt.Errorf("%w: %s (after gzip decoding)", upstream.ErrBodyTooLarge, url)
+	}
+	return body, nil
+}
+
+// decode разбирает JSON-массив элементов T и собирает имена полей, которых нет в T
+func decode[T any](body []byte, strict bool) ([]T, []string, error) {
+	var items []T
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(&items); err != nil {
+		return nil, nil, err
+	}
+	if dec.More() {
+		return nil, nil, errors.New("unexpected data after JSON value")
+	}
+	if strict {
+		return items, nil, nil
+	}
+	var raw []map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil { // элементы не объекты - неизвестных полей быть не может
+		return items, nil, nil
+	}
+	known := jsonFields(reflect.TypeOf((*T)(nil)).Elem())
+	seen := make(map[string]struct{})
+	var unknown []string
+	for _, obj := range raw {
+		for key := range obj {
+			if _, ok := known[strings.ToLower(key)]; ok {
+				continue
+			}
+			if _, ok := seen[key]; !ok {
+				seen[key] = struct{}{}
+				unknown = append(unknown, key)
+			}
+		}
+	}
+	sort.Strings(unknown)
+	return items, unknown, nil
+}
+
+func jsonFields(t reflect.Type) map[string]struct{} { // имена полей структуры в JSON (в нижнем регистре, как сравнивает encoding/json)
+	fields := make(map[string]struct{})
+	if t.Kind() != reflect.Struct {
+		return fields
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Name
+		if tag := field.Tag.Get("json"); tag != "" {
+			if tag == "-" {
+				continue
+			}
+			if n := strings.Split(tag, ",")[0]; n != "" {
+				name = n
+			}
+		}
+		fields[strings.ToLower(name)] = struct{}{}
+	}
+	return fields
+}
@@ -0,0 +1,145 @@
+package fetch
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"finalwork/internal/upstream"
+)
+
+type item struct {
+	Topic string `json:"topic"`
+	Count int    `json:"active_tickets"`
+}
+
+var body = []byte(`[{"topic": "SMS", "active_tickets": 3, "priority": 1}, {"Topic": "MMS", "active_tickets": -1, "owner": "x"}]`)
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// serve поднимает источник, который отвечает кодом status, телом data и заголовками header
+func serve(t *testing.T, status int, data []byte, header map[string]string) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "application/json" || r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for k, v := range header {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(status)
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func source(opts Options) Source {
+	opts.Header = http.Header{"X-Token": {"secret"}}
+	return Source{Client: upstream.New(upstream.Options{}), Options: opts}
+}
+
+func positive(v *item) bool { // отбрасывает элементы с отрицательным счетчиком
+	return v.Count >= 0
+}
+
+func TestFetch(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		opts   Options
+		data   []byte
+		header map[string]string
+	}{
+		{"plain", Options{}, body, map[string]string{"Content-Type": "application/json"}},
+		{"gzip", Options{MaxBodySize: 1000}, gzipped(t, body), map[string]string{"Content-Type": "application/json; charset=utf-8", "Content-Encoding": "gzip"}},
+		{"allowed content type", Options{ContentTypes: []string{"text/plain", "application/json"}}, body, map[string]string{"Content-Type": "Application/JSON"}},
+	} {
+		res, err := Fetch(context.Background(), source(tc.opts), serve(t, http.StatusOK, tc.data, tc.header), positive)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		want := Result[item]{
+			Items:         []item{{Topic: "SMS", Count: 3}},
+			Rejected:      1,
+			StatusCode:    http.StatusOK,
+			UnknownFields: []string{"owner", "priority"}, // "Topic" совпадает с полем topic без учета регистра, как в encoding/json
+		}
+		if !reflect.DeepEqual(res, want) {
+			t.Errorf("%s: got %+v, want %+v", tc.name, res, want)
+		}
+	}
+}
+
+func TestFetchErrors(t *testing.T) {
+	large := []byte(`[` + strings.Repeat(`{"topic": "SMS", "active_tickets": 1},`, 100) + `{}]`)
+	var (
+		contentTypeErr *ContentTypeError
+		decodeErr      *DecodeError
+		statusErr      *upstream.StatusError
+	)
+	for _, tc := range []struct {
+		name   string
+		opts   Options
+		status int
+		data   []byte
+		header map[string]string
+		check  func(err error) bool
+	}{
+		{"status", Options{}, http.StatusServiceUnavailable, body, nil, func(err error) bool {
+			return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusServiceUnavailable
+		}},
+		{"too large", Options{MaxBodySize: 100}, http.StatusOK, large, nil, func(err error) bool { return errors.Is(err, upstream.ErrBodyTooLarge) }},
+		{"too large after gzip", Options{MaxBodySize: 200}, http.StatusOK, gzipped(t, large), map[string]string{"Content-Encoding": "gzip"}, func(err error) bool {
+			return errors.Is(err, upstream.ErrBodyTooLarge) // сжатое тело меньше предела, распакованное - больше
+		}},
+		{"content type", Options{ContentTypes: []string{"application/json"}}, http.StatusOK, body, map[string]string{"Content-Type": "text/html"}, func(err error) bool {
+			return errors.As(err, &contentTypeErr) && contentTypeErr.ContentType == "text/html"
+		}},
+		{"no content type", Options{ContentTypes: []string{"application/json"}}, http.StatusOK, body, map[string]string{"Content-Type": ""}, func(err error) bool { return errors.As(err, &contentTypeErr) }},
+		{"broken gzip", Options{}, http.StatusOK, body, map[string]string{"Content-Encoding": "gzip"}, func(err error) bool { return errors.As(err, &decodeErr) }},
+		{"not an array", Options{}, http.StatusOK, []byte(`{"topic": "SMS"}`), nil, func(err error) bool { return errors.As(err, &decodeErr) }},
+		{"trailing data", Options{}, http.StatusOK, []byte(`[] []`), nil, func(err error) bool { return errors.As(err, &decodeErr) }},
+		{"strict", Options{Strict: true}, http.StatusOK, body, nil, func(err error) bool { return errors.As(err, &decodeErr) }},
+	} {
+		res, err := Fetch(context.Background(), source(tc.opts), serve(t, tc.status, tc.data, tc.header), positive)
+		if err == nil || !tc.check(err) {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		}
+		if res.Items != nil {
+			t.Errorf("%s: items %+v returned with an error", tc.name, res.Items)
+		}
+	}
+}
+
+func TestStrictDecoding(t *testing.T) {
+	known := []byte(`[{"topic": "SMS", "active_tickets": 3}]`)
+	for _, strict := range []bool{false, true} {
+		res, err := Fetch[item](context.Background(), source(Options{Strict: strict}), serve(t, http.StatusOK, known, nil), nil)
+		if err != nil || len(res.Items) != 1 || res.UnknownFields != nil {
+			t.Errorf("strict=%v, known fields: got %+v, %v", strict, res, err)
+		}
+	}
+	res, err := Fetch[item](context.Background(), source(Options{}), serve(t, http.StatusOK, []byte(`[1, 2]`), nil), nil)
+	if err == nil || res.UnknownFields != nil { // элементы не объекты - ошибка разбора, а не неизвестные поля
+		t.Errorf("array of numbers: got %+v, %v", res, err)
+	}
+}
@@ -0,0 +1,133 @@
+package httpcache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+const minCompressSize = 1024 // меньшие ответы не сжимаем: выигрыш меньше накладных расходов
+
+// ETag возвращает сильный ETag содержимого body
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// Write отправляет body с заголовками ETag и Last-Modified (время сбора данных modTime).
+// Если у клиента уже есть это содержимое (If-None-Match или If-Modified-Since), отвечает 304 без тела.
+// Тело сжимается brotli или gzip, если клиент принимает их (Accept-Encoding). Content-Type должен быть установлен заранее
+func Write(w http.ResponseWriter, r *http.Request, body []byte, modTime time.Time) {
+	h := w.Header()
+	h.Add("Vary", "Accept-Encoding")
+	encoding := ""
+	if len(body) >= minCompressSize {
+		encoding = negotiate(r.Header.Get("Accept-Encoding"))
+	}
+	etag := ETag(body)
+	if encoding != "" { // у каждого представления свой сильный ETag: сжатое тело отличается побайтно
+		etag = etag[:len(etag)-1] + "-" + encoding + `"`
+	}
+	h.Set("ETag", etag)
+	if !modTime.IsZero() {
+		h.Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	}
+	if notModified(r, body, modTime) {
+		h.Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if encoding != "" {
+		compressed, err := compress(encoding, body)
+		if err == nil {
+			body = compressed
+			h.Set("Content-Encoding", encoding)
+		} else {
+			h.Set("ETag", ETag(body)) // отдаем несжатым
+		}
+	}
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(body)
+	}
+}
+
+func notModified(r *http.Request, body []byte, modTime time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" { // If-None-Match важнее If-Modified-Since (RFC 9110, 13.2.2)
+		base := ETag(body)
+		base = base[1 : len(base)-1]
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" {
+				return true
+			}
+			tag = strings.TrimPrefix(tag, "W/") // для If-None-Match используется слабое сравнение
+			tag = strings.Trim(tag, `"`)
+			if i := strings.LastIndex(tag, "-"); i >= 0 { // суффикс кодировки: содержимое то же, меняется только сжатие
+				tag = tag[:i]
+			}
+			if tag == base {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !modTime.IsZero() {
+		t, err := http.ParseTime(ims)
+		return err == nil && !modTime.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
+// negotiate выбирает сжатие по Accept-Encoding: br предпочтительнее gzip при равном весе. Пустая строка - без сжатия
+func negotiate(acceptEncoding string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				v, err := strconv.ParseFloat(param[2:], 64)
+				if err != nil {
+					v = 0
+				}
+				q = v
+			}
+		}
+		if q <= 0 || (name != "br" && name != "gzip") {
+			continue
+		}
+		if q > bestQ || (q == bestQ && name == "br") {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+func compress(encoding string, body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch encoding {
+	case "br":
+		bw := brotli.NewWriterLevel(&buf, 5) // средний уровень: сжатие близко к максимальному при заметно меньшем времени
+		if _, err = bw.Write(body); err == nil {
+			err = bw.Close()
+		}
+	default:
+		gw := gzip.NewWriter(&buf)
+		if _, err = gw.Write(body); err == nil {
+			err = gw.Close()
+		}
+	}
+	return buf.Bytes(), err
+}
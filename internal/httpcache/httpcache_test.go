@@ -0,0 +1,136 @@
+package httpcache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+var (
+	largeBody = []byte(strings.Repeat(`{"system":"sms","status":"ok"}`, 100))
+	modTime   = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+)
+
+func write(method string, body []byte, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/", nil)
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Content-Type", "application/json")
+	Write(rec, r, body, modTime)
+	return rec
+}
+
+func TestNegotiate(t *testing.T) {
+	for header, want := range map[string]string{
+		"":                           "",
+		"identity":                   "",
+		"gzip":                       "gzip",
+		"gzip, deflate, br":          "br",
+		"br, gzip":                   "br",
+		"GZIP":                       "gzip",
+		"br;q=0.5, gzip":             "gzip",
+		"br;q=0.8, gzip;q=0.8":       "br",
+		"br;q=0, gzip;q=0":           "",
+		"br;q=abc, gzip;q=0.1":       "gzip",
+		"deflate, gzip ; q=0.3":      "gzip",
+		"*":                          "",
+		" br ; q=1.0 , gzip ; q=0.9": "br",
+	} {
+		if got := negotiate(header); got != want {
+			t.Errorf("%q: got %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	base := ETag(largeBody)
+	for _, tc := range []struct {
+		accept, encoding string
+		decode           func(io.Reader) (io.Reader, error)
+	}{
+		{"", "", func(r io.Reader) (io.Reader, error) { return r, nil }},
+		{"gzip", "gzip", func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		{"gzip, br", "br", func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil }},
+	} {
+		rec := write("GET", largeBody, map[string]string{"Accept-Encoding": tc.accept})
+		h := rec.Header()
+		if h.Get("Content-Encoding") != tc.encoding || h.Get("Vary") != "Accept-Encoding" || h.Get("Content-Type") != "application/json" {
+			t.Errorf("%q: headers %v", tc.accept, h)
+		}
+		wantTag := base
+		if tc.encoding != "" {
+			wantTag = base[:len(base)-1] + "-" + tc.encoding + `"`
+		}
+		if h.Get("ETag") != wantTag {
+			t.Errorf("%q: ETag %s, want %s", tc.accept, h.Get("ETag"), wantTag)
+		}
+		if h.Get("Content-Length") != strconv.Itoa(rec.Body.Len()) {
+			t.Errorf("%q: Content-Length %s for %d bytes", tc.accept, h.Get("Content-Length"), rec.Body.Len())
+		}
+		r, err := tc.decode(rec.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := io.ReadAll(r); err != nil || !bytes.Equal(got, largeBody) {
+			t.Errorf("%q: decoded body differs: %v", tc.accept, err)
+		}
+	}
+
+	small := []byte(`{"status":"ok"}`) // маленький ответ не сжимается
+	rec := write("GET", small, map[string]string{"Accept-Encoding": "br"})
+	if rec.Header().Get("Content-Encoding") != "" || rec.Header().Get("ETag") != ETag(small) || rec.Body.String() != string(small) {
+		t.Errorf("small body: headers %v, body %q", rec.Header(), rec.Body.String())
+	}
+}
+
+func TestConditional(t *testing.T) {
+	base := ETag(largeBody)
+	bare := strings.Trim(base, `"`)
+	lastModified := modTime.Format(http.TimeFormat)
+	for _, tc := range []struct {
+		name   string
+		header map[string]string
+		status int
+	}{
+		{"no validators", nil, http.StatusOK},
+		{"same ETag", map[string]string{"If-None-Match": base}, http.StatusNotModified},
+		{"ETag of another encoding", map[string]string{"If-None-Match": `"` + bare + `-gzip"`, "Accept-Encoding": "br"}, http.StatusNotModified},
+		{"weak ETag", map[string]string{"If-None-Match": `W/"` + bare + `-br"`}, http.StatusNotModified},
+		{"ETag in a list", map[string]string{"If-None-Match": `"abc", ` + base}, http.StatusNotModified},
+		{"star", map[string]string{"If-None-Match": "*"}, http.StatusNotModified},
+		{"other ETag", map[string]string{"If-None-Match": `"abc-gzip"`}, http.StatusOK},
+		{"other ETag wins over If-Modified-Since", map[string]string{"If-None-Match": `"abc"`, "If-Modified-Since": lastModified}, http.StatusOK},
+		{"not modified since", map[string]string{"If-Modified-Since": lastModified}, http.StatusNotModified},
+		{"modified since", map[string]string{"If-Modified-Since": modTime.Add(-time.Second).Format(http.TimeFormat)}, http.StatusOK},
+		{"bad date", map[string]string{"If-Modified-Since": "yesterday"}, http.StatusOK},
+	} {
+		rec := write("GET", largeBody, tc.header)
+		if rec.Code != tc.status {
+			t.Errorf("%s: got %d, want %d", tc.name, rec.Code, tc.status)
+			continue
+		}
+		if rec.Header().Get("Last-Modified") != lastModified || rec.Header().Get("ETag") == "" {
+			t.Errorf("%s: headers %v", tc.name, rec.Header())
+		}
+		if tc.status == http.StatusNotModified && (rec.Body.Len() != 0 || rec.Header().Get("Content-Type") != "") {
+			t.Errorf("%s: 304 with body %d bytes or Content-Type %q", tc.name, rec.Body.Len(), rec.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestHead(t *testing.T) {
+	rec := write("HEAD", largeBody, nil)
+	if rec.Code != http.StatusOK || rec.Body.Len() != 0 || rec.Header().Get("Content-Length") != strconv.Itoa(len(largeBody)) {
+		t.Errorf("HEAD: code %d, body %d bytes, headers %v", rec.Code, rec.Body.Len(), rec.Header())
+	}
+}
@@ -0,0 +1,154 @@
+package incident
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Классификация инцидентов по теме: источник присылает только тему и статус ("SMS delivery in EU"),
+// систему и серьезность определяют правила из настроек, страны и регионы - слова после "in".
+
+const (
+	SeverityMinor    = "minor"
+	SeverityMajor    = "major"
+	SeverityCritical = "critical"
+)
+
+// SeverityRank возвращает порядок серьезности (1 - minor, 3 - critical), 0 для неизвестного значения
+func SeverityRank(severity string) int {
+	switch severity {
+	case SeverityMinor:
+		return 1
+	case SeverityMajor:
+		return 2
+	case SeverityCritical:
+		return 3
+	}
+	return 0
+}
+
+var Systems = []string{"sms", "mms", "voice_call", "email", "billing", "support"} // системы, совпадают с полями агрегированного ответа
+
+func knownSystem(system string) bool {
+	for _, s := range Systems {
+		if s == system {
+			return true
+		}
+	}
+	return false
+}
+
+type Config struct {
+	Rules           []Rule              `json:"rules"`            // проверяются по порядку, каждое поле берется из первого совпавшего правила, в котором оно задано
+	DefaultSeverity string              `json:"default_severity"` // если ни одно правило не задало серьезность
+	Regions         map[string][]string `json:"regions"`          // название региона -> коды стран alpha-2
+}
+
+type Rule struct {
+	Match    string `json:"match"`              // регулярное выражение по теме (без учета регистра)
+	System   string `json:"system,omitempty"`   // затронутая система
+	Severity string `json:"severity,omitempty"` // серьезность
+}
+
+type Classifier struct {
+	rules           []compiledRule
+	defaultSeverity string
+	regions         map[string]region // ключ - название региона в верхнем регистре
+}
+
+type compiledRule struct {
+	Rule
+	re *regexp.Regexp
+}
+
+type region struct {
+	name      string
+	countries []string
+}
+
+var locationRe = regexp.MustCompile(`(?i)\bin\s+(.+)$`) // "... in EU", "... in US, CA"
+
+// NewClassifier проверяет настройки и компилирует правила
+func NewClassifier(c Config) (*Classifier, error) {
+	if SeverityRank(c.DefaultSeverity) == 0 {
+		return nil, fmt.Errorf("incidents: unknown default_severity %q", c.DefaultSeverity)
+	}
+	cl := &Classifier{defaultSeverity: c.DefaultSeverity, regions: make(map[string]region)}
+	for i, r := range c.Rules {
+		re, err := regexp.Compile("(?i)" + r.Match)
+		if err != nil {
+			return nil, fmt.Errorf("incidents: rule #%d: %w", i+1, err)
+		}
+		if r.System != "" && !knownSystem(r.System) {
+			return nil, fmt.Errorf("incidents: rule #%d: unknown system %q (allowed: %s)", i+1, r.System, strings.Join(Systems, ", "))
+		}
+		if r.Severity != "" && SeverityRank(r.Severity) == 0 {
+			return nil, fmt.Errorf("incidents: rule #%d: unknown severity %q", i+1, r.Severity)
+		}
+		cl.rules = append(cl.rules, compiledRule{Rule: r, re: re})
+	}
+	for name, codes := range c.Regions {
+		for _, code := range codes {
+			if len(code) != 2 || strings.ToUpper(code) != code {
+				return nil, fmt.Errorf("incidents: region %q: %q is not an alpha-2 code", name, code)
+			}
+		}
+		cl.regions[strings.ToUpper(name)] = region{name: name, countries: codes}
+	}
+	return cl, nil
+}
+
+// Apply заполняет систему, серьезность, регионы и страны, которые источник не прислал.
+// isCountry проверяет код страны: после "in" допускаются только известные коды и регионы
+func (cl *Classifier) Apply(data []IncidentData, isCountry func(code string) bool) {
+	for i := range data {
+		v := &data[i]
+		for _, r := range cl.rules {
+			if (v.System != "" || r.System == "") && (v.Severity != "" || r.Severity == "") {
+				continue // правило ничего не добавит
+			}
+			if !r.re.MatchString(v.Topic) {
+				continue
+			}
+			if v.System == "" {
+				v.System = r.System
+			}
+			if v.Severity == "" {
+				v.Severity = r.Severity
+			}
+		}
+		if v.Severity == "" {
+			v.Severity = cl.defaultSeverity
+		}
+		if len(v.Regions) == 0 && len(v.Countries) == 0 {
+			v.Regions, v.Countries = cl.locations(v.Topic, isCountry)
+		}
+	}
+}
+
+func (cl *Classifier) locations(topic string, isCountry func(code string) bool) (regions, countries []string) {
+	m := locationRe.FindStringSubmatch(topic)
+	if m == nil {
+		return nil, nil
+	}
+	seen := make(map[string]bool)
+	add := func(code string) {
+		if !seen[code] {
+			seen[code] = true
+			countries = append(countries, code)
+		}
+	}
+	for _, token := range strings.FieldsFunc(strings.ReplaceAll(m[1], " and ", ","), func(r rune) bool { return r == ',' || r == '/' }) {
+		token = strings.ToUpper(strings.TrimSpace(token))
+		if r, ok := cl.regions[token]; ok {
+			regions = append(regions, r.name)
+			for _, code := range r.countries {
+				add(code)
+			}
+		} else if len(token) == 2 && isCountry(token) {
+			add(token)
+		}
+	}
+	return regions, countries
+}
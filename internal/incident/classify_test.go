@@ -0,0 +1,114 @@
+package incident
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testConfig = Config{
+	Rules: []Rule{
+		{Match: `\bsms\b`, System: "sms"},
+		{Match: `delivery`, Severity: SeverityMajor},
+		{Match: `\bmms\b`, System: "mms", Severity: SeverityCritical},
+		{Match: `outage`, Severity: SeverityCritical},
+	},
+	DefaultSeverity: SeverityMinor,
+	Regions:         map[string][]string{"EU": {"DE", "FR"}, "Nordics": {"SE", "NO"}},
+}
+
+func isTestCountry(code string) bool {
+	switch code {
+	case "US", "CA", "DE", "FR", "SE", "NO", "RU":
+		return true
+	}
+	return false
+}
+
+func TestNewClassifierErrors(t *testing.T) {
+	for _, tc := range []struct {
+		config Config
+		want   string
+	}{
+		{Config{DefaultSeverity: "urgent"}, "unknown default_severity"},
+		{Config{}, "unknown default_severity"},
+		{Config{DefaultSeverity: SeverityMinor, Rules: []Rule{{Match: "("}}}, "rule #1"},
+		{Config{DefaultSeverity: SeverityMinor, Rules: []Rule{{Match: "sms"}, {Match: "fax", System: "fax"}}}, `rule #2: unknown system "fax"`},
+		{Config{DefaultSeverity: SeverityMinor, Rules: []Rule{{Match: "sms", Severity: "high"}}}, `unknown severity "high"`},
+		{Config{DefaultSeverity: SeverityMinor, Regions: map[string][]string{"EU": {"de"}}}, "not an alpha-2 code"},
+		{Config{DefaultSeverity: SeverityMinor, Regions: map[string][]string{"EU": {"DEU"}}}, "not an alpha-2 code"},
+	} {
+		if _, err := NewClassifier(tc.config); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%+v: got %v, want an error containing %q", tc.config, err, tc.want)
+		}
+	}
+	if _, err := NewClassifier(testConfig); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	cl, err := NewClassifier(testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		in   IncidentData
+		want IncidentData
+	}{
+		{ // система и серьезность из разных правил: каждое поле - из первого правила, где оно задано
+			IncidentData{Topic: "SMS delivery in EU"},
+			IncidentData{Topic: "SMS delivery in EU", System: "sms", Severity: SeverityMajor, Regions: []string{"EU"}, Countries: []string{"DE", "FR"}},
+		},
+		{ // более позднее правило не перезаписывает уже заполненные поля
+			IncidentData{Topic: "SMS and MMS outage"},
+			IncidentData{Topic: "SMS and MMS outage", System: "sms", Severity: SeverityCritical},
+		},
+		{ // присланные источником поля не меняются
+			IncidentData{Topic: "MMS delivery", System: "email", Severity: SeverityMinor},
+			IncidentData{Topic: "MMS delivery", System: "email", Severity: SeverityMinor},
+		},
+		{ // ни одно правило не совпало
+			IncidentData{Topic: "Billing slowdown"},
+			IncidentData{Topic: "Billing slowdown", Severity: SeverityMinor},
+		},
+		{
+			IncidentData{Topic: "Voice calls in US and CA"},
+			IncidentData{Topic: "Voice calls in US and CA", Severity: SeverityMinor, Countries: []string{"US", "CA"}},
+		},
+		{
+			IncidentData{Topic: "Email delays in us/ca"},
+			IncidentData{Topic: "Email delays in us/ca", Severity: SeverityMinor, Countries: []string{"US", "CA"}},
+		},
+		{ // регион и его страна без повторов
+			IncidentData{Topic: "Support queue in nordics, SE and RU"},
+			IncidentData{Topic: "Support queue in nordics, SE and RU", Severity: SeverityMinor, Regions: []string{"Nordics"}, Countries: []string{"SE", "NO", "RU"}},
+		},
+		{ // неизвестные коды и регионы пропускаются
+			IncidentData{Topic: "Email delays in XX, Asia and DE"},
+			IncidentData{Topic: "Email delays in XX, Asia and DE", Severity: SeverityMinor, Countries: []string{"DE"}},
+		},
+		{
+			IncidentData{Topic: "Email delays in Mordor"},
+			IncidentData{Topic: "Email delays in Mordor", Severity: SeverityMinor},
+		},
+		{ // без "in" страны не определяются
+			IncidentData{Topic: "Email delays US"},
+			IncidentData{Topic: "Email delays US", Severity: SeverityMinor},
+		},
+		{ // "in" внутри слова не считается
+			IncidentData{Topic: "Billing maintenance"},
+			IncidentData{Topic: "Billing maintenance", Severity: SeverityMinor},
+		},
+		{ // присланные источником страны не меняются
+			IncidentData{Topic: "Email delays in DE", Countries: []string{"US"}},
+			IncidentData{Topic: "Email delays in DE", Severity: SeverityMinor, Countries: []string{"US"}},
+		},
+	} {
+		data := []IncidentData{tc.in}
+		cl.Apply(data, isTestCountry)
+		if !reflect.DeepEqual(data[0], tc.want) {
+			t.Errorf("%q:\ngot  %+v\nwant %+v", tc.in.Topic, data[0], tc.want)
+		}
+	}
+}
@@ -1,32 +1,57 @@
 package incident
 
 import (
-	"encoding/json"
-	"io"
-	"net/http"
+	"context"
+	"finalwork/internal/fetch"
+	"sort"
+	"strings"
+	"time"
 )
 
 type IncidentData struct {
-	Topic  string `json:"topic"`
-	Status string `json:"status"`
+	Topic     string   `json:"topic"`
+	Status    string   `json:"status"`
+	Severity  string   `json:"severity,omitempty"`  // minor, major или critical. Если источник не прислал - определяется правилами по теме
+	System    string   `json:"system,omitempty"`    // затронутая система (sms, mms, voice_call, email, billing, support). Пустая строка - не определена
+	Regions   []string `json:"regions,omitempty"`   // затронутые регионы из настроек (например EU)
+	Countries []string `json:"countries,omitempty"` // затронутые страны, коды alpha-2 (в том числе страны регионов). Пусто - все страны
+	Updates   []Update `json:"updates,omitempty"`   // сообщения о ходе устранения, новые первыми
 }
 
-func GetIncidentData(addr string) ([]IncidentData, int, error) { // функция сбора данных о системе Incident
-	var incidentDataSlice []IncidentData
-	resp, err := http.Get(addr) //отправляем GET-запрос по addr
-	if err != nil {
-		return incidentDataSlice, resp.StatusCode, err
+type Update struct { // сообщение о ходе устранения инцидента
+	At   time.Time `json:"at"`
+	Text string    `json:"text"`
+}
+
+// GetIncidentData запрашивает данные системы Incident. Ответ с кодом, отличным от 200, возвращается как *upstream.StatusError
+func GetIncidentData(src fetch.Source, addr string) (fetch.Result[IncidentData], error) {
+	return fetch.Fetch(context.Background(), src, addr, validate) // GET-запрос, проверка и разбор ответа
+}
+
+func validate(v *IncidentData) bool { // проверка элемента: тема указана, статус "active" или "closed"
+	v.Topic = strings.TrimSpace(v.Topic)
+	v.Status = strings.ToLower(strings.TrimSpace(v.Status))
+	if v.Topic == "" || (v.Status != "active" && v.Status != "closed") {
+		return false
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode == 200 { // проверяем код ответа API
-		body, err := io.ReadAll(resp.Body) // считываем тело запроса
-		if err != nil {
-			return incidentDataSlice, resp.StatusCode, err
-		}
-		if err := json.Unmarshal(body, &incidentDataSlice); err != nil { // используем функцию Unmarshal
-			return incidentDataSlice, resp.StatusCode, err
+	v.Severity = strings.ToLower(strings.TrimSpace(v.Severity)) // необязательные поля: неизвестные значения отбрасываются, их заполнят правила
+	if SeverityRank(v.Severity) == 0 {
+		v.Severity = ""
+	}
+	v.System = strings.ToLower(strings.TrimSpace(v.System))
+	if !knownSystem(v.System) {
+		v.System = ""
+	}
+	for i := range v.Countries {
+		v.Countries[i] = strings.ToUpper(strings.TrimSpace(v.Countries[i]))
+	}
+	updates := v.Updates[:0]
+	for _, u := range v.Updates {
+		if u.Text = strings.TrimSpace(u.Text); u.Text != "" {
+			updates = append(updates, u)
 		}
-		return incidentDataSlice, resp.StatusCode, nil
 	}
-	return incidentDataSlice, resp.StatusCode, nil
+	sort.SliceStable(updates, func(i, j int) bool { return updates[i].At.After(updates[j].At) })
+	v.Updates = updates
+	return true
 }
@@ -0,0 +1,14 @@
+package input
+
+import "os"
+
+// Files - откуда читаются файлы систем SMS, Voice, Email и Billing
+type Files interface {
+	ReadFile(name string) ([]byte, error)
+}
+
+type Disk struct{} // чтение с диска
+
+func (Disk) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
@@ -0,0 +1,41 @@
+// Package inputtest - общие заготовки для тестов разбора файлов систем SMS, Voice, Email и Billing.
+// Импортируется только из тестов, как net/http/httptest.
+package inputtest
+
+import (
+	"finalwork/internal/countries"
+	"finalwork/internal/providers"
+	"testing"
+)
+
+type Bytes []byte // содержимое файла в памяти: любое имя читается как эти байты
+
+func (b Bytes) ReadFile(string) ([]byte, error) {
+	return b, nil
+}
+
+// Setup возвращает вшитый список стран и каталог из провайдеров ps
+func Setup(t testing.TB, ps ...providers.Provider) (countries.CountryRepository, *providers.Catalog) {
+	t.Helper()
+	repo, err := countries.ISOCountryRepository("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog, err := providers.NewCatalog(ps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo, catalog
+}
+
+// CheckRow проверяет строку, которую разбор признал допустимой: страна известна, провайдер допустим в канале ch
+// и записан каноническим именем
+func CheckRow(t testing.TB, repo countries.CountryRepository, catalog *providers.Catalog, ch providers.Channel, country, provider string) {
+	t.Helper()
+	if _, ok := repo.Lookup(countries.Code(country)); !ok {
+		t.Fatalf("accepted unknown country %q", country)
+	}
+	if name, ok := catalog.Canonical(ch, provider); !ok || name != provider {
+		t.Fatalf("accepted provider %q that is not a canonical %s provider", provider, ch)
+	}
+}
@@ -0,0 +1,249 @@
+package maintenance
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Плановые работы и ручные состояния, заданные операторами. Хранятся в JSON-файле и переживают перезапуск сервиса.
+// Закончившиеся окна и истекшие состояния удаляются при следующем изменении.
+
+type Window struct { // окно плановых работ
+	ID        string    `json:"id"`
+	Systems   []string  `json:"systems,omitempty"`   // затронутые системы
+	Providers []string  `json:"providers,omitempty"` // затронутые провайдеры (канонические имена)
+	Countries []string  `json:"countries,omitempty"` // затронутые страны, коды alpha-2
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Override struct { // состояние, заданное вручную поверх вычисленного правилами
+	ID        string    `json:"id"`
+	Component string    `json:"component,omitempty"` // система; пусто - общее состояние
+	State     string    `json:"state"`
+	Message   string    `json:"message"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Active сообщает, идет ли работа в момент now
+func (w Window) Active(now time.Time) bool {
+	return !now.Before(w.Start) && now.Before(w.End)
+}
+
+// SystemWide сообщает, относится ли окно к системам целиком: системы указаны, провайдеры и страны - нет
+func (w Window) SystemWide() bool {
+	return len(w.Systems) > 0 && len(w.Providers) == 0 && len(w.Countries) == 0
+}
+
+// Covers сообщает, относится ли окно к строке данных системы system провайдера provider в стране country (код alpha-2)
+func (w Window) Covers(system, provider, country string) bool {
+	return matches(w.Systems, system) && matches(w.Providers, provider) && matches(w.Countries, country)
+}
+
+func matches(list []string, v string) bool { // пустой список - любое значение
+	if len(list) == 0 {
+		return true
+	}
+	for _, s := range list {
+		if strings.EqualFold(s, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Active сообщает, действует ли состояние в момент now
+func (o Override) Active(now time.Time) bool {
+	return now.Before(o.ExpiresAt)
+}
+
+var (
+	ErrNotFound = errors.New("maintenance: not found")
+	ErrInvalid  = errors.New("maintenance: invalid time") // окно уже закончилось, конец раньше начала, срок действия в прошлом
+)
+
+type Store struct {
+	mu        sync.Mutex
+	file      string
+	windows   []Window
+	overrides []Override
+	changedAt time.Time // время последнего изменения через API
+}
+
+type fileData struct {
+	Windows   []Window   `json:"windows"`
+	Overrides []Override `json:"overrides"`
+	ChangedAt time.Time  `json:"changed_at"`
+}
+
+// Open читает сохраненные окна и состояния. Отсутствующий файл - пустое хранилище
+func Open(file string) (*Store, error) {
+	s := &Store{file: file}
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("maintenance: %w", err)
+	}
+	var d fileData
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("maintenance: %s: %w", file, err)
+	}
+	s.windows, s.overrides, s.changedAt = d.Windows, d.Overrides, d.ChangedAt
+	return s, nil
+}
+
+// AddWindow проверяет время окна, назначает ему ID и сохраняет. Нулевое начало - с текущего момента
+func (s *Store) AddWindow(w Window, now time.Time) (Window, error) {
+	if w.Start.IsZero() {
+		w.Start = now
+	}
+	if !w.End.After(w.Start) {
+		return w, fmt.Errorf("%w: end must be after start", ErrInvalid)
+	}
+	if !w.End.After(now) {
+		return w, fmt.Errorf("%w: window has already ended", ErrInvalid)
+	}
+	w.ID, w.CreatedAt = newID(), now
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return w, s.save(append(s.windows[:len(s.windows):len(s.windows)], w), s.overrides, now)
+}
+
+// AddOverride проверяет срок действия состояния, назначает ему ID и сохраняет
+func (s *Store) AddOverride(o Override, now time.Time) (Override, error) {
+	if !o.ExpiresAt.After(now) {
+		return o, fmt.Errorf("%w: expires_at must be in the future", ErrInvalid)
+	}
+	o.ID, o.CreatedAt = newID(), now
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return o, s.save(s.windows, append(s.overrides[:len(s.overrides):len(s.overrides)], o), now)
+}
+
+// DeleteWindow удаляет окно (в том числе для досрочного завершения работ)
+func (s *Store) DeleteWindow(id string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, w := range s.windows {
+		if w.ID == id {
+			windows := append(append([]Window(nil), s.windows[:i]...), s.windows[i+1:]...)
+			return s.save(windows, s.overrides, now)
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *Store) DeleteOverride(id string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, o := range s.overrides {
+		if o.ID == id {
+			overrides := append(append([]Override(nil), s.overrides[:i]...), s.overrides[i+1:]...)
+			return s.save(s.windows, overrides, now)
+		}
+	}
+	return ErrNotFound
+}
+
+// Windows возвращает текущие и запланированные окна в порядке начала
+func (s *Store) Windows(now time.Time) []Window {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]Window, 0, len(s.windows))
+	for _, w := range s.windows {
+		if now.Before(w.End) {
+			list = append(list, w)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Start.Before(list[j].Start) })
+	return list
+}
+
+// Overrides возвращает действующие состояния в порядке создания
+func (s *Store) Overrides(now time.Time) []Override {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]Override, 0, len(s.overrides))
+	for _, o := range s.overrides {
+		if o.Active(now) {
+			list = append(list, o)
+		}
+	}
+	return list
+}
+
+// ChangedAt возвращает момент, с которого действует текущий набор окон и состояний:
+// последнее изменение через API, начало или конец окна, истечение состояния - что было позже (но не позже now)
+func (s *Store) ChangedAt(now time.Time) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.changedAt
+	later := func(v time.Time) {
+		if v.After(t) && !v.After(now) {
+			t = v
+		}
+	}
+	for _, w := range s.windows {
+		later(w.Start)
+		later(w.End)
+	}
+	for _, o := range s.overrides {
+		later(o.ExpiresAt)
+	}
+	return t
+}
+
+// save записывает новый набор окон и состояний без закончившихся и только после успешной записи делает его текущим:
+// при ошибке API возвращает ошибку, а действующие окна и состояния не меняются. Вызывается под s.mu
+func (s *Store) save(windows []Window, overrides []Override, now time.Time) error {
+	keptWindows := make([]Window, 0, len(windows))
+	for _, w := range windows {
+		if now.Before(w.End) {
+			keptWindows = append(keptWindows, w)
+		}
+	}
+	keptOverrides := make([]Override, 0, len(overrides))
+	for _, o := range overrides {
+		if o.Active(now) {
+			keptOverrides = append(keptOverrides, o)
+		}
+	}
+	data, err := json.MarshalIndent(fileData{Windows: keptWindows, Overrides: keptOverrides, ChangedAt: now}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("maintenance: %w", err)
+	}
+	if dir := filepath.Dir(s.file); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("maintenance: %w", err)
+		}
+	}
+	tmp := s.file + ".tmp" // запись через временный файл: при сбое остается прежняя версия
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("maintenance: %w", err)
+	}
+	if err := os.Rename(tmp, s.file); err != nil {
+		return fmt.Errorf("maintenance: %w", err)
+	}
+	s.windows, s.overrides, s.changedAt = keptWindows, keptOverrides, now
+	return nil
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,42 @@
+package maintenance
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFailedSaveKeepsState(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(filepath.Join(dir, "maintenance.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	kept, err := s.AddWindow(Window{Systems: []string{"sms"}, End: now.Add(time.Hour), Message: "kept"}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocker := filepath.Join(dir, "blocker") // файл на месте директории: запись невозможна
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	s.file = filepath.Join(blocker, "maintenance.json")
+	if _, err := s.AddWindow(Window{Systems: []string{"email"}, End: now.Add(time.Hour), Message: "lost"}, now); err == nil {
+		t.Fatal("expected a write error")
+	}
+	if _, err := s.AddOverride(Override{Component: "sms", State: "degraded", Message: "lost", ExpiresAt: now.Add(time.Hour)}, now); err == nil {
+		t.Fatal("expected a write error")
+	}
+	if err := s.DeleteWindow(kept.ID, now); err == nil {
+		t.Fatal("expected a write error")
+	}
+	if list := s.Windows(now); len(list) != 1 || list[0].ID != kept.ID {
+		t.Errorf("windows after failed writes: %+v", list)
+	}
+	if list := s.Overrides(now); len(list) != 0 {
+		t.Errorf("overrides after failed writes: %+v", list)
+	}
+}
@@ -0,0 +1,128 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//go:embed templates/*.tmpl
+var embeddedTemplates embed.FS // шаблоны писем: первая строка - "Subject: ...", после пустой строки - текст
+
+var templateNames = []string{"confirm.tmpl", "incident.tmpl", "maintenance.tmpl"}
+
+var templateFuncs = template.FuncMap{
+	"join":       strings.Join,
+	"formatTime": func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04 MST") },
+	"title": func(s string) string {
+		if s == "" {
+			return s
+		}
+		return strings.ToUpper(s[:1]) + s[1:]
+	},
+	"components": func(list []string) string { // компоненты подписки для текста письма
+		if len(list) == 0 {
+			return "all systems"
+		}
+		return strings.Join(list, ", ")
+	},
+}
+
+// loadTemplates разбирает встроенные шаблоны и, если dir не пустой, одноименные файлы из dir поверх них
+func loadTemplates(dir string) (*template.Template, error) {
+	t, err := template.New("").Funcs(templateFuncs).ParseFS(embeddedTemplates, "templates/*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		files, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+		if err != nil {
+			return nil, err
+		}
+		if len(files) > 0 {
+			if t, err = t.ParseFiles(files...); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, name := range templateNames {
+		if t.Lookup(name) == nil {
+			return nil, fmt.Errorf("template %s is missing", name)
+		}
+	}
+	return t, nil
+}
+
+type message struct {
+	to             string
+	subject        string
+	body           string
+	unsubscribeURL string
+}
+
+// render выполняет шаблон и отделяет тему от текста
+func (n *Notifier) render(name, to, unsubscribeURL string, data interface{}) (message, error) {
+	var b bytes.Buffer
+	if err := n.templates.ExecuteTemplate(&b, name, data); err != nil {
+		return message{}, err
+	}
+	head, body, ok := strings.Cut(b.String(), "\n\n")
+	if !ok || !strings.HasPrefix(head, "Subject: ") || strings.Contains(head, "\n") {
+		return message{}, fmt.Errorf("template %s: the first line must be \"Subject: ...\" followed by an empty line", name)
+	}
+	return message{to: to, subject: strings.TrimPrefix(head, "Subject: "), body: body, unsubscribeURL: unsubscribeURL}, nil
+}
+
+func (n *Notifier) build(m message) []byte {
+	var b bytes.Buffer
+	header := func(name, value string) {
+		b.WriteString(name + ": " + value + "\r\n")
+	}
+	header("From", n.cfg.SMTP.From)
+	header("To", m.to)
+	header("Subject", mime.QEncoding.Encode("utf-8", m.subject)) // тема может содержать не-ASCII символы
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=UTF-8")
+	header("Content-Transfer-Encoding", "8bit")
+	if m.unsubscribeURL != "" {
+		header("List-Unsubscribe", "<"+m.unsubscribeURL+">")
+		header("List-Unsubscribe-Post", "List-Unsubscribe=One-Click") // отписка одним POST-запросом из почтового клиента
+	}
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(m.body, "\r\n", "\n"), "\n", "\r\n"))
+	return b.Bytes()
+}
+
+// deliver отправляет письма в фоне, по одному на получателя: у каждого своя ссылка отписки
+func (n *Notifier) deliver(messages []message) {
+	if len(messages) == 0 {
+		return
+	}
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		var auth smtp.Auth
+		if n.cfg.SMTP.Username != "" {
+			host, _, _ := net.SplitHostPort(n.cfg.SMTP.Addr)
+			auth = smtp.PlainAuth("", n.cfg.SMTP.Username, n.cfg.SMTP.Password, host)
+		}
+		for _, m := range messages {
+			if err := n.send(n.cfg.SMTP.Addr, auth, n.cfg.SMTP.From, []string{m.to}, n.build(m)); err != nil {
+				fmt.Printf("Error sending notification to %s: %v\n", m.to, err)
+			}
+		}
+	}()
+}
+
+// Wait ожидает отправки писем, поставленных в очередь
+func (n *Notifier) Wait() {
+	n.wg.Wait()
+}
@@ -0,0 +1,338 @@
+package notify
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"finalwork/internal/incident"
+	"fmt"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Оповещения подписчиков по электронной почте: подписка с подтверждением, отписка по ссылке из письма,
+// письма об открытии и закрытии инцидентов и о запланированных работах. Подписчик выбирает системы; без выбора - все.
+
+type Config struct {
+	Enabled      bool   `json:"enabled"`
+	File         string `json:"file"`          // подписчики и последние статусы инцидентов (сохраняются между перезапусками)
+	BaseURL      string `json:"base_url"`      // внешний адрес сервиса для ссылок подтверждения и отписки
+	TemplatesDir string `json:"templates_dir"` // файлы *.tmpl, заменяющие встроенные шаблоны с тем же именем. Пусто - только встроенные
+	SMTP         SMTP   `json:"smtp"`
+}
+
+type SMTP struct {
+	Addr     string `json:"addr"`     // host:port
+	Username string `json:"username"` // пусто - без аутентификации
+	Password string `json:"password"`
+	From     string `json:"from"`
+}
+
+type Subscriber struct {
+	Email            string    `json:"email"`
+	Components       []string  `json:"components,omitempty"` // системы; пусто - все
+	Confirmed        bool      `json:"confirmed"`
+	ConfirmToken     string    `json:"confirm_token,omitempty"` // ожидающий подтверждения запрос: новая подписка или изменение систем
+	Requested        []string  `json:"requested,omitempty"`     // системы из этого запроса
+	UnsubscribeToken string    `json:"unsubscribe_token"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+type Announcement struct { // запланированные работы
+	Systems []string
+	Start   time.Time
+	End     time.Time
+	Message string
+}
+
+const (
+	transitionOpened   = "opened"
+	transitionResolved = "resolved"
+)
+
+var (
+	ErrUnknownToken = errors.New("notify: unknown or used token")
+	ErrInvalid      = errors.New("notify: invalid subscription")
+)
+
+type Notifier struct {
+	cfg        Config
+	components []string
+	templates  *template.Template
+	send       func(addr string, a smtp.Auth, from string, to []string, msg []byte) error // smtp.SendMail; в тестах подменяется
+	wg         sync.WaitGroup                                                             // письма в процессе отправки
+
+	mu          sync.Mutex
+	subscribers []Subscriber
+	incidents   map[string]string // тема -> статус при последнем сборе; nil - сборов еще не было
+}
+
+type state struct {
+	Subscribers []Subscriber      `json:"subscribers"`
+	Incidents   map[string]string `json:"incidents"`
+}
+
+// New проверяет настройки, разбирает шаблоны и читает сохраненное состояние. components - допустимые системы
+func New(cfg Config, components []string) (*Notifier, error) {
+	if cfg.SMTP.Addr == "" || cfg.SMTP.From == "" {
+		return nil, fmt.Errorf("notifications: smtp.addr and smtp.from are required")
+	}
+	if u, err := url.Parse(cfg.BaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("notifications: base_url must be an absolute URL, got %q", cfg.BaseURL)
+	}
+	t, err := loadTemplates(cfg.TemplatesDir)
+	if err != nil {
+		return nil, fmt.Errorf("notifications: %w", err)
+	}
+	n := &Notifier{cfg: cfg, components: components, templates: t, send: smtp.SendMail}
+	data, err := os.ReadFile(cfg.File)
+	if os.IsNotExist(err) {
+		return n, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("notifications: %w", err)
+	}
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("notifications: %s: %w", cfg.File, err)
+	}
+	n.subscribers, n.incidents = s.Subscribers, s.Incidents
+	return n, nil
+}
+
+// Subscribe регистрирует запрос подписки и отправляет письмо со ссылкой подтверждения.
+// Для существующего подписчика новый набор систем применяется тоже только после подтверждения
+func (n *Notifier) Subscribe(email string, components []string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	email = strings.ToLower(addr.Address)
+	for _, c := range components {
+		if !contains(n.components, c) {
+			return fmt.Errorf("%w: unknown component %q (allowed: %s)", ErrInvalid, c, strings.Join(n.components, ", "))
+		}
+	}
+	n.mu.Lock()
+	var sub Subscriber
+	err = n.update(func(list []Subscriber) []Subscriber {
+		i := find(list, func(s Subscriber) bool { return s.Email == email })
+		if i < 0 {
+			list = append(list, Subscriber{Email: email, UnsubscribeToken: newToken(), CreatedAt: time.Now()})
+			i = len(list) - 1
+		}
+		list[i].ConfirmToken, list[i].Requested = newToken(), components
+		sub = list[i]
+		return list
+	})
+	n.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	m, err := n.render("confirm.tmpl", sub.Email, "", map[string]interface{}{
+		"Email":      sub.Email,
+		"Components": components,
+		"ConfirmURL": n.link("/subscriptions/confirm", sub.ConfirmToken),
+	})
+	if err != nil {
+		return err
+	}
+	n.deliver([]message{m})
+	return nil
+}
+
+// Confirm подтверждает подписку или изменение систем
+func (n *Notifier) Confirm(token string) (Subscriber, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	i := find(n.subscribers, func(s Subscriber) bool { return token != "" && s.ConfirmToken == token })
+	if i < 0 {
+		return Subscriber{}, ErrUnknownToken
+	}
+	var s Subscriber
+	err := n.update(func(list []Subscriber) []Subscriber {
+		list[i].Confirmed, list[i].Components, list[i].ConfirmToken, list[i].Requested = true, list[i].Requested, "", nil
+		s = list[i]
+		return list
+	})
+	if err != nil {
+		return Subscriber{}, err
+	}
+	return s, nil
+}
+
+// Unsubscribe удаляет подписчика
+func (n *Notifier) Unsubscribe(token string) (Subscriber, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	i := find(n.subscribers, func(s Subscriber) bool { return token != "" && s.UnsubscribeToken == token })
+	if i < 0 {
+		return Subscriber{}, ErrUnknownToken
+	}
+	s := n.subscribers[i]
+	err := n.update(func(list []Subscriber) []Subscriber {
+		return append(list[:i], list[i+1:]...)
+	})
+	if err != nil {
+		return Subscriber{}, err
+	}
+	return s, nil
+}
+
+// Subscribers возвращает копию списка подписчиков
+func (n *Notifier) Subscribers() []Subscriber {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]Subscriber(nil), n.subscribers...)
+}
+
+// Incidents сравнивает статусы инцидентов с предыдущим сбором и оповещает об открытых и закрытых.
+// Первый сбор (без сохраненного состояния) только запоминает статусы. Для инцидентов, для которых muted возвращает true
+// (плановые работы), статусы запоминаются, но письма не отправляются
+func (n *Notifier) Incidents(list []incident.IncidentData, muted func(inc incident.IncidentData) bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	current := make(map[string]string, len(list))
+	var messages []message
+	for _, inc := range list {
+		current[inc.Topic] = inc.Status
+		if n.incidents == nil {
+			continue
+		}
+		var transition string
+		switch prev, seen := n.incidents[inc.Topic]; {
+		case inc.Status == "active" && prev != "active":
+			transition = transitionOpened // новый или повторно открытый
+		case inc.Status == "closed" && seen && prev == "active":
+			transition = transitionResolved
+		default:
+			continue
+		}
+		if muted != nil && muted(inc) {
+			continue
+		}
+		messages = append(messages, n.messages("incident.tmpl", []string{inc.System}, map[string]interface{}{
+			"Incident":   inc,
+			"Transition": transition,
+		})...)
+	}
+	n.incidents = current // состояние обновляется и при ошибке записи: иначе следующий сбор повторил бы письма
+	if err := n.save(n.subscribers, n.incidents); err != nil {
+		fmt.Println("Error saving notification state:", err)
+	}
+	n.deliver(messages)
+}
+
+// Maintenance оповещает о запланированных работах подписчиков затронутых систем
+func (n *Notifier) Maintenance(a Announcement) {
+	n.mu.Lock()
+	messages := n.messages("maintenance.tmpl", a.Systems, map[string]interface{}{
+		"Systems": a.Systems,
+		"Start":   a.Start,
+		"End":     a.End,
+		"Message": a.Message,
+	})
+	n.mu.Unlock()
+	n.deliver(messages)
+}
+
+// messages готовит письма подтвержденным подписчикам, выбравшим одну из систем (или все системы). Вызывается под n.mu
+func (n *Notifier) messages(name string, systems []string, data map[string]interface{}) []message {
+	var list []message
+	for _, s := range n.subscribers {
+		if !s.Confirmed || !interested(s.Components, systems) {
+			continue
+		}
+		unsubscribeURL := n.link("/subscriptions/unsubscribe", s.UnsubscribeToken)
+		personal := map[string]interface{}{"Components": s.Components, "UnsubscribeURL": unsubscribeURL}
+		for k, v := range data {
+			personal[k] = v
+		}
+		m, err := n.render(name, s.Email, unsubscribeURL, personal)
+		if err != nil {
+			fmt.Printf("Error rendering %s: %v\n", name, err)
+			return nil
+		}
+		list = append(list, m)
+	}
+	return list
+}
+
+func interested(subscribed, systems []string) bool {
+	if len(subscribed) == 0 {
+		return true
+	}
+	for _, s := range systems {
+		if contains(subscribed, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *Notifier) link(path, token string) string {
+	return strings.TrimSuffix(n.cfg.BaseURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+func find(list []Subscriber, match func(Subscriber) bool) int {
+	for i, s := range list {
+		if match(s) {
+			return i
+		}
+	}
+	return -1
+}
+
+// update применяет change к копии списка подписчиков и сохраняет ее. Список в памяти заменяется только после
+// успешной записи, поэтому при ошибке подписка, подтверждение и отписка не происходят. Вызывается под n.mu
+func (n *Notifier) update(change func(list []Subscriber) []Subscriber) error {
+	list := change(append([]Subscriber(nil), n.subscribers...))
+	if err := n.save(list, n.incidents); err != nil {
+		return err
+	}
+	n.subscribers = list
+	return nil
+}
+
+func (n *Notifier) save(subscribers []Subscriber, incidents map[string]string) error { // вызывается под n.mu
+	data, err := json.MarshalIndent(state{Subscribers: subscribers, Incidents: incidents}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("notifications: %w", err)
+	}
+	if dir := filepath.Dir(n.cfg.File); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("notifications: %w", err)
+		}
+	}
+	tmp := n.cfg.File + ".tmp" // запись через временный файл: при сбое остается прежняя версия
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("notifications: %w", err)
+	}
+	if err := os.Rename(tmp, n.cfg.File); err != nil {
+		return fmt.Errorf("notifications: %w", err)
+	}
+	return nil
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+func newToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,222 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"finalwork/internal/incident"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type sent struct {
+	to      string
+	subject string
+	body    string
+}
+
+type outbox struct { // SMTP-сервер в памяти
+	mu   sync.Mutex
+	mail []sent
+}
+
+func (o *outbox) send(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+	head, body, _ := strings.Cut(string(msg), "\r\n\r\n")
+	var subject string
+	for _, line := range strings.Split(head, "\r\n") {
+		if strings.HasPrefix(line, "Subject: ") {
+			subject = strings.TrimPrefix(line, "Subject: ")
+		}
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.mail = append(o.mail, sent{to: strings.Join(to, ","), subject: subject, body: body})
+	return nil
+}
+
+// take ожидает отправки и возвращает отправленные письма, очищая ящик
+func (o *outbox) take(n *Notifier) []sent {
+	n.Wait()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	mail := o.mail
+	o.mail = nil
+	return mail
+}
+
+var components = []string{"sms", "email", "billing"}
+
+func newNotifier(t *testing.T, file string) (*Notifier, *outbox) {
+	t.Helper()
+	n, err := New(Config{File: file, BaseURL: "https://status.example.com/", SMTP: SMTP{Addr: "localhost:25", From: "status@example.com"}}, components)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := &outbox{}
+	n.send = o.send
+	return n, o
+}
+
+var tokenPattern = regexp.MustCompile(`token=([0-9a-f]+)`)
+
+// subscribe подписывает email на системы list и возвращает токен из письма подтверждения
+func subscribe(t *testing.T, n *Notifier, o *outbox, email string, list ...string) string {
+	t.Helper()
+	if err := n.Subscribe(email, list); err != nil {
+		t.Fatal(err)
+	}
+	mail := o.take(n)
+	if len(mail) != 1 || mail[0].subject != "Confirm your subscription to status updates" {
+		t.Fatalf("confirmation mail: %+v", mail)
+	}
+	m := tokenPattern.FindStringSubmatch(mail[0].body)
+	if m == nil || !strings.Contains(mail[0].body, "https://status.example.com/subscriptions/confirm?token=") {
+		t.Fatalf("no confirmation link in %q", mail[0].body)
+	}
+	return m[1]
+}
+
+func TestSubscription(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "state", "notify.json")
+	n, o := newNotifier(t, file)
+	for email, list := range map[string][]string{"not an address": nil, "a@example.com": {"fax"}} {
+		if err := n.Subscribe(email, list); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%q %v: got %v, want ErrInvalid", email, list, err)
+		}
+	}
+
+	token := subscribe(t, n, o, "User <User@Example.com>", "sms")
+	if s := n.Subscribers(); len(s) != 1 || s[0].Email != "user@example.com" || s[0].Confirmed {
+		t.Fatalf("before confirmation: %+v", s)
+	}
+	for _, bad := range []string{"", "deadbeef"} {
+		if _, err := n.Confirm(bad); !errors.Is(err, ErrUnknownToken) {
+			t.Errorf("token %q: got %v", bad, err)
+		}
+	}
+	s, err := n.Confirm(token)
+	if err != nil || !s.Confirmed || !reflect.DeepEqual(s.Components, []string{"sms"}) || s.ConfirmToken != "" {
+		t.Fatalf("confirmed: %+v, %v", s, err)
+	}
+	if _, err := n.Confirm(token); !errors.Is(err, ErrUnknownToken) { // ссылка одноразовая
+		t.Errorf("second confirmation: got %v", err)
+	}
+
+	token = subscribe(t, n, o, "user@example.com", "email") // изменение систем - тоже после подтверждения
+	if s := n.Subscribers(); len(s) != 1 || !reflect.DeepEqual(s[0].Components, []string{"sms"}) {
+		t.Fatalf("unconfirmed change applied: %+v", s)
+	}
+	if s, err := n.Confirm(token); err != nil || !reflect.DeepEqual(s.Components, []string{"email"}) {
+		t.Fatalf("confirmed change: %+v, %v", s, err)
+	}
+
+	saved, _ := newNotifier(t, file) // состояние переживает перезапуск
+	got, _ := json.Marshal(saved.Subscribers())
+	want, _ := json.Marshal(n.Subscribers())
+	if string(got) != string(want) {
+		t.Errorf("saved %s, in memory %s", got, want)
+	}
+
+	unsubscribeToken := n.Subscribers()[0].UnsubscribeToken
+	if s, err := n.Unsubscribe(unsubscribeToken); err != nil || s.Email != "user@example.com" || len(n.Subscribers()) != 0 {
+		t.Fatalf("unsubscribe: %+v, %v, left %+v", s, err, n.Subscribers())
+	}
+	if _, err := n.Unsubscribe(unsubscribeToken); !errors.Is(err, ErrUnknownToken) {
+		t.Errorf("second unsubscribe: got %v", err)
+	}
+}
+
+func TestSaveFailure(t *testing.T) { // при ошибке записи состояние в памяти не меняется
+	dir := t.TempDir()
+	n, o := newNotifier(t, filepath.Join(dir, "notify.json"))
+	confirmed := subscribe(t, n, o, "a@example.com")
+	if _, err := n.Confirm(confirmed); err != nil {
+		t.Fatal(err)
+	}
+	pending := subscribe(t, n, o, "b@example.com")
+	before := n.Subscribers()
+
+	blocker := filepath.Join(dir, "blocker")
+	if err := os.WriteFile(blocker, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	n.cfg.File = filepath.Join(blocker, "notify.json") // директория - обычный файл: запись не удается
+	if _, err := n.Confirm(pending); err == nil {
+		t.Error("confirm: no error")
+	}
+	if _, err := n.Unsubscribe(before[0].UnsubscribeToken); err == nil {
+		t.Error("unsubscribe: no error")
+	}
+	if err := n.Subscribe("c@example.com", nil); err == nil {
+		t.Error("subscribe: no error")
+	}
+	if got := n.Subscribers(); !reflect.DeepEqual(got, before) {
+		t.Errorf("state changed after failed saves:\n%+v\n%+v", got, before)
+	}
+	if mail := o.take(n); len(mail) != 0 {
+		t.Errorf("mail sent after a failed save: %+v", mail)
+	}
+
+	n.cfg.File = filepath.Join(dir, "notify.json")
+	if s, err := n.Confirm(pending); err != nil || !s.Confirmed { // ссылка осталась действительной
+		t.Errorf("confirm after recovery: %+v, %v", s, err)
+	}
+}
+
+func TestIncidents(t *testing.T) {
+	n, o := newNotifier(t, filepath.Join(t.TempDir(), "notify.json"))
+	for email, list := range map[string][]string{"all@example.com": nil, "sms@example.com": {"sms"}, "email@example.com": {"email"}} {
+		if _, err := n.Confirm(subscribe(t, n, o, email, list...)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	subscribe(t, n, o, "pending@example.com") // без подтверждения писем нет
+
+	sms := func(status string) incident.IncidentData {
+		return incident.IncidentData{Topic: "SMS delivery in EU", Status: status, Severity: "major", System: "sms"}
+	}
+	billing := func(status string) incident.IncidentData {
+		return incident.IncidentData{Topic: "Billing outage", Status: status, Severity: "critical", System: "billing"}
+	}
+	var mutedTopic string
+	muted := func(inc incident.IncidentData) bool { return inc.Topic == mutedTopic }
+	for _, step := range []struct {
+		name  string
+		list  []incident.IncidentData
+		muted string
+		want  []string // письма: получатель и тема
+	}{
+		{"first collection only remembers", []incident.IncidentData{sms("active")}, "", nil},
+		{"still active", []incident.IncidentData{sms("active")}, "", nil},
+		{"resolved", []incident.IncidentData{sms("closed")}, "", []string{
+			"all@example.com [Resolved] SMS delivery in EU", "sms@example.com [Resolved] SMS delivery in EU"}},
+		{"still closed", []incident.IncidentData{sms("closed")}, "", nil},
+		{"reopened", []incident.IncidentData{sms("active"), billing("closed")}, "", []string{
+			"all@example.com [Major incident] SMS delivery in EU", "sms@example.com [Major incident] SMS delivery in EU"}},
+		{"opened during maintenance", []incident.IncidentData{sms("active"), billing("active")}, "Billing outage", nil},
+		{"resolved during maintenance", []incident.IncidentData{sms("active"), billing("closed")}, "Billing outage", nil},
+		{"opened", []incident.IncidentData{billing("active")}, "", []string{"all@example.com [Critical incident] Billing outage"}},
+		{"gone from the list", nil, "", nil},
+		{"new active", []incident.IncidentData{billing("active")}, "", []string{"all@example.com [Critical incident] Billing outage"}},
+	} {
+		mutedTopic = step.muted
+		n.Incidents(step.list, muted)
+		var got []string
+		for _, m := range o.take(n) {
+			got = append(got, m.to+" "+m.subject)
+			if !strings.Contains(m.body, "https://status.example.com/subscriptions/unsubscribe?token=") {
+				t.Errorf("%s: no unsubscribe link in %q", step.name, m.body)
+			}
+		}
+		sort.Strings(got) // порядок писем зависит от порядка подписчиков
+		if !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: got %q, want %q", step.name, got, step.want)
+		}
+	}
+}
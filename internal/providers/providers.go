@@ -0,0 +1,104 @@
+package providers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Channel string // канал (система), который обслуживает провайдер
+
+const (
+	SMS   Channel = "sms"
+	MMS   Channel = "mms"
+	Voice Channel = "voice"
+	Email Channel = "email"
+)
+
+var Channels = []Channel{SMS, MMS, Voice, Email} // все известные каналы
+
+type Provider struct {
+	Name     string    `json:"name"`              // каноническое имя, под которым провайдер попадает в ответ
+	Aliases  []string  `json:"aliases,omitempty"` // другие написания имени (например, как его присылает система-источник)
+	Channels []Channel `json:"channels"`          // каналы, в которых провайдер допустим
+}
+
+type Catalog struct {
+	providers []Provider           // провайдеры в порядке из конфигурации
+	byName    map[string]*Provider // ключ - имя или псевдоним в нижнем регистре
+}
+
+func normalize(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// NewCatalog строит каталог и проверяет, что имена и псевдонимы не пересекаются, а каналы известны
+func NewCatalog(list []Provider) (*Catalog, error) {
+	c := &Catalog{
+		providers: make([]Provider, len(list)),
+		byName:    make(map[string]*Provider),
+	}
+	copy(c.providers, list)
+	known := make(map[Channel]struct{})
+	for _, ch := range Channels {
+		known[ch] = struct{}{}
+	}
+	for i := range c.providers {
+		p := &c.providers[i]
+		if strings.TrimSpace(p.Name) == "" {
+			return nil, fmt.Errorf("providers: provider #%d has no name", i+1)
+		}
+		if len(p.Channels) == 0 {
+			return nil, fmt.Errorf("providers: provider %q has no channels", p.Name)
+		}
+		for _, ch := range p.Channels {
+			if _, ok := known[ch]; !ok {
+				return nil, fmt.Errorf("providers: provider %q has unknown channel %q", p.Name, ch)
+			}
+		}
+		for _, name := range append([]string{p.Name}, p.Aliases...) {
+			key := normalize(name)
+			if other, ok := c.byName[key]; ok && other != p {
+				return nil, fmt.Errorf("providers: name %q is used by both %q and %q", name, other.Name, p.Name)
+			}
+			c.byName[key] = p
+		}
+	}
+	return c, nil
+}
+
+// Lookup ищет провайдера по имени или псевдониму без учета регистра
+func (c *Catalog) Lookup(name string) (Provider, bool) {
+	p, ok := c.byName[normalize(name)]
+	if !ok {
+		return Provider{}, false
+	}
+	return *p, true
+}
+
+// Canonical возвращает каноническое имя провайдера, если он допустим в канале ch
+func (c *Catalog) Canonical(ch Channel, name string) (string, bool) {
+	p, ok := c.byName[normalize(name)]
+	if !ok || !p.Serves(ch) {
+		return "", false
+	}
+	return p.Name, true
+}
+
+// Serves сообщает, работает ли провайдер в канале ch
+func (p Provider) Serves(ch Channel) bool {
+	for _, v := range p.Channels {
+		if v == ch {
+			return true
+		}
+	}
+	return false
+}
+
+// List возвращает провайдеров, отсортированных по имени
+func (c *Catalog) List() []Provider {
+	list := make([]Provider, len(c.providers))
+	copy(list, c.providers)
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
@@ -0,0 +1,99 @@
+package providers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testList = []Provider{
+	{Name: "Rond", Channels: []Channel{SMS, MMS}},
+	{Name: "Proton Mail", Aliases: []string{"Protonmail", "PROTON"}, Channels: []Channel{Email}},
+	{Name: "E-Voice", Channels: []Channel{Voice}},
+}
+
+func TestNewCatalog(t *testing.T) {
+	c, err := NewCatalog(testList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testList[0].Name = "Changed" // каталог хранит свою копию списка
+	defer func() { testList[0].Name = "Rond" }()
+	var names []string
+	for _, p := range c.List() {
+		names = append(names, p.Name)
+	}
+	if want := []string{"E-Voice", "Proton Mail", "Rond"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("List: got %v, want %v", names, want)
+	}
+
+	for _, tc := range []struct {
+		list []Provider
+		err  string
+	}{
+		{[]Provider{{Name: " ", Channels: []Channel{SMS}}}, "has no name"},
+		{[]Provider{{Name: "Rond"}}, "has no channels"},
+		{[]Provider{{Name: "Rond", Channels: []Channel{"fax"}}}, "unknown channel"},
+		{[]Provider{{Name: "Rond", Channels: []Channel{SMS}}, {Name: "rond", Channels: []Channel{MMS}}}, `name "rond" is used by both "Rond" and "rond"`},
+		{[]Provider{{Name: "Rond", Channels: []Channel{SMS}}, {Name: "Kildy", Aliases: []string{" ROND "}, Channels: []Channel{SMS}}}, `is used by both "Rond" and "Kildy"`},
+		{[]Provider{{Name: "Gmail", Aliases: []string{"Yahoo"}, Channels: []Channel{Email}}, {Name: "Yahoo", Channels: []Channel{Email}}}, `is used by both "Gmail" and "Yahoo"`},
+	} {
+		if _, err := NewCatalog(tc.list); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%+v: got %v, want an error with %q", tc.list, err, tc.err)
+		}
+	}
+	if _, err := NewCatalog([]Provider{{Name: "Rond", Aliases: []string{"rond", "RONDO"}, Channels: []Channel{SMS}}}); err != nil {
+		t.Errorf("alias equal to the own name: %v", err) // свое имя в другом регистре - не пересечение
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	c, err := NewCatalog(testList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		ch   Channel
+		name string
+		want string
+		ok   bool
+	}{
+		{SMS, "Rond", "Rond", true},
+		{MMS, "rOND", "Rond", true},
+		{Email, " protonmail ", "Proton Mail", true},
+		{Email, "proton", "Proton Mail", true},
+		{Voice, "e-voice", "E-Voice", true},
+		{Voice, "Rond", "", false}, // провайдер известен, но не работает в канале
+		{SMS, "Topolo", "", false},
+		{SMS, "", "", false},
+	} {
+		got, ok := c.Canonical(tc.ch, tc.name)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("%s %q: got %q, %v, want %q, %v", tc.ch, tc.name, got, ok, tc.want, tc.ok)
+		}
+	}
+	if p, ok := c.Lookup("PROTONMAIL"); !ok || p.Name != "Proton Mail" || !p.Serves(Email) || p.Serves(SMS) {
+		t.Errorf("Lookup: got %+v, %v", p, ok)
+	}
+}
+
+func TestRowStats(t *testing.T) {
+	c, err := NewCatalog(testList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewRowStats()
+	s.Accept("Rond")
+	s.Reject(c, "protonmail")
+	s.Reject(c, "Topolo")
+	s.RejectMalformed("RU;1")
+	s.RejectMalformed("  ") // пустая строка не считается
+	want := &RowStats{Accepted: map[string]int{"Rond": 1}, Rejected: map[string]int{"Proton Mail": 1}, Unattributed: 2}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("got %+v, want %+v", s, want)
+	}
+	var none *RowStats // nil - подсчет не ведется
+	none.Accept("Rond")
+	none.Reject(c, "Rond")
+	none.RejectMalformed("RU")
+}
@@ -0,0 +1,47 @@
+package providers
+
+import "strings"
+
+// RowStats считает принятые и отброшенные строки данных по провайдерам одного канала.
+// Методы можно вызывать на nil - тогда подсчет не ведется.
+type RowStats struct {
+	Accepted     map[string]int `json:"accepted"`     // каноническое имя -> принятые строки
+	Rejected     map[string]int `json:"rejected"`     // каноническое имя -> отброшенные строки
+	Unattributed int            `json:"unattributed"` // отброшенные строки, провайдера которых определить не удалось
+}
+
+func NewRowStats() *RowStats {
+	return &RowStats{
+		Accepted: make(map[string]int),
+		Rejected: make(map[string]int),
+	}
+}
+
+// Accept учитывает принятую строку провайдера с каноническим именем name
+func (s *RowStats) Accept(name string) {
+	if s == nil {
+		return
+	}
+	s.Accepted[name]++
+}
+
+// Reject учитывает отброшенную строку. Провайдер определяется по каталогу, если имя в строке распознается
+func (s *RowStats) Reject(catalog *Catalog, rawProvider string) {
+	if s == nil {
+		return
+	}
+	if p, ok := catalog.Lookup(rawProvider); ok {
+		s.Rejected[p.Name]++
+		return
+	}
+	s.Unattributed++
+}
+
+// RejectMalformed учитывает строку, в которой не хватает полей. Пустые строки (в том числе после последнего
+// перевода строки в файле) не учитываются
+func (s *RowStats) RejectMalformed(line string) {
+	if s == nil || strings.TrimSpace(line) == "" {
+		return
+	}
+	s.Unattributed++
+}
@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	Enabled    bool    `json:"enabled"`
+	Rate       float64 `json:"rate"`        // пополнение корзины, запросов в секунду
+	Burst      int     `json:"burst"`       // емкость корзины: сколько запросов можно сделать подряд
+	TrustProxy bool    `json:"trust_proxy"` // брать IP клиента из последнего адреса X-Forwarded-For, добавленного своим прокси
+}
+
+// Validate проверяет параметры включенного ограничения
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Burst <= 0 {
+		return fmt.Errorf("rate_limit: burst must be positive, got %d", c.Burst)
+	}
+	if c.Rate < 0 {
+		return fmt.Errorf("rate_limit: rate must not be negative, got %v", c.Rate)
+	}
+	return nil
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter - корзины токенов (token bucket) по ключу клиента
+type Limiter struct {
+	cfg     Config
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+	swept   time.Time
+}
+
+func New(cfg Config) *Limiter {
+	return &Limiter{cfg: cfg, buckets: make(map[string]*bucket), now: time.Now}
+}
+
+// Allow забирает токен из корзины клиента key. Если токенов нет, возвращает время до появления следующего
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.cfg.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(l.cfg.Burst), b.tokens+now.Sub(b.last).Seconds()*l.cfg.Rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	if l.cfg.Rate <= 0 {
+		return false, time.Hour
+	}
+	wait := time.Duration((1 - b.tokens) / l.cfg.Rate * float64(time.Second))
+	return false, wait
+}
+
+func (l *Limiter) sweep(now time.Time) { // раз в минуту удаляем полные корзины, чтобы map не рос бесконечно
+	if now.Sub(l.swept) < time.Minute {
+		return
+	}
+	l.swept = now
+	for key, b := range l.buckets {
+		if l.cfg.Rate > 0 && b.tokens+now.Sub(b.last).Seconds()*l.cfg.Rate >= float64(l.cfg.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Middleware ограничивает частоту запросов. Ключ клиента вычисляет keyFunc (например, имя API-ключа или IP)
+func (l *Limiter) Middleware(keyFunc func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !l.cfg.Enabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ok, wait := l.Allow(keyFunc(r))
+			if !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClientIP возвращает IP клиента. При trustProxy берется последний адрес из X-Forwarded-For: его добавил свой прокси,
+// а адреса левее клиент может подставить сам
+func ClientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		values := r.Header.Values("X-Forwarded-For")
+		if len(values) > 0 {
+			list := strings.Split(values[len(values)-1], ",")
+			if ip := net.ParseIP(strings.TrimSpace(list[len(list)-1])); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,128 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientIP(t *testing.T) {
+	for _, tc := range []struct {
+		xff        []string
+		trustProxy bool
+		want       string
+	}{
+		{nil, true, "192.0.2.1"},
+		{[]string{"203.0.113.7"}, false, "192.0.2.1"},
+		{[]string{"203.0.113.7"}, true, "203.0.113.7"},
+		{[]string{"1.1.1.1, 2.2.2.2, 203.0.113.7"}, true, "203.0.113.7"}, // адреса левее подставлены клиентом
+		{[]string{"1.1.1.1", "203.0.113.7"}, true, "203.0.113.7"},        // прокси добавил отдельный заголовок
+		{[]string{"1.1.1.1, garbage"}, true, "192.0.2.1"},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		for _, v := range tc.xff {
+			r.Header.Add("X-Forwarded-For", v)
+		}
+		if got := ClientIP(r, tc.trustProxy); got != tc.want {
+			t.Errorf("%q trust=%v: got %s, want %s", tc.xff, tc.trustProxy, got, tc.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, c := range []Config{{Enabled: true, Rate: 1, Burst: 0}, {Enabled: true, Rate: -1, Burst: 5}} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("%+v: expected an error", c)
+		}
+	}
+	if err := (Config{Enabled: false}).Validate(); err != nil {
+		t.Errorf("disabled limiter: %v", err)
+	}
+	if err := (Config{Enabled: true, Rate: 0.5, Burst: 1}).Validate(); err != nil {
+		t.Errorf("valid config: %v", err)
+	}
+}
+
+func TestAllow(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	l := New(Config{Enabled: true, Rate: 2, Burst: 3})
+	l.now = func() time.Time { return now }
+	for i := 0; i < 3; i++ { // полная корзина: burst запросов подряд
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("request %d of the burst rejected", i)
+		}
+	}
+	if ok, wait := l.Allow("a"); ok || wait != 500*time.Millisecond {
+		t.Fatalf("empty bucket: got %v, %v, want false, 500ms", ok, wait)
+	}
+	if ok, _ := l.Allow("b"); !ok { // у другого клиента своя корзина
+		t.Fatal("another key rejected")
+	}
+
+	now = now.Add(250 * time.Millisecond) // половина токена
+	if ok, wait := l.Allow("a"); ok || wait != 250*time.Millisecond {
+		t.Fatalf("half a token: got %v, %v, want false, 250ms", ok, wait)
+	}
+	now = now.Add(250 * time.Millisecond)
+	if ok, _ := l.Allow("a"); !ok {
+		t.Fatal("refilled token rejected")
+	}
+	now = now.Add(time.Hour) // корзина не наполняется сверх burst
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("request %d after refill rejected", i)
+		}
+	}
+	if ok, _ := l.Allow("a"); ok {
+		t.Error("bucket refilled above burst")
+	}
+
+	l = New(Config{Enabled: true, Rate: 0, Burst: 1}) // без пополнения
+	l.now = func() time.Time { return now }
+	l.Allow("a")
+	if ok, wait := l.Allow("a"); ok || wait != time.Hour {
+		t.Errorf("zero rate: got %v, %v, want false, 1h", ok, wait)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	l := New(Config{Enabled: true, Rate: 0.4, Burst: 1})
+	l.now = func() time.Time { return now }
+	h := l.Middleware(func(r *http.Request) string { return r.Header.Get("X-Client") })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(client string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Client", client)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec
+	}
+
+	if rec := serve("a"); rec.Code != http.StatusOK || rec.Header().Get("Retry-After") != "" {
+		t.Fatalf("first request: %d, headers %v", rec.Code, rec.Header())
+	}
+	for _, tc := range []struct {
+		after time.Duration
+		want  string
+	}{
+		{0, "3"}, // 2.5 с округляются вверх
+		{2 * time.Second, "1"},
+	} {
+		now = now.Add(tc.after)
+		if rec := serve("a"); rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != tc.want {
+			t.Errorf("after %v: got %d, Retry-After %q, want 429 and %s", tc.after, rec.Code, rec.Header().Get("Retry-After"), tc.want)
+		}
+	}
+	if rec := serve("b"); rec.Code != http.StatusOK {
+		t.Errorf("another client: got %d", rec.Code)
+	}
+
+	l.cfg.Enabled = false
+	if rec := serve("a"); rec.Code != http.StatusOK {
+		t.Errorf("disabled limiter: got %d", rec.Code)
+	}
+}
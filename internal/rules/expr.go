@@ -0,0 +1,278 @@
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Выражения условий правил: сравнения чисел и логических значений с переменными собранных данных.
+//   billing.failed > 0
+//   support.load >= 3 && !support.available
+//   component.incidents > 0 or component.incident_severity >= critical
+// Операции: || (or), && (and), ! (not), ==, !=, <, <=, >, >=, скобки. Значения - числа и true/false.
+
+type Vars map[string]interface{} // имя переменной -> float64 или bool
+
+type node interface {
+	eval(v Vars, prefix string) (interface{}, error)
+}
+
+type (
+	literal struct{ value interface{} }
+	ident   struct{ name string }
+	not     struct{ x node }
+	logical struct {
+		op   string // "&&" или "||"
+		x, y node
+	}
+	compare struct {
+		op   string
+		x, y node
+	}
+)
+
+// componentPrefix - в правилах для всех компонентов ("component": "*") заменяется именем компонента
+const componentPrefix = "component."
+
+func (n literal) eval(Vars, string) (interface{}, error) { return n.value, nil }
+
+func (n ident) eval(v Vars, component string) (interface{}, error) {
+	name := n.name
+	if strings.HasPrefix(name, componentPrefix) {
+		if component == "" {
+			return nil, fmt.Errorf("%s: %q is only allowed in rules for all components", name, strings.TrimSuffix(componentPrefix, "."))
+		}
+		name = component + "." + strings.TrimPrefix(name, componentPrefix)
+	}
+	value, ok := v[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown variable %q", name)
+	}
+	return value, nil
+}
+
+func (n not) eval(v Vars, component string) (interface{}, error) {
+	x, err := evalBool(n.x, v, component)
+	return !x, err
+}
+
+func (n logical) eval(v Vars, component string) (interface{}, error) {
+	x, err := evalBool(n.x, v, component)
+	if err != nil {
+		return nil, err
+	}
+	y, err := evalBool(n.y, v, component) // без короткого замыкания: ошибки в правой части видны при проверке настроек
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "&&" {
+		return x && y, nil
+	}
+	return x || y, nil
+}
+
+func (n compare) eval(v Vars, component string) (interface{}, error) {
+	x, err := n.x.eval(v, component)
+	if err != nil {
+		return nil, err
+	}
+	y, err := n.y.eval(v, component)
+	if err != nil {
+		return nil, err
+	}
+	switch a := x.(type) {
+	case float64:
+		b, ok := y.(float64)
+		if !ok {
+			return nil, fmt.Errorf("%s: cannot compare a number with %v", n.op, y)
+		}
+		switch n.op {
+		case "==":
+			return a == b, nil
+		case "!=":
+			return a != b, nil
+		case "<":
+			return a < b, nil
+		case "<=":
+			return a <= b, nil
+		case ">":
+			return a > b, nil
+		case ">=":
+			return a >= b, nil
+		}
+	case bool:
+		b, ok := y.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s: cannot compare a boolean with %v", n.op, y)
+		}
+		switch n.op {
+		case "==":
+			return a == b, nil
+		case "!=":
+			return a != b, nil
+		}
+		return nil, fmt.Errorf("%s: booleans can only be compared with == and !=", n.op)
+	}
+	return nil, fmt.Errorf("%s: unsupported value %v", n.op, x)
+}
+
+func evalBool(n node, v Vars, component string) (bool, error) {
+	x, err := n.eval(v, component)
+	if err != nil {
+		return false, err
+	}
+	b, ok := x.(bool)
+	if !ok {
+		return false, fmt.Errorf("expected a condition, got %v", x)
+	}
+	return b, nil
+}
+
+// разбор: рекурсивный спуск по лексемам
+
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+func parse(src string) (node, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	return n, nil
+}
+
+func (p *parser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *parser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *parser) or() (node, error) {
+	x, err := p.and()
+	for err == nil && (p.peek() == "||" || p.peek() == "or") {
+		p.next()
+		var y node
+		if y, err = p.and(); err == nil {
+			x = logical{op: "||", x: x, y: y}
+		}
+	}
+	return x, err
+}
+
+func (p *parser) and() (node, error) {
+	x, err := p.unary()
+	for err == nil && (p.peek() == "&&" || p.peek() == "and") {
+		p.next()
+		var y node
+		if y, err = p.unary(); err == nil {
+			x = logical{op: "&&", x: x, y: y}
+		}
+	}
+	return x, err
+}
+
+func (p *parser) unary() (node, error) {
+	if p.peek() == "!" || p.peek() == "not" {
+		p.next()
+		x, err := p.unary()
+		return not{x}, err
+	}
+	x, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	switch op := p.peek(); op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		p.next()
+		y, err := p.primary()
+		return compare{op: op, x: x, y: y}, err
+	}
+	return x, nil
+}
+
+func (p *parser) primary() (node, error) {
+	t := p.next()
+	switch {
+	case t == "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case t == "(":
+		x, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		return x, nil
+	case t == "true" || t == "false":
+		return literal{t == "true"}, nil
+	case unicode.IsDigit(rune(t[0])):
+		f, err := strconv.ParseFloat(t, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %q", t)
+		}
+		return literal{f}, nil
+	case isIdentStart(rune(t[0])):
+		return ident{t}, nil
+	}
+	return nil, fmt.Errorf("unexpected %q", t)
+}
+
+func isIdentStart(r rune) bool { return r == '_' || unicode.IsLetter(r) }
+
+func isIdentPart(r rune) bool { return isIdentStart(r) || unicode.IsDigit(r) || r == '.' }
+
+func tokenize(src string) ([]string, error) {
+	var tokens []string
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case isIdentStart(r) || unicode.IsDigit(r):
+			j := i
+			for j < len(runes) && isIdentPart(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+		case strings.ContainsRune("()", r):
+			tokens = append(tokens, string(r))
+			i++
+		default:
+			op := ""
+			for _, candidate := range []string{"||", "&&", "==", "!=", "<=", ">=", "<", ">", "!"} {
+				if strings.HasPrefix(string(runes[i:]), candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q", r)
+			}
+			tokens = append(tokens, op)
+			i += len(op)
+		}
+	}
+	return tokens, nil
+}
@@ -0,0 +1,203 @@
+package rules
+
+import (
+	"fmt"
+)
+
+// Общее состояние и состояние компонентов (систем), выведенные правилами из собранных данных.
+// Состояние компонента - худшее из сработавших для него правил, общее состояние - худшее из состояний компонентов и общих правил.
+
+const (
+	Operational   = "operational"
+	Degraded      = "degraded"
+	PartialOutage = "partial_outage"
+	MajorOutage   = "major_outage"
+)
+
+var states = []string{Operational, Degraded, PartialOutage, MajorOutage} // от лучшего к худшему
+
+const UnderMaintenance = "under_maintenance" // состояние компонента на плановых работах: вне шкалы, в общее состояние не входит
+
+const ( // происхождение пояснения
+	KindMaintenance = "maintenance" // плановые работы
+	KindOverride    = "override"    // состояние задано оператором вручную
+)
+
+// Rank возвращает порядок состояния (0 - operational), -1 для неизвестного значения
+func Rank(state string) int {
+	for i, s := range states {
+		if s == state {
+			return i
+		}
+	}
+	return -1
+}
+
+// Worse возвращает худшее из двух состояний
+func Worse(a, b string) string {
+	if Rank(b) > Rank(a) {
+		return b
+	}
+	return a
+}
+
+type Rule struct {
+	Component string `json:"component,omitempty"` // компонент; "*" - каждый компонент (в условии доступен префикс component.), пусто - только общее состояние
+	When      string `json:"when"`                // условие, например "billing.failed > 0"
+	State     string `json:"state"`               // состояние, если условие выполнено
+	Message   string `json:"message,omitempty"`   // пояснение для ответа
+}
+
+type Engine struct {
+	rules      []compiledRule
+	components []string
+}
+
+type compiledRule struct {
+	Rule
+	cond node
+}
+
+type Result struct {
+	State      string            `json:"state"`      // общее состояние
+	Components map[string]string `json:"components"` // компонент -> состояние
+	Reasons    []Reason          `json:"reasons"`    // сработавшие правила в порядке из настроек
+}
+
+type Reason struct {
+	Component  string `json:"component,omitempty"`
+	State      string `json:"state"`
+	Rule       string `json:"rule,omitempty"` // условие правила (в агрегированном ответе не отдается)
+	Message    string `json:"message,omitempty"`
+	Kind       string `json:"kind,omitempty"`       // пусто - правило, иначе KindMaintenance или KindOverride
+	Suppressed bool   `json:"suppressed,omitempty"` // правило сработало для компонента на плановых работах и на состояние не влияет
+}
+
+// New компилирует правила. sample - переменные с нулевыми значениями: на них проверяются имена и типы в условиях
+func New(list []Rule, components []string, sample Vars) (*Engine, error) {
+	e := &Engine{components: components}
+	known := make(map[string]bool)
+	for _, c := range components {
+		known[c] = true
+	}
+	for i, r := range list {
+		if Rank(r.State) < 0 {
+			return nil, fmt.Errorf("status rules: rule #%d: unknown state %q (allowed: operational, degraded, partial_outage, major_outage)", i+1, r.State)
+		}
+		if r.Component != "" && r.Component != "*" && !known[r.Component] {
+			return nil, fmt.Errorf("status rules: rule #%d: unknown component %q", i+1, r.Component)
+		}
+		cond, err := parse(r.When)
+		if err != nil {
+			return nil, fmt.Errorf("status rules: rule #%d %q: %w", i+1, r.When, err)
+		}
+		cr := compiledRule{Rule: r, cond: cond}
+		for _, c := range cr.targets(components) {
+			if _, err := evalBool(cond, sample, c); err != nil {
+				return nil, fmt.Errorf("status rules: rule #%d %q: %w", i+1, r.When, err)
+			}
+		}
+		e.rules = append(e.rules, cr)
+	}
+	return e, nil
+}
+
+func (r compiledRule) targets(components []string) []string {
+	switch r.Component {
+	case "*":
+		return components
+	case "":
+		return []string{""}
+	}
+	return []string{r.Component}
+}
+
+// Evaluate применяет правила к переменным собранных данных
+func (e *Engine) Evaluate(v Vars) Result {
+	res := Result{State: Operational, Components: make(map[string]string), Reasons: []Reason{}}
+	for _, c := range e.components {
+		res.Components[c] = Operational
+	}
+	for _, r := range e.rules {
+		for _, c := range r.targets(e.components) {
+			ok, err := evalBool(r.cond, v, c)
+			if err != nil { // типы проверены при загрузке, сюда попадает только отсутствующая переменная
+				fmt.Printf("Status rule %q: %v\n", r.When, err)
+				continue
+			}
+			if !ok {
+				continue
+			}
+			if c != "" {
+				res.Components[c] = Worse(res.Components[c], r.State)
+			}
+			res.Reasons = append(res.Reasons, Reason{Component: c, State: r.State, Rule: r.When, Message: r.Message})
+		}
+	}
+	res.overall()
+	return res
+}
+
+// Apply возвращает копию результата с плановыми работами (maintenance: компонент и сообщение) и ручными состояниями
+// (overrides: компонент, состояние и сообщение; пустой компонент - общее состояние). Компоненты на плановых работах
+// в общее состояние не входят, ручное состояние заменяет вычисленное
+func (r Result) Apply(maintenance, overrides []Reason) Result {
+	res := Result{Components: make(map[string]string, len(r.Components)), Reasons: make([]Reason, len(r.Reasons))}
+	for c, state := range r.Components {
+		res.Components[c] = state
+	}
+	copy(res.Reasons, r.Reasons)
+	for _, m := range maintenance {
+		if _, ok := res.Components[m.Component]; !ok {
+			continue
+		}
+		res.Components[m.Component] = UnderMaintenance
+		for i := range res.Reasons {
+			if res.Reasons[i].Component == m.Component && res.Reasons[i].Kind == "" {
+				res.Reasons[i].Suppressed = true
+			}
+		}
+		res.Reasons = append(res.Reasons, Reason{Component: m.Component, State: UnderMaintenance, Message: m.Message, Kind: KindMaintenance})
+	}
+	for _, o := range overrides {
+		if _, ok := res.Components[o.Component]; ok {
+			res.Components[o.Component] = o.State
+		} else if o.Component != "" {
+			continue
+		}
+		res.Reasons = append(res.Reasons, Reason{Component: o.Component, State: o.State, Message: o.Message, Kind: KindOverride})
+	}
+	res.overall()
+	return res
+}
+
+// overall вычисляет общее состояние: худшее из состояний компонентов и общих правил; последнее ручное общее состояние заменяет его
+func (r *Result) overall() {
+	r.State = Operational
+	for _, state := range r.Components {
+		if state != UnderMaintenance {
+			r.State = Worse(r.State, state)
+		}
+	}
+	for _, reason := range r.Reasons {
+		if reason.Component == "" && reason.Kind == "" {
+			r.State = Worse(r.State, reason.State)
+		}
+	}
+	for _, reason := range r.Reasons {
+		if reason.Component == "" && reason.Kind == KindOverride {
+			r.State = reason.State
+		}
+	}
+}
+
+// Public возвращает результат без условий правил: они раскрывают внутренние метрики
+func (r Result) Public() Result {
+	reasons := make([]Reason, len(r.Reasons))
+	for i, reason := range r.Reasons {
+		reason.Rule = ""
+		reasons[i] = reason
+	}
+	r.Reasons = reasons
+	return r
+}
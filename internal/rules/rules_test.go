@@ -0,0 +1,93 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+)
+
+var testVars = Vars{
+	"billing.failed":    0.0,
+	"billing.purchase":  true,
+	"sms.available":     true,
+	"sms.incidents":     0.0,
+	"billing.available": true,
+	"billing.incidents": 0.0,
+	"support.load":      0.0,
+	"critical":          3.0,
+}
+
+func TestExpressions(t *testing.T) {
+	v := Vars{"a": 2.0, "b": 3.5, "ok": true, "no": false}
+	for expr, want := range map[string]bool{
+		"a < b":                      true,
+		"a >= 2 && b <= 3":           false,
+		"a == 1 || b > 3":            true,
+		"!(a == 2)":                  false,
+		"not ok or no == false":      true,
+		"ok and (no || a != 2)":      false,
+		"ok == true && !no":          true,
+		"a > 1 && a < 3 && b == 3.5": true,
+	} {
+		n, err := parse(expr)
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		if got, err := evalBool(n, v, ""); err != nil || got != want {
+			t.Errorf("%s: got %v, %v, want %v", expr, got, err, want)
+		}
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	components := []string{"sms", "billing"}
+	for _, tc := range []struct {
+		rule Rule
+		want string
+	}{
+		{Rule{When: "billing.failed >", State: Degraded}, "unexpected end"},
+		{Rule{When: "billing.failed > 0)", State: Degraded}, "unexpected"},
+		{Rule{When: "billing.fail > 0", State: Degraded}, "unknown variable"},
+		{Rule{When: "billing.failed > true", State: Degraded}, "cannot compare"},
+		{Rule{When: "billing.purchase < true", State: Degraded}, "only be compared"},
+		{Rule{When: "billing.failed", State: Degraded}, "expected a condition"},
+		{Rule{When: "billing.failed > 0", State: "red"}, "unknown state"},
+		{Rule{Component: "fax", When: "billing.failed > 0", State: Degraded}, "unknown component"},
+		{Rule{When: "component.incidents > 0", State: Degraded}, "only allowed"},
+		{Rule{When: "support.load @ 3", State: Degraded}, "unexpected character"},
+	} {
+		if _, err := New([]Rule{tc.rule}, components, testVars); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%+v: got %v, want an error containing %q", tc.rule, err, tc.want)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	e, err := New([]Rule{
+		{Component: "*", When: "!component.available", State: MajorOutage},
+		{Component: "*", When: "component.incidents > 0", State: Degraded, Message: "active incident"},
+		{Component: "billing", When: "billing.failed > 0", State: PartialOutage},
+		{When: "support.load >= 3", State: Degraded}, // только общее состояние
+	}, []string{"sms", "billing"}, testVars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := Vars{}
+	for k, x := range testVars {
+		v[k] = x
+	}
+	if res := e.Evaluate(v); res.State != Operational || len(res.Reasons) != 0 || res.Components["sms"] != Operational {
+		t.Errorf("all good: got %+v", res)
+	}
+	v["sms.incidents"], v["billing.incidents"], v["billing.failed"], v["support.load"] = 1.0, 2.0, 1.0, 3.0
+	res := e.Evaluate(v)
+	if res.State != PartialOutage || res.Components["sms"] != Degraded || res.Components["billing"] != PartialOutage || len(res.Reasons) != 4 {
+		t.Errorf("got %+v", res)
+	}
+	if r := res.Reasons[3]; r.Component != "" || r.State != Degraded {
+		t.Errorf("overall-only rule: got %+v", r)
+	}
+	v["sms.available"] = false
+	if res := e.Evaluate(v); res.State != MajorOutage || res.Components["sms"] != MajorOutage {
+		t.Errorf("sms unavailable: got %+v", res)
+	}
+}
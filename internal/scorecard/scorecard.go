@@ -0,0 +1,207 @@
+package scorecard
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"finalwork/internal/providers"
+)
+
+type Row struct { // одна принятая строка данных, сведенная к общим для всех каналов метрикам
+	Provider     string
+	Country      string // код alpha-2
+	ResponseTime int    // для Email - время доставки
+	Bandwidth    int
+	HasBandwidth bool // у Voice и Email пропускной способности нет
+}
+
+type Sample struct { // метрики одного провайдера в одном канале за один сбор данных
+	Provider      string            `json:"provider"`
+	Channel       providers.Channel `json:"channel"`
+	Countries     []string          `json:"countries"`
+	ResponseTimes []int             `json:"response_times"`
+	Bandwidths    []int             `json:"bandwidths,omitempty"`
+	Accepted      int               `json:"accepted"`
+	Rejected      int               `json:"rejected"`
+}
+
+type Snapshot struct { // результат одного сбора данных
+	Time    time.Time `json:"time"`
+	Samples []Sample  `json:"samples"`
+}
+
+// Add добавляет в снимок строки канала ch и статистику отброшенных строк
+func (s *Snapshot) Add(ch providers.Channel, rows []Row, stats *providers.RowStats) {
+	byProvider := make(map[string]*Sample)
+	var order []string
+	sample := func(provider string) *Sample {
+		smp, ok := byProvider[provider]
+		if !ok {
+			smp = &Sample{Provider: provider, Channel: ch}
+			byProvider[provider] = smp
+			order = append(order, provider)
+		}
+		return smp
+	}
+	for _, r := range rows {
+		smp := sample(r.Provider)
+		smp.ResponseTimes = append(smp.ResponseTimes, r.ResponseTime)
+		if r.HasBandwidth {
+			smp.Bandwidths = append(smp.Bandwidths, r.Bandwidth)
+		}
+		if !contains(smp.Countries, r.Country) {
+			smp.Countries = append(smp.Countries, r.Country)
+		}
+	}
+	if stats != nil {
+		for provider, n := range stats.Accepted {
+			sample(provider).Accepted = n
+		}
+		for provider, n := range stats.Rejected {
+			sample(provider).Rejected = n
+		}
+	}
+	sort.Strings(order)
+	for _, provider := range order {
+		smp := byProvider[provider]
+		sort.Strings(smp.Countries)
+		s.Samples = append(s.Samples, *smp)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+type Metrics struct { // сводные метрики провайдера (по одному каналу или по всем сразу)
+	Countries       []string `json:"countries"`
+	AvgResponseTime float64  `json:"avg_response_time"`
+	P95ResponseTime float64  `json:"p95_response_time"`
+	AvgBandwidth    *float64 `json:"avg_bandwidth,omitempty"` // nil, если в каналах провайдера нет данных о пропускной способности
+	Rows            int      `json:"rows"`                    // принятые строки
+	Rejected        int      `json:"rejected"`                // отброшенные строки
+	RejectedRate    float64  `json:"rejected_rate"`           // доля отброшенных строк: rejected / (rows + rejected)
+}
+
+type ChannelScore struct {
+	Channel providers.Channel `json:"channel"`
+	Metrics
+}
+
+type Scorecard struct {
+	Provider  string         `json:"provider"`
+	Snapshots int            `json:"snapshots"` // в скольких снимках встречается провайдер
+	Total     Metrics        `json:"total"`     // по всем каналам
+	Channels  []ChannelScore `json:"channels"`
+}
+
+type accumulator struct {
+	countries     map[string]struct{}
+	responseTimes []int
+	bandwidths    []int
+	rows          int
+	rejected      int
+}
+
+func (a *accumulator) add(s Sample) {
+	if a.countries == nil {
+		a.countries = make(map[string]struct{})
+	}
+	for _, c := range s.Countries {
+		a.countries[c] = struct{}{}
+	}
+	a.responseTimes = append(a.responseTimes, s.ResponseTimes...)
+	a.bandwidths = append(a.bandwidths, s.Bandwidths...)
+	a.rows += s.Accepted
+	a.rejected += s.Rejected
+}
+
+func (a *accumulator) metrics() Metrics {
+	m := Metrics{Rows: a.rows, Rejected: a.rejected}
+	for c := range a.countries {
+		m.Countries = append(m.Countries, c)
+	}
+	sort.Strings(m.Countries)
+	m.AvgResponseTime = round(average(a.responseTimes))
+	m.P95ResponseTime = percentile(a.responseTimes, 95)
+	if len(a.bandwidths) > 0 {
+		avg := round(average(a.bandwidths))
+		m.AvgBandwidth = &avg
+	}
+	if total := a.rows + a.rejected; total > 0 {
+		m.RejectedRate = math.Round(float64(a.rejected)/float64(total)*10000) / 10000
+	}
+	return m
+}
+
+// Build сводит снимки в карточки провайдеров. Карточки упорядочены по имени провайдера, каналы - в порядке providers.Channels
+func Build(snapshots []Snapshot) []Scorecard {
+	totals := make(map[string]*accumulator)
+	channels := make(map[string]map[providers.Channel]*accumulator)
+	seen := make(map[string]int)
+	for _, snap := range snapshots {
+		inSnapshot := make(map[string]bool)
+		for _, s := range snap.Samples {
+			if totals[s.Provider] == nil {
+				totals[s.Provider] = &accumulator{}
+				channels[s.Provider] = make(map[providers.Channel]*accumulator)
+			}
+			if channels[s.Provider][s.Channel] == nil {
+				channels[s.Provider][s.Channel] = &accumulator{}
+			}
+			totals[s.Provider].add(s)
+			channels[s.Provider][s.Channel].add(s)
+			if !inSnapshot[s.Provider] {
+				inSnapshot[s.Provider] = true
+				seen[s.Provider]++
+			}
+		}
+	}
+	cards := make([]Scorecard, 0, len(totals))
+	for provider, total := range totals {
+		card := Scorecard{Provider: provider, Snapshots: seen[provider], Total: total.metrics()}
+		for _, ch := range providers.Channels {
+			if acc, ok := channels[provider][ch]; ok {
+				card.Channels = append(card.Channels, ChannelScore{Channel: ch, Metrics: acc.metrics()})
+			}
+		}
+		cards = append(cards, card)
+	}
+	sort.Slice(cards, func(i, j int) bool { return cards[i].Provider < cards[j].Provider })
+	return cards
+}
+
+func average(values []int) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum int
+	for _, v := range values {
+		sum += v
+	}
+	return float64(sum) / float64(len(values))
+}
+
+func percentile(values []int, p int) float64 { // перцентиль методом ближайшего ранга
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := make([]int, len(values))
+	copy(sorted, values)
+	sort.Ints(sorted)
+	rank := int(math.Ceil(float64(p) / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return float64(sorted[rank-1])
+}
+
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
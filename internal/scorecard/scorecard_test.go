@@ -0,0 +1,117 @@
+package scorecard
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"finalwork/internal/providers"
+)
+
+func float(v float64) *float64 { return &v }
+
+func TestBuild(t *testing.T) {
+	seq := func(from, to int) []int {
+		var list []int
+		for v := from; v <= to; v++ {
+			list = append(list, v)
+		}
+		return list
+	}
+	at := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name      string
+		snapshots []Snapshot
+		want      []Scorecard
+	}{
+		{"empty history", nil, []Scorecard{}},
+		{"snapshots without samples", []Snapshot{{Time: at}, {Time: at}}, []Scorecard{}},
+		{
+			"single sample",
+			[]Snapshot{{Time: at, Samples: []Sample{{Provider: "Topolo", Channel: providers.SMS, Countries: []string{"RU"}, ResponseTimes: []int{100}, Bandwidths: []int{30}, Accepted: 1}}}},
+			[]Scorecard{{Provider: "Topolo", Snapshots: 1,
+				Total:    Metrics{Countries: []string{"RU"}, AvgResponseTime: 100, P95ResponseTime: 100, AvgBandwidth: float(30), Rows: 1},
+				Channels: []ChannelScore{{Channel: providers.SMS, Metrics: Metrics{Countries: []string{"RU"}, AvgResponseTime: 100, P95ResponseTime: 100, AvgBandwidth: float(30), Rows: 1}}},
+			}},
+		},
+		{
+			"only rejected rows",
+			[]Snapshot{{Time: at, Samples: []Sample{{Provider: "Kildy", Channel: providers.Voice, Rejected: 2}}}},
+			[]Scorecard{{Provider: "Kildy", Snapshots: 1,
+				Total:    Metrics{Rejected: 2, RejectedRate: 1},
+				Channels: []ChannelScore{{Channel: providers.Voice, Metrics: Metrics{Rejected: 2, RejectedRate: 1}}},
+			}},
+		},
+		{
+			"nearest rank over several snapshots and channels",
+			[]Snapshot{
+				{Time: at, Samples: []Sample{
+					{Provider: "Rond", Channel: providers.Voice, Countries: []string{"US"}, ResponseTimes: seq(11, 20), Accepted: 10, Rejected: 1},
+					{Provider: "Rond", Channel: providers.SMS, Countries: []string{"GB", "US"}, ResponseTimes: seq(1, 10), Bandwidths: []int{1, 2}, Accepted: 10},
+				}},
+				{Time: at.Add(time.Hour), Samples: []Sample{
+					{Provider: "Rond", Channel: providers.SMS, Countries: []string{"DE"}, ResponseTimes: []int{1}, Bandwidths: []int{4}, Accepted: 1, Rejected: 1},
+					{Provider: "Anicall", Channel: providers.SMS, Countries: []string{"RU"}, ResponseTimes: []int{7, 3}, Accepted: 2},
+				}},
+			},
+			[]Scorecard{
+				{Provider: "Anicall", Snapshots: 1,
+					Total:    Metrics{Countries: []string{"RU"}, AvgResponseTime: 5, P95ResponseTime: 7, Rows: 2},
+					Channels: []ChannelScore{{Channel: providers.SMS, Metrics: Metrics{Countries: []string{"RU"}, AvgResponseTime: 5, P95ResponseTime: 7, Rows: 2}}},
+				},
+				{Provider: "Rond", Snapshots: 2,
+					// 21 время ответа (1, 1..20): ранг ceil(0.95 * 21) = 20 - значение 19; отброшено 2 из 23
+					Total: Metrics{Countries: []string{"DE", "GB", "US"}, AvgResponseTime: 10.05, P95ResponseTime: 19, AvgBandwidth: float(2.33), Rows: 21, Rejected: 2, RejectedRate: 0.087},
+					Channels: []ChannelScore{ // в порядке providers.Channels, а не снимков
+						{Channel: providers.SMS, Metrics: Metrics{Countries: []string{"DE", "GB", "US"}, AvgResponseTime: 5.09, P95ResponseTime: 10, AvgBandwidth: float(2.33), Rows: 11, Rejected: 1, RejectedRate: 0.0833}},
+						{Channel: providers.Voice, Metrics: Metrics{Countries: []string{"US"}, AvgResponseTime: 15.5, P95ResponseTime: 20, Rows: 10, Rejected: 1, RejectedRate: 0.0909}},
+					},
+				},
+			},
+		},
+	} {
+		if got := Build(tc.snapshots); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s:\ngot  %+v\nwant %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	for _, tc := range []struct {
+		values []int
+		p      int
+		want   float64
+	}{
+		{nil, 95, 0},
+		{[]int{42}, 95, 42},
+		{[]int{42}, 0, 42}, // ранг не меньше 1
+		{[]int{5, 1, 4, 2, 3}, 95, 5},
+		{[]int{5, 1, 4, 2, 3}, 50, 3},
+		{[]int{5, 1, 4, 2, 3}, 20, 1},
+	} {
+		if got := percentile(tc.values, tc.p); got != tc.want {
+			t.Errorf("p%d of %v: got %v, want %v", tc.p, tc.values, got, tc.want)
+		}
+	}
+}
+
+func TestSnapshotAdd(t *testing.T) {
+	var s Snapshot
+	stats := providers.NewRowStats()
+	stats.Accept("Topolo")
+	stats.Accept("Topolo")
+	stats.Rejected["Kildy"] = 1
+	s.Add(providers.MMS, []Row{
+		{Provider: "Topolo", Country: "US", ResponseTime: 20, Bandwidth: 5, HasBandwidth: true},
+		{Provider: "Topolo", Country: "RU", ResponseTime: 10, Bandwidth: 7, HasBandwidth: true},
+	}, stats)
+	s.Add(providers.Email, []Row{{Provider: "Gmail", Country: "RU", ResponseTime: 40}}, nil)
+	want := []Sample{
+		{Provider: "Kildy", Channel: providers.MMS, Rejected: 1}, // только отброшенные строки
+		{Provider: "Topolo", Channel: providers.MMS, Countries: []string{"RU", "US"}, ResponseTimes: []int{20, 10}, Bandwidths: []int{5, 7}, Accepted: 2},
+		{Provider: "Gmail", Channel: providers.Email, Countries: []string{"RU"}, ResponseTimes: []int{40}},
+	}
+	if !reflect.DeepEqual(s.Samples, want) {
+		t.Errorf("got %+v, want %+v", s.Samples, want)
+	}
+}
@@ -0,0 +1,105 @@
+package scorecard
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Store хранит снимки на диске: по файлу в формате JSON Lines на каждый месяц (например 2026-10.jsonl)
+type Store struct {
+	mu    sync.Mutex
+	dir   string
+	last  *Snapshot // последний снимок, в том числе не попавший в историю
+	saved time.Time // время последнего снимка, записанного на диск
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+func (s *Store) fileName(t time.Time) string {
+	return filepath.Join(s.dir, t.UTC().Format("2006-01")+".jsonl")
+}
+
+// SetLast запоминает снимок как последний (для карточек по текущим данным), не записывая его в историю
+func (s *Store) SetLast(snap Snapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last = &snap
+}
+
+// AppendLast дописывает последний снимок в файл его месяца, если он еще не записан.
+// История пополняется только так, с постоянным периодом, поэтому не зависит от частоты запросов клиентов
+func (s *Store) AppendLast() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.last == nil || !s.last.Time.After(s.saved) {
+		return nil
+	}
+	snap := *s.last
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("scorecard: %w", err)
+	}
+	line, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("scorecard: %w", err)
+	}
+	file, err := os.OpenFile(s.fileName(snap.Time), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("scorecard: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("scorecard: %w", err)
+	}
+	s.saved = snap.Time
+	return nil
+}
+
+// Last возвращает последний снимок, записанный с момента запуска
+func (s *Store) Last() (Snapshot, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.last == nil {
+		return Snapshot{}, false
+	}
+	return *s.last, true
+}
+
+// Load читает снимки с временем в полуинтервале [from, to)
+func (s *Store) Load(from, to time.Time) ([]Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var snapshots []Snapshot
+	for month := time.Date(from.UTC().Year(), from.UTC().Month(), 1, 0, 0, 0, 0, time.UTC); month.Before(to); month = month.AddDate(0, 1, 0) {
+		file, err := os.Open(s.fileName(month))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("scorecard: %w", err)
+		}
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			var snap Snapshot
+			if err := json.Unmarshal(scanner.Bytes(), &snap); err != nil {
+				continue // недописанная строка (например, при аварийном завершении) не должна ломать отчет
+			}
+			if !snap.Time.Before(from) && snap.Time.Before(to) {
+				snapshots = append(snapshots, snap)
+			}
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("scorecard: %w", err)
+		}
+	}
+	return snapshots, nil
+}
@@ -0,0 +1,34 @@
+package scorecard
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistoryOnlyFromAppendLast(t *testing.T) {
+	s := NewStore(t.TempDir())
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ { // сборы по запросам клиентов
+		s.SetLast(Snapshot{Time: start.Add(time.Duration(i) * time.Second)})
+	}
+	if err := s.AppendLast(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AppendLast(); err != nil { // новых сборов не было - повторно не пишем
+		t.Fatal(err)
+	}
+	s.SetLast(Snapshot{Time: start.Add(time.Minute)})
+	if err := s.AppendLast(); err != nil {
+		t.Fatal(err)
+	}
+	list, err := s.Load(start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || !list[0].Time.Equal(start.Add(4*time.Second)) || !list[1].Time.Equal(start.Add(time.Minute)) {
+		t.Errorf("history: got %+v", list)
+	}
+	if last, ok := s.Last(); !ok || !last.Time.Equal(start.Add(time.Minute)) {
+		t.Errorf("last: got %+v", last)
+	}
+}
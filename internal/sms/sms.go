@@ -2,8 +2,8 @@ package sms
 
 import (
 	"finalwork/internal/countries"
-	"fmt"
-	"os"
+	"finalwork/internal/input"
+	"finalwork/internal/providers"
 	"strings"
 	//"io/ioutil"
 )
@@ -15,33 +15,11 @@ type SMSData struct {
 	Provider     string `json:"provider"`
 }
 
-var providers = map[string]struct{}{ // создадим мап с ключами, которые соответствуют названиям допустимых провайдеров
-	"Topolo": {},
-	"Rond":   {},
-	"Kildy":  {},
-}
-
 type SmsCountryRepository countries.CountryRepository // локальная обёртка над типом countries.CountryRepository
 
-func openFile(fileName string) ([]byte, error) { // функция открытия файла
-	file, err := os.Open(fileName)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	fi, _ := file.Stat()
-	buf := make([]byte, fi.Size())
-	if _, err = file.Read(buf); err != nil { // читаем, получаем слайс байтов (используем вместо ioutil)
-		fmt.Println("File reading error:", err)
-		panic(err)
-	}
-	return buf, nil
-}
-
-func (r *SmsCountryRepository) GetSmsData(fileName string) ([]SMSData, error) { // функция сбора данных о системе SMS
+func (r *SmsCountryRepository) GetSmsData(files input.Files, fileName string, catalog *providers.Catalog, stats *providers.RowStats) ([]SMSData, error) { // функция сбора данных о системе SMS
 	var SMSDataSlice []SMSData
-	fileByteSlice, err := openFile(fileName) // получаем слайс байтов из файла, через отдельную функцию
+	fileByteSlice, err := files.ReadFile(fileName) // читаем файл, получаем слайс байтов; пустой файл - нет строк, как у Voice и Email
 	if err != nil {
 		return SMSDataSlice, err
 	}
@@ -51,11 +29,15 @@ func (r *SmsCountryRepository) GetSmsData(fileName string) ([]SMSData, error) {
 		sep := ";"                                 // создаём сепаратор
 		singleStringSlice := strings.Split(v, sep) // разделяем подстроку по разделителю ";"
 		if len(singleStringSlice) < 4 {            // проверяем, что кол-во элементов не меньше 4
+			stats.RejectMalformed(v)
 			continue
 		} else {
-			SMSDataStruct, ok := r.parseStringSlice(singleStringSlice) // парсинг в структуру и проверка требованиям
-			if ok {                                                    //если true
+			SMSDataStruct, ok := r.parseStringSlice(singleStringSlice, catalog) // парсинг в структуру и проверка требованиям
+			if ok {                                                             //если true
 				SMSDataSlice = append(SMSDataSlice, SMSDataStruct) // добавление структуры в результирующий срез
+				stats.Accept(SMSDataStruct.Provider)
+			} else {
+				stats.Reject(catalog, singleStringSlice[3])
 			}
 		}
 	}
@@ -63,16 +45,20 @@ func (r *SmsCountryRepository) GetSmsData(fileName string) ([]SMSData, error) {
 }
 
 // функция создания структуры из слайса строк и проверки поля Country по коду alpha-2
-func (r *SmsCountryRepository) parseStringSlice(singleStringSlice []string) (SMSData, bool) {
+func (r *SmsCountryRepository) parseStringSlice(singleStringSlice []string, catalog *providers.Catalog) (SMSData, bool) {
+	if len(singleStringSlice) < 4 { // поврежденная строка (вызывающий код проверяет это же, но парсер не должен на него полагаться)
+		return SMSData{}, false
+	}
 	SMSds := SMSData{
 		Country:      singleStringSlice[0],
 		Bandwidth:    singleStringSlice[1],
 		ResponseTime: singleStringSlice[2],
 		Provider:     singleStringSlice[3],
 	}
-	if _, ok := r.CountryByCode[countries.Code(SMSds.Country)]; ok { // проверяем по alpha-2, обращаясь к хранилищу SmsCountryRepository по ключу = значению поля Country у полученной структуры
-		if _, ok := providers[SMSds.Provider]; ok { // проверяем провайдера, обращаясь к мап по ключу = значению поля Provider у полученной структуры
-			return SMSds, true // если значения по ключам в мап имеются, возвращаем структуру и true
+	if _, ok := r.Lookup(countries.Code(SMSds.Country)); ok { // проверяем по alpha-2, обращаясь к хранилищу SmsCountryRepository по ключу = значению поля Country у полученной структуры
+		if name, ok := catalog.Canonical(providers.SMS, SMSds.Provider); ok { // проверяем провайдера по каталогу (с учетом псевдонимов и без учета регистра)
+			SMSds.Provider = name // приводим имя к каноническому
+			return SMSds, true    // если страна и провайдер допустимы, возвращаем структуру и true
 		}
 	}
 	return SMSds, false // возвращаем структуру и false
@@ -80,7 +66,7 @@ func (r *SmsCountryRepository) parseStringSlice(singleStringSlice []string) (SMS
 	// if !ok {
 	//      return SMSds, false
 	// }
-	// if _, ok := providers[SMSds.Provider]; !ok {
+	// if _, ok := catalog.Canonical(providers.SMS, SMSds.Provider); !ok {
 	//      return SMSds, false
 	// }
 	// return SMSds, true
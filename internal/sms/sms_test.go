@@ -0,0 +1,96 @@
+package sms
+
+import (
+	"finalwork/internal/input/inputtest"
+	"finalwork/internal/providers"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testProviders = []providers.Provider{
+	{Name: "Topolo", Channels: []providers.Channel{providers.SMS}},
+	{Name: "Rond", Channels: []providers.Channel{providers.SMS}},
+	{Name: "Kildy", Aliases: []string{"Kildy Mobile"}, Channels: []providers.Channel{providers.SMS}},
+}
+
+func TestParseStringSlice(t *testing.T) {
+	repo, catalog := inputtest.Setup(t, testProviders...)
+	r := SmsCountryRepository(repo)
+	for _, tc := range []struct {
+		line string
+		want SMSData
+		ok   bool
+	}{
+		{"RU;58;1234;Topolo", SMSData{Country: "RU", Bandwidth: "58", ResponseTime: "1234", Provider: "Topolo"}, true},
+		{"US;0;30;rond", SMSData{Country: "US", Bandwidth: "0", ResponseTime: "30", Provider: "Rond"}, true},
+		{"GB;100;1999;Kildy Mobile", SMSData{Country: "GB", Bandwidth: "100", ResponseTime: "1999", Provider: "Kildy"}, true},
+		{"RU;fast;-5;Topolo", SMSData{Country: "RU", Bandwidth: "fast", ResponseTime: "-5", Provider: "Topolo"}, true}, // числа хранятся строками и не проверяются
+		{"XX;1;1;Topolo", SMSData{}, false},
+		{"RU;1;1;Gmail", SMSData{}, false},
+		{"RU;58", SMSData{}, false},
+		{"", SMSData{}, false},
+	} {
+		row, ok := r.parseStringSlice(strings.Split(tc.line, ";"), catalog)
+		if ok != tc.ok || ok && row != tc.want {
+			t.Errorf("%q: got %+v, %v, want %+v, %v", tc.line, row, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestGetSmsData(t *testing.T) {
+	repo, catalog := inputtest.Setup(t, testProviders...)
+	r := SmsCountryRepository(repo)
+	for _, tc := range []struct {
+		data  string
+		rows  []SMSData
+		stats *providers.RowStats
+	}{
+		{
+			"RU;58;1234;Topolo\nUS;61;1136;#??\nXX;1;2;Kildy\nAT43;158;Topolo\n\nCA;26;1\n",
+			[]SMSData{{Country: "RU", Bandwidth: "58", ResponseTime: "1234", Provider: "Topolo"}},
+			&providers.RowStats{Accepted: map[string]int{"Topolo": 1}, Rejected: map[string]int{"Kildy": 1}, Unattributed: 3},
+		},
+		{"", nil, &providers.RowStats{Accepted: map[string]int{}, Rejected: map[string]int{}}},
+		{"\n\n", nil, &providers.RowStats{Accepted: map[string]int{}, Rejected: map[string]int{}}},
+	} {
+		stats := providers.NewRowStats()
+		rows, err := r.GetSmsData(inputtest.Bytes(tc.data), "sms.data", catalog, stats)
+		if err != nil || !reflect.DeepEqual(rows, tc.rows) || !reflect.DeepEqual(stats, tc.stats) {
+			t.Errorf("%q: got %+v, %+v, %v, want %+v, %+v", tc.data, rows, stats, err, tc.rows, tc.stats)
+		}
+	}
+}
+
+func FuzzParseStringSlice(f *testing.F) {
+	for _, line := range []string{"RU;58;1234;Topolo", "US;0;30;rond", "GB;100;1999;Kildy Mobile", "", ";;;", "RU;58", "XX;1;1;Topolo"} {
+		f.Add(line)
+	}
+	repo, catalog := inputtest.Setup(f, testProviders...)
+	r := SmsCountryRepository(repo)
+	f.Fuzz(func(t *testing.T, line string) {
+		row, ok := r.parseStringSlice(strings.Split(line, ";"), catalog)
+		if ok {
+			inputtest.CheckRow(t, repo, catalog, providers.SMS, row.Country, row.Provider)
+		}
+	})
+}
+
+func FuzzGetSmsData(f *testing.F) {
+	f.Add([]byte("RU;58;1234;Topolo\nUS;61;1136;#??\nBL;#???;105;Kildy\nAT43;158;Topolo\nCA;26;1\n"))
+	f.Add([]byte("\n\n"))
+	repo, catalog := inputtest.Setup(f, testProviders...)
+	r := SmsCountryRepository(repo)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		rows, err := r.GetSmsData(inputtest.Bytes(data), "sms.data", catalog, providers.NewRowStats())
+		if err != nil {
+			t.Fatalf("error for file contents: %v", err)
+		}
+		if len(rows) > strings.Count(string(data), "\n")+1 {
+			t.Fatalf("%d rows from %d lines", len(rows), strings.Count(string(data), "\n")+1)
+		}
+		for _, row := range rows {
+			inputtest.CheckRow(t, repo, catalog, providers.SMS, row.Country, row.Provider)
+		}
+	})
+}
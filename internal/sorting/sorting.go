@@ -0,0 +1,135 @@
+package sorting
+
+// Стабильная сортировка по нескольким ключам: первый ключ - основной, следующие разрешают равенство.
+// Элементы, равные по всем ключам, сохраняют исходный порядок, поэтому результат детерминирован.
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/collate"
+)
+
+type Key[T any] struct { // ключ сортировки
+	Name    string           // имя для параметра sort= (совпадает с полем JSON)
+	Compare func(a, b T) int // <0, если a идет раньше b; 0, если элементы равны по ключу
+}
+
+// Desc возвращает ключ с обратным порядком
+func (k Key[T]) Desc() Key[T] {
+	compare := k.Compare
+	return Key[T]{Name: k.Name, Compare: func(a, b T) int { return compare(b, a) }}
+}
+
+type multiKey[T any] struct {
+	items []T
+	keys  []Key[T]
+}
+
+func (m multiKey[T]) Len() int      { return len(m.items) }
+func (m multiKey[T]) Swap(i, j int) { m.items[i], m.items[j] = m.items[j], m.items[i] }
+func (m multiKey[T]) Less(i, j int) bool {
+	for _, k := range m.keys {
+		if c := k.Compare(m.items[i], m.items[j]); c != 0 {
+			return c < 0
+		}
+	}
+	return false
+}
+
+// Stable сортирует items на месте по ключам keys
+func Stable[T any](items []T, keys ...Key[T]) {
+	sort.Stable(multiKey[T]{items: items, keys: keys})
+}
+
+// Sorted возвращает отсортированную копию items (пустой слайс, а не nil, если элементов нет), исходный слайс не меняется
+func Sorted[T any](items []T, keys ...Key[T]) []T {
+	c := make([]T, len(items))
+	copy(c, items)
+	Stable(c, keys...)
+	return c
+}
+
+// Find ищет ключ по имени
+func Find[T any](keys []Key[T], name string) (Key[T], bool) {
+	for _, k := range keys {
+		if k.Name == name {
+			return k, true
+		}
+	}
+	return Key[T]{}, false
+}
+
+// Parse разбирает список ключей вида "country,-response_time" ("-" - по убыванию) из допустимых known
+func Parse[T any](spec string, known []Key[T]) ([]Key[T], error) {
+	var keys []Key[T]
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		desc := strings.HasPrefix(name, "-")
+		name = strings.TrimPrefix(name, "-")
+		k, ok := Find(known, name)
+		if !ok {
+			return nil, fmt.Errorf("unknown sort key %q (allowed: %s)", name, Names(known))
+		}
+		if desc {
+			k = k.Desc()
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// Names возвращает имена ключей через запятую (для сообщений об ошибках)
+func Names[T any](keys []Key[T]) string {
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = k.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// функции построения сравнения по полю элемента
+
+func Strings[T any](get func(T) string) func(a, b T) int { // побайтовое сравнение строк
+	return func(a, b T) int { return strings.Compare(get(a), get(b)) }
+}
+
+func Collated[T any](c *collate.Collator, get func(T) string) func(a, b T) int { // сравнение строк по правилам языка (названия стран)
+	return func(a, b T) int { return c.CompareString(get(a), get(b)) }
+}
+
+func Ints[T any](get func(T) int) func(a, b T) int {
+	return func(a, b T) int { return compareNumbers(get(a), get(b)) }
+}
+
+func Floats[T any](get func(T) float64) func(a, b T) int {
+	return func(a, b T) int { return compareNumbers(get(a), get(b)) }
+}
+
+func NumericStrings[T any](get func(T) string) func(a, b T) int { // числа, хранящиеся строками (SMS, MMS). Нечисловые значения - в конце
+	return func(a, b T) int {
+		x, errX := strconv.Atoi(get(a))
+		y, errY := strconv.Atoi(get(b))
+		switch {
+		case errX != nil && errY != nil:
+			return strings.Compare(get(a), get(b))
+		case errX != nil:
+			return 1
+		case errY != nil:
+			return -1
+		}
+		return compareNumbers(x, y)
+	}
+}
+
+func compareNumbers[N int | float64](a, b N) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,129 @@
+package sorting
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type row struct {
+	Country, Provider string
+	Bandwidth         string
+	Seq               int // исходная позиция, для проверки стабильности
+}
+
+var testKeys = []Key[row]{
+	{Name: "country", Compare: Strings(func(r row) string { return r.Country })},
+	{Name: "provider", Compare: Strings(func(r row) string { return r.Provider })},
+	{Name: "bandwidth", Compare: NumericStrings(func(r row) string { return r.Bandwidth })},
+}
+
+func order(rows []row) string {
+	list := make([]string, len(rows))
+	for i, r := range rows {
+		list[i] = strconv.Itoa(r.Seq)
+	}
+	return strings.Join(list, " ")
+}
+
+func TestStableMultiKey(t *testing.T) {
+	rows := []row{
+		{"RU", "Topolo", "10", 0},
+		{"GB", "Rond", "5", 1},
+		{"RU", "Rond", "7", 2},
+		{"GB", "Rond", "x", 3},
+		{"RU", "Rond", "7", 4},
+		{"GB", "Kildy", "20", 5},
+	}
+	for _, tc := range []struct {
+		spec, want string
+	}{
+		{"country,provider", "5 1 3 2 4 0"},
+		{"provider,country", "5 1 3 2 4 0"},
+		{"provider,-country", "5 2 4 1 3 0"},
+		{"bandwidth", "1 2 4 0 5 3"},  // нечисловое значение - в конце
+		{"-bandwidth", "3 5 0 2 4 1"}, // по убыванию: нечисловое значение - первым, равные 2 и 4 сохраняют порядок
+		{"country,-bandwidth", "3 5 1 0 2 4"},
+	} {
+		keys, err := Parse(tc.spec, testKeys)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.spec, err)
+		}
+		got := Sorted(rows, keys...)
+		if order(got) != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.spec, order(got), tc.want)
+		}
+	}
+	if order(rows) != "0 1 2 3 4 5" {
+		t.Errorf("Sorted changed the input: %s", order(rows))
+	}
+}
+
+func TestSortedEmpty(t *testing.T) {
+	if got := Sorted[row](nil, testKeys...); got == nil || len(got) != 0 {
+		t.Errorf("got %#v, want an empty non-nil slice", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{"speed", "country,", "-", "country,,provider"} {
+		if _, err := Parse(spec, testKeys); err == nil || !strings.Contains(err.Error(), "allowed: country, provider, bandwidth") {
+			t.Errorf("%q: got %v", spec, err)
+		}
+	}
+}
+
+// selectionSort повторяет сортировку, которая раньше была в getAndSortSMS: выбор минимума по одному полю, без вторичных ключей
+func selectionSort(rows []row) {
+	for i := 0; i < len(rows)-1; i++ {
+		min := i
+		for j := i + 1; j < len(rows); j++ {
+			if rows[j].Provider < rows[min].Provider {
+				min = j
+			}
+		}
+		rows[i], rows[min] = rows[min], rows[i]
+	}
+}
+
+func benchRows(n int) []row {
+	r := rand.New(rand.NewSource(1))
+	rows := make([]row, n)
+	for i := range rows {
+		rows[i] = row{
+			Country:   fmt.Sprintf("C%03d", r.Intn(250)),
+			Provider:  fmt.Sprintf("P%02d", r.Intn(40)),
+			Bandwidth: strconv.Itoa(r.Intn(100)),
+			Seq:       i,
+		}
+	}
+	return rows
+}
+
+func BenchmarkSelectionSort(b *testing.B) {
+	for _, n := range []int{1000, 10000} {
+		rows := benchRows(n)
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			buf := make([]row, n)
+			for i := 0; i < b.N; i++ {
+				copy(buf, rows)
+				selectionSort(buf)
+			}
+		})
+	}
+}
+
+func BenchmarkStable(b *testing.B) {
+	for _, n := range []int{1000, 10000, 100000} {
+		rows := benchRows(n)
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			buf := make([]row, n)
+			for i := 0; i < b.N; i++ {
+				copy(buf, rows)
+				Stable(buf, testKeys[1], testKeys[0]) // провайдер, затем страна
+			}
+		})
+	}
+}
@@ -1,9 +1,9 @@
 package support
 
 import (
-	"encoding/json"
-	"io"
-	"net/http"
+	"context"
+	"finalwork/internal/fetch"
+	"strings"
 )
 
 type SupportData struct {
@@ -11,22 +11,12 @@ type SupportData struct {
 	ActiveTickets int    `json:"active_tickets"`
 }
 
-func GetSupportData(addr string) ([]SupportData, int, error) { // функция сбора данных о системе support
-	var supportDataSlice []SupportData
-	resp, err := http.Get(addr) //отправляем GET-запрос по addr
-	if err != nil {
-		return supportDataSlice, resp.StatusCode, err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode == 200 { // проверяем код ответа API
-		body, err := io.ReadAll(resp.Body) // считываем тело запроса
-		if err != nil {
-			return supportDataSlice, resp.StatusCode, err
-		}
-		if err := json.Unmarshal(body, &supportDataSlice); err != nil { // используем функцию Unmarshal
-			return supportDataSlice, resp.StatusCode, err
-		}
-		return supportDataSlice, resp.StatusCode, nil
-	}
-	return supportDataSlice, resp.StatusCode, nil
+// GetSupportData запрашивает данные системы Support. Ответ с кодом, отличным от 200, возвращается как *upstream.StatusError
+func GetSupportData(src fetch.Source, addr string) (fetch.Result[SupportData], error) {
+	return fetch.Fetch(context.Background(), src, addr, validate) // GET-запрос, проверка и разбор ответа
+}
+
+func validate(v *SupportData) bool { // проверка элемента: тема указана, число тикетов не отрицательное
+	v.Topic = strings.TrimSpace(v.Topic)
+	return v.Topic != "" && v.ActiveTickets >= 0
 }
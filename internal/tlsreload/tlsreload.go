@@ -0,0 +1,127 @@
+package tlsreload
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Reloader хранит текущие сертификат сервера и пул CA для проверки клиентов.
+// Новые соединения получают данные, загруженные последним успешным Reload; открытые соединения не разрываются.
+type Reloader struct {
+	certFile, keyFile, clientCAFile string
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	clientCA *x509.CertPool
+	modTimes map[string]time.Time
+}
+
+func New(certFile, keyFile, clientCAFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile, clientCAFile: clientCAFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload перечитывает файлы. При ошибке остаются прежние сертификаты
+func (r *Reloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+	var pool *x509.CertPool
+	if r.clientCAFile != "" {
+		pem, err := os.ReadFile(r.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("tls: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("tls: %s: no certificates found", r.clientCAFile)
+		}
+	}
+	r.mu.Lock()
+	r.cert = &cert
+	r.clientCA = pool
+	r.modTimes = r.currentModTimes()
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *Reloader) currentModTimes() map[string]time.Time {
+	times := make(map[string]time.Time)
+	for _, name := range []string{r.certFile, r.keyFile, r.clientCAFile} {
+		if name == "" {
+			continue
+		}
+		if fi, err := os.Stat(name); err == nil {
+			times[name] = fi.ModTime()
+		}
+	}
+	return times
+}
+
+func (r *Reloader) changed() bool {
+	current := r.currentModTimes()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for name, t := range current {
+		if !t.Equal(r.modTimes[name]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch каждые interval проверяет время изменения файлов и перечитывает их при изменении. Завершается при закрытии stop
+func (r *Reloader) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			if err := r.Reload(); err != nil {
+				fmt.Println("TLS certificates reload failed:", err) // например, сертификат уже заменен, а ключ еще нет - попробуем в следующий раз
+				continue
+			}
+			fmt.Println("TLS certificates reloaded")
+		}
+	}
+}
+
+// TLSConfig возвращает настройки TLS, которые берут сертификаты из Reloader при каждом новом соединении.
+// requireClientCert - клиент обязан предъявить сертификат, подписанный CA из clientCAFile; иначе сертификат проверяется, только если предъявлен
+func (r *Reloader) TLSConfig(requireClientCert bool) *tls.Config {
+	base := &tls.Config{MinVersion: tls.VersionTLS12}
+	base.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		return r.cert, nil
+	}
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.RLock()
+		pool := r.clientCA
+		r.mu.RUnlock()
+		cfg := base.Clone()
+		cfg.GetConfigForClient = nil
+		if pool != nil {
+			cfg.ClientCAs = pool
+			cfg.ClientAuth = tls.VerifyClientCertIfGiven
+			if requireClientCert {
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+		}
+		return cfg, nil
+	}
+	return base
+}
@@ -0,0 +1,208 @@
+package tlsreload
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert записывает в dir самоподписанный сертификат с CN cn и его ключ, возвращает пути к файлам
+func writeCert(t *testing.T, dir, cn string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// serverCN подключается к серверу с настройками config и возвращает CN его сертификата
+func serverCN(t *testing.T, config *tls.Config) string {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		conn.(*tls.Conn).Handshake()
+		conn.Close()
+	}()
+	conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "first")
+	r, err := New(certFile, keyFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := r.TLSConfig(false)
+	if cn := serverCN(t, config); cn != "first" {
+		t.Fatalf("got %q, want first", cn)
+	}
+
+	writeCert(t, dir, "second")
+	if cn := serverCN(t, config); cn != "first" { // без Reload остается прежний сертификат
+		t.Fatalf("before reload: got %q", cn)
+	}
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if cn := serverCN(t, config); cn != "second" {
+		t.Fatalf("after reload: got %q, want second", cn)
+	}
+
+	if err := os.WriteFile(certFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Fatal("broken certificate loaded")
+	}
+	if cn := serverCN(t, config); cn != "second" { // при ошибке остается прежний
+		t.Errorf("after a failed reload: got %q, want second", cn)
+	}
+
+	if _, err := New(certFile, keyFile, ""); err == nil {
+		t.Error("New accepted a broken certificate")
+	}
+}
+
+func TestClientCA(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "server")
+	caFile, _ := writeCert(t, t.TempDir(), "client-ca")
+	r, err := New(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for require, want := range map[bool]tls.ClientAuthType{false: tls.VerifyClientCertIfGiven, true: tls.RequireAndVerifyClientCert} {
+		cfg, err := r.TLSConfig(require).GetConfigForClient(&tls.ClientHelloInfo{})
+		if err != nil || cfg.ClientAuth != want || cfg.ClientCAs == nil || cfg.GetCertificate == nil || cfg.GetConfigForClient != nil {
+			t.Errorf("require=%v: got %+v, %v", require, cfg, err)
+		}
+	}
+
+	first, _ := r.TLSConfig(true).GetConfigForClient(&tls.ClientHelloInfo{})
+	writeCert(t, filepath.Dir(caFile), "other-ca")
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	second, _ := r.TLSConfig(true).GetConfigForClient(&tls.ClientHelloInfo{})
+	if first.ClientCAs.Equal(second.ClientCAs) { // новые соединения проверяются по новому CA
+		t.Error("client CA pool was not replaced")
+	}
+
+	if err := os.WriteFile(caFile, []byte("no pem here"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Error("CA file without certificates loaded")
+	}
+
+	noCA, err := New(certFile, keyFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg, _ := noCA.TLSConfig(true).GetConfigForClient(&tls.ClientHelloInfo{}); cfg.ClientAuth != tls.NoClientCert {
+		t.Errorf("without client_ca_file: client auth %v", cfg.ClientAuth)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "first")
+	r, err := New(certFile, keyFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		r.Watch(10*time.Millisecond, stop)
+		close(done)
+	}()
+	defer func() {
+		close(stop)
+		<-done // Watch завершается по stop
+	}()
+	config := r.TLSConfig(false)
+	touch := func(at time.Time) { // время изменения явно, чтобы не зависеть от точности часов файловой системы
+		for _, name := range []string{certFile, keyFile} {
+			if err := os.Chtimes(name, at, at); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	waitCN := func(want string) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			cn := serverCN(t, config)
+			if cn == want {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("got %q, want %q", cn, want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	if err := os.WriteFile(certFile, []byte("half-written"), 0o600); err != nil { // ошибка чтения - прежний сертификат
+		t.Fatal(err)
+	}
+	touch(time.Now().Add(time.Minute))
+	time.Sleep(50 * time.Millisecond)
+	waitCN("first")
+
+	writeCert(t, dir, "second")
+	touch(time.Now().Add(2 * time.Minute))
+	waitCN("second")
+}
@@ -0,0 +1,61 @@
+package upstream
+
+import (
+	"sync"
+	"time"
+)
+
+// breaker - автомат отключения источника.
+// closed: запросы идут; после failures неудачных запросов подряд - open.
+// open: запросы не идут до openUntil; после этого один пробный запрос (half-open).
+// Успешный пробный запрос возвращает closed, неудачный - снова open.
+type breaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool // пробный запрос уже отправлен
+}
+
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openUntil.IsZero() {
+		return true
+	}
+	if now.Before(b.openUntil) || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.openUntil = time.Time{}
+	b.probing = false
+}
+
+func (b *breaker) failure(now time.Time, threshold int, cooldown time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	if threshold > 0 && (b.failures >= threshold || !b.openUntil.IsZero()) {
+		b.openUntil = now.Add(cooldown)
+	}
+}
+
+func (b *breaker) state(now time.Time) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.openUntil.IsZero():
+		return "closed"
+	case now.Before(b.openUntil) || b.probing:
+		return "open"
+	default:
+		return "half-open"
+	}
+}
@@ -0,0 +1,61 @@
+package upstream
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreakerTransitions(t *testing.T) {
+	const threshold, cooldown = 3, 10 * time.Second
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var b breaker
+	check := func(now time.Time, state string, allow bool) {
+		t.Helper()
+		if got := b.state(now); got != state {
+			t.Fatalf("state at +%v: got %s, want %s", now.Sub(start), got, state)
+		}
+		if got := b.allow(now); got != allow {
+			t.Fatalf("allow at +%v: got %v, want %v", now.Sub(start), got, allow)
+		}
+	}
+
+	for i := 0; i < threshold-1; i++ { // до порога источник доступен
+		b.failure(start, threshold, cooldown)
+		check(start, "closed", true)
+	}
+	b.success() // успешный запрос сбрасывает счетчик
+	for i := 0; i < threshold-1; i++ {
+		b.failure(start, threshold, cooldown)
+	}
+	check(start, "closed", true)
+
+	b.failure(start, threshold, cooldown)
+	check(start, "open", false)
+	check(start.Add(cooldown-time.Second), "open", false)
+
+	now := start.Add(cooldown)
+	check(now, "half-open", true) // пробный запрос
+	check(now, "open", false)     // второй запрос во время пробного не пропускается
+
+	b.failure(now, threshold, cooldown) // неудачный пробный запрос - снова open на cooldown
+	check(now.Add(cooldown-time.Second), "open", false)
+
+	now = now.Add(cooldown)
+	check(now, "half-open", true)
+	b.success()
+	check(now, "closed", true)
+
+	b.failure(now, threshold, cooldown) // после закрытия счетчик начинается заново
+	check(now, "closed", true)
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	var b breaker
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		b.failure(now, 0, time.Minute)
+	}
+	if !b.allow(now) || b.state(now) != "closed" {
+		t.Errorf("threshold 0 must never open the breaker: %s", b.state(now))
+	}
+}
@@ -0,0 +1,227 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("upstream: circuit open") // запросы к источнику временно не отправляются после серии ошибок
+
+var ErrBodyTooLarge = errors.New("upstream: response body too large") // тело ответа больше допустимого размера
+
+type StatusError struct { // источник ответил кодом, отличным от 200
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("upstream: %s: unexpected status code %d", e.URL, e.StatusCode)
+}
+
+type TransportError struct { // запрос не дошел до источника или ответ не был получен полностью (в том числе по таймауту)
+	URL string
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("upstream: %s: %v", e.URL, e.Err)
+}
+
+func (e *TransportError) Unwrap() error { return e.Err }
+
+// Timeout сообщает, что ошибка вызвана истечением таймаута
+func (e *TransportError) Timeout() bool {
+	var netErr net.Error
+	return errors.Is(e.Err, context.DeadlineExceeded) || (errors.As(e.Err, &netErr) && netErr.Timeout())
+}
+
+type Options struct {
+	ConnectTimeout  time.Duration // таймаут установки соединения
+	ReadTimeout     time.Duration // таймаут получения ответа (заголовков и тела) после соединения
+	Retries         int           // количество повторов после первой неудачной попытки
+	BackoffBase     time.Duration // базовая пауза перед повтором, удваивается с каждой попыткой
+	BackoffMax      time.Duration // максимальная пауза перед повтором
+	BreakerFailures int           // после стольких неудачных запросов подряд источник отключается
+	BreakerCooldown time.Duration // на сколько отключается источник, после чего пропускается один пробный запрос
+}
+
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	FetchedAt  time.Time // когда ответ был получен от источника
+	Stale      bool      // true - источник отключен, возвращен последний успешный ответ
+}
+
+type Client struct {
+	opts       Options
+	httpClient *http.Client
+	mu         sync.Mutex
+	breakers   map[string]*breaker
+	lastGood   map[string]*Response // последний успешный ответ по каждому адресу
+	rnd        *rand.Rand
+	sleep      func(ctx context.Context, d time.Duration) error
+}
+
+func New(opts Options) *Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: opts.ConnectTimeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.ResponseHeaderTimeout = opts.ReadTimeout
+	return &Client{
+		opts:       opts,
+		httpClient: &http.Client{Transport: transport},
+		breakers:   make(map[string]*breaker),
+		lastGood:   make(map[string]*Response),
+		rnd:        rand.New(rand.NewSource(time.Now().UnixNano())),
+		sleep:      sleepContext,
+	}
+}
+
+// Get выполняет GET-запрос с повторами и учетом состояния источника. maxBody ограничивает размер тела ответа (0 - без ограничения).
+// Если источник отключен и ранее был успешный ответ, он возвращается с признаком Stale и без ошибки.
+func (c *Client) Get(ctx context.Context, url string, header http.Header, maxBody int64) (*Response, error) {
+	br := c.breaker(url)
+	if !br.allow(time.Now()) {
+		if resp := c.cached(url); resp != nil {
+			fmt.Printf("Upstream %s: circuit open, serving data fetched at %s\n", url, resp.FetchedAt.Format(time.RFC3339))
+			return resp, nil
+		}
+		return nil, fmt.Errorf("%w: %s", ErrCircuitOpen, url)
+	}
+	var lastErr error
+	for attempt := 0; attempt <= c.opts.Retries; attempt++ {
+		if attempt > 0 {
+			if err := c.sleep(ctx, c.backoff(attempt)); err != nil {
+				lastErr = &TransportError{URL: url, Err: err}
+				break
+			}
+		}
+		resp, err := c.do(ctx, url, header, maxBody)
+		if err == nil {
+			br.success()
+			c.mu.Lock()
+			c.lastGood[url] = resp
+			c.mu.Unlock()
+			return resp, nil
+		}
+		lastErr = err
+		if !retryable(err) {
+			break
+		}
+	}
+	br.failure(time.Now(), c.opts.BreakerFailures, c.opts.BreakerCooldown)
+	return nil, lastErr
+}
+
+func (c *Client) do(ctx context.Context, url string, header http.Header, maxBody int64) (*Response, error) {
+	attemptCtx := ctx
+	if c.opts.ConnectTimeout > 0 || c.opts.ReadTimeout > 0 { // общий срок попытки, чтобы зависшее чтение тела не держало запрос бесконечно
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithTimeout(ctx, c.opts.ConnectTimeout+c.opts.ReadTimeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(attemptCtx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, &TransportError{URL: url, Err: err}
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, &TransportError{URL: url, Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024)) // дочитываем, чтобы соединение можно было переиспользовать
+		return nil, &StatusError{URL: url, StatusCode: resp.StatusCode}
+	}
+	var reader io.Reader = resp.Body
+	if maxBody > 0 {
+		reader = io.LimitReader(resp.Body, maxBody+1)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, &TransportError{URL: url, Err: err}
+	}
+	if maxBody > 0 && int64(len(body)) > maxBody {
+		return nil, fmt.Errorf("%w: %s (limit %d bytes)", ErrBodyTooLarge, url, maxBody)
+	}
+	return &Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: body, FetchedAt: time.Now()}, nil
+}
+
+func retryable(err error) bool { // повторяем сетевые ошибки, 5xx и 429. Остальные коды повтором не исправить
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	return !errors.Is(err, ErrBodyTooLarge)
+}
+
+func (c *Client) backoff(attempt int) time.Duration { // экспоненциальная пауза со случайным разбросом (full jitter)
+	d := c.opts.BackoffBase << uint(attempt-1)
+	if d <= 0 || (c.opts.BackoffMax > 0 && d > c.opts.BackoffMax) {
+		d = c.opts.BackoffMax
+	}
+	if d <= 0 {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Duration(c.rnd.Int63n(int64(d) + 1))
+}
+
+func (c *Client) breaker(url string) *breaker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	br, ok := c.breakers[url]
+	if !ok {
+		br = &breaker{}
+		c.breakers[url] = br
+	}
+	return br
+}
+
+func (c *Client) cached(url string) *Response {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	resp, ok := c.lastGood[url]
+	if !ok {
+		return nil
+	}
+	stale := *resp
+	stale.Stale = true
+	return &stale
+}
+
+// State возвращает состояние источника: closed, open или half-open
+func (c *Client) State(url string) string {
+	return c.breaker(url).state(time.Now())
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// StatusCode возвращает код ответа источника из ошибки StatusError или 0, если ответа не было
+func StatusCode(err error) int {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode
+	}
+	return 0
+}
@@ -0,0 +1,171 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// source поднимает источник, который отвечает кодами statuses по очереди (последний - на все остальные запросы)
+func source(t *testing.T, statuses ...int) (string, *int32) {
+	t.Helper()
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&requests, 1)) - 1
+		if n >= len(statuses) {
+			n = len(statuses) - 1
+		}
+		w.WriteHeader(statuses[n])
+		w.Write([]byte(`[]`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, &requests
+}
+
+// newClient - клиент, который вместо пауз перед повторами записывает их длительность
+func newClient(opts Options) (*Client, *[]time.Duration) {
+	c := New(opts)
+	var pauses []time.Duration
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		pauses = append(pauses, d)
+		return ctx.Err()
+	}
+	return c, &pauses
+}
+
+func TestRetries(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		statuses []int
+		maxBody  int64
+		requests int32
+		status   int
+	}{
+		{"recovered", []int{503, 429, 200}, 0, 3, 0},
+		{"exhausted", []int{500}, 0, 4, 500},
+		{"not retryable status", []int{404, 200}, 0, 1, 404},
+		{"not retryable size", []int{200}, 1, 1, 0},
+	} {
+		url, requests := source(t, tc.statuses...)
+		c, pauses := newClient(Options{Retries: 3, BackoffBase: 100 * time.Millisecond, BackoffMax: time.Second})
+		resp, err := c.Get(context.Background(), url, nil, tc.maxBody)
+		if got := atomic.LoadInt32(requests); got != tc.requests || len(*pauses) != int(tc.requests)-1 {
+			t.Errorf("%s: %d requests, %d pauses, want %d requests", tc.name, got, len(*pauses), tc.requests)
+		}
+		switch {
+		case tc.maxBody > 0:
+			if !errors.Is(err, ErrBodyTooLarge) {
+				t.Errorf("%s: got %v, want ErrBodyTooLarge", tc.name, err)
+			}
+		case tc.status != 0:
+			if StatusCode(err) != tc.status {
+				t.Errorf("%s: got %v, want status %d", tc.name, err, tc.status)
+			}
+		case err != nil || resp.StatusCode != http.StatusOK || string(resp.Body) != "[]" || resp.Stale:
+			t.Errorf("%s: got %+v, %v", tc.name, resp, err)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	c, _ := newClient(Options{BackoffBase: 100 * time.Millisecond, BackoffMax: 250 * time.Millisecond})
+	for attempt, limit := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 250 * time.Millisecond, 40: 250 * time.Millisecond} {
+		var longest time.Duration
+		for i := 0; i < 1000; i++ {
+			d := c.backoff(attempt)
+			if d < 0 || d > limit {
+				t.Fatalf("attempt %d: pause %v outside [0, %v]", attempt, d, limit)
+			}
+			if d > longest {
+				longest = d
+			}
+		}
+		if longest < limit/2 { // случайный разброс по всему интервалу, а не постоянная пауза
+			t.Errorf("attempt %d: longest of 1000 pauses is %v, limit %v", attempt, longest, limit)
+		}
+	}
+	if d := (&Client{opts: Options{}}).backoff(1); d != 0 {
+		t.Errorf("no backoff configured: got %v", d)
+	}
+}
+
+func TestReadTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	for name, handler := range map[string]http.HandlerFunc{
+		"headers": func(w http.ResponseWriter, r *http.Request) { // ответ не начинается
+			<-release
+		},
+		"body": func(w http.ResponseWriter, r *http.Request) { // заголовки пришли, тело зависло
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`[`))
+			w.(http.Flusher).Flush()
+			<-release
+		},
+	} {
+		srv := httptest.NewServer(handler)
+		c, pauses := newClient(Options{ReadTimeout: 50 * time.Millisecond, Retries: 1})
+		start := time.Now()
+		_, err := c.Get(context.Background(), srv.URL, nil, 0)
+		var transportErr *TransportError
+		if !errors.As(err, &transportErr) || !transportErr.Timeout() {
+			t.Errorf("%s: got %v, want a timeout", name, err)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second || len(*pauses) != 1 { // таймаут повторяется
+			t.Errorf("%s: took %v with %d retries", name, elapsed, len(*pauses))
+		}
+		srv.CloseClientConnections()
+		srv.Listener.Close()
+	}
+}
+
+func TestConnectTimeout(t *testing.T) {
+	c, pauses := newClient(Options{ConnectTimeout: 50 * time.Millisecond, Retries: 1})
+	c.httpClient.Transport.(*http.Transport).DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		<-ctx.Done() // соединение не устанавливается: пакеты теряются
+		return nil, ctx.Err()
+	}
+	start := time.Now()
+	_, err := c.Get(context.Background(), "http://192.0.2.1/", nil, 0)
+	var transportErr *TransportError
+	if !errors.As(err, &transportErr) || !transportErr.Timeout() {
+		t.Fatalf("got %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second || len(*pauses) != 1 {
+		t.Errorf("took %v with %d retries", elapsed, len(*pauses))
+	}
+}
+
+func TestStale(t *testing.T) {
+	url, requests := source(t, 200, 500)
+	c, _ := newClient(Options{BreakerFailures: 1, BreakerCooldown: time.Hour})
+	if _, err := c.Get(context.Background(), url, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(context.Background(), url, nil, 0); StatusCode(err) != 500 || c.State(url) != "open" {
+		t.Fatalf("failed request: got %v, state %s", err, c.State(url))
+	}
+	resp, err := c.Get(context.Background(), url, nil, 0)
+	if err != nil || !resp.Stale || string(resp.Body) != "[]" {
+		t.Fatalf("open circuit: got %+v, %v, want the last good response", resp, err)
+	}
+	if got := atomic.LoadInt32(requests); got != 2 {
+		t.Errorf("open circuit sent a request: %d requests", got)
+	}
+	if resp, err := c.Get(context.Background(), url, nil, 0); err != nil || !resp.Stale { // последний успешный ответ не помечается сам
+		t.Errorf("second stale read: got %+v, %v", resp, err)
+	}
+
+	url, _ = source(t, 500)
+	if _, err := c.Get(context.Background(), url, nil, 0); StatusCode(err) != 500 {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(context.Background(), url, nil, 0); !errors.Is(err, ErrCircuitOpen) { // успешных ответов не было
+		t.Errorf("open circuit without data: got %v, want ErrCircuitOpen", err)
+	}
+}
@@ -0,0 +1,150 @@
+package voicecall
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Оценка качества звонка по аналогии с MOS: от 1 (плохо) до 4.5 (отлично).
+const (
+	minScore = 1.0
+	maxScore = 4.5
+)
+
+type MetricRange struct { // нормирование одной метрики: значение Good дает 1, значение Bad дает 0, между ними - линейно
+	Weight float64 `json:"weight"`
+	Good   float64 `json:"good"`
+	Bad    float64 `json:"bad"`
+}
+
+type QualityConfig struct {
+	Threshold           float64     `json:"threshold"` // строки с оценкой ниже порога помечаются как плохие
+	ConnectionStability MetricRange `json:"connection_stability"`
+	PurityTTFB          MetricRange `json:"purity_ttfb"`
+	CallDuration        MetricRange `json:"call_duration"`
+	CurrentLoad         MetricRange `json:"current_load"`
+	ResponseTime        MetricRange `json:"response_time"`
+}
+
+// Validate проверяет, что веса неотрицательны, хотя бы один вес задан, а у метрик с весом границы различаются
+func (c QualityConfig) Validate() error {
+	var total float64
+	for name, m := range c.metrics() {
+		if m.Weight < 0 {
+			return fmt.Errorf("voice quality: %s: negative weight", name)
+		}
+		if m.Weight > 0 && m.Good == m.Bad {
+			return fmt.Errorf("voice quality: %s: good and bad values must differ", name)
+		}
+		total += m.Weight
+	}
+	if total == 0 {
+		return fmt.Errorf("voice quality: all weights are zero")
+	}
+	if c.Threshold < minScore || c.Threshold > maxScore {
+		return fmt.Errorf("voice quality: threshold %.2f is outside [%.1f, %.1f]", c.Threshold, minScore, maxScore)
+	}
+	return nil
+}
+
+func (c QualityConfig) metrics() map[string]MetricRange {
+	return map[string]MetricRange{
+		"connection_stability": c.ConnectionStability,
+		"purity_ttfb":          c.PurityTTFB,
+		"call_duration":        c.CallDuration,
+		"current_load":         c.CurrentLoad,
+		"response_time":        c.ResponseTime,
+	}
+}
+
+func (m MetricRange) normalize(v float64) float64 { // приводит значение метрики к диапазону [0, 1]
+	n := (v - m.Bad) / (m.Good - m.Bad)
+	switch {
+	case n < 0:
+		return 0
+	case n > 1:
+		return 1
+	}
+	return n
+}
+
+// Score считает взвешенную оценку качества строки
+func (c QualityConfig) Score(v VoiceData) float64 {
+	parts := []struct {
+		m MetricRange
+		v float64
+	}{
+		{c.ConnectionStability, float64(v.ConnectionStability)},
+		{c.PurityTTFB, float64(v.PurityTTFB)},
+		{c.CallDuration, float64(v.CallDuration)},
+		{c.CurrentLoad, float64(v.CurrentLoad)},
+		{c.ResponseTime, float64(v.ResponseTime)},
+	}
+	var sum, weights float64
+	for _, p := range parts {
+		if p.m.Weight == 0 {
+			continue
+		}
+		sum += p.m.Weight * p.m.normalize(p.v)
+		weights += p.m.Weight
+	}
+	if weights == 0 {
+		return minScore
+	}
+	score := minScore + (maxScore-minScore)*sum/weights
+	return float64(int(score*100+0.5)) / 100 // округляем до сотых
+}
+
+// Apply заполняет в строках оценку качества и признак низкого качества
+func (c QualityConfig) Apply(data []VoiceData) {
+	for i := range data {
+		data[i].QualityScore = c.Score(data[i])
+		data[i].LowQuality = data[i].QualityScore < c.Threshold
+	}
+}
+
+type Ranking struct { // место провайдера или страны в рейтинге качества
+	Rank       int     `json:"rank"`
+	Name       string  `json:"name"`
+	AvgScore   float64 `json:"avg_score"`
+	MinScore   float64 `json:"min_score"`
+	Rows       int     `json:"rows"`
+	LowQuality int     `json:"low_quality"` // количество строк с оценкой ниже порога
+}
+
+// RankBy строит рейтинг по ключу key (провайдер, страна): лучшие по средней оценке - первыми. Оценки должны быть заполнены через Apply
+func RankBy(data []VoiceData, key func(VoiceData) string) []Ranking {
+	groups := make(map[string]*Ranking)
+	var sums = make(map[string]float64)
+	for _, v := range data {
+		name := key(v)
+		g, ok := groups[name]
+		if !ok {
+			g = &Ranking{Name: name, MinScore: v.QualityScore}
+			groups[name] = g
+		}
+		g.Rows++
+		sums[name] += v.QualityScore
+		if v.QualityScore < g.MinScore {
+			g.MinScore = v.QualityScore
+		}
+		if v.LowQuality {
+			g.LowQuality++
+		}
+	}
+	rankings := make([]Ranking, 0, len(groups))
+	for name, g := range groups {
+		g.AvgScore = float64(int(sums[name]/float64(g.Rows)*100+0.5)) / 100
+		rankings = append(rankings, *g)
+	}
+	sort.Slice(rankings, func(i, j int) bool {
+		if rankings[i].AvgScore != rankings[j].AvgScore {
+			return rankings[i].AvgScore > rankings[j].AvgScore
+		}
+		return rankings[i].Name < rankings[j].Name
+	})
+	for i := range rankings {
+		rankings[i].Rank = i + 1
+	}
+	return rankings
+}
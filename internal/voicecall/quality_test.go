@@ -0,0 +1,75 @@
+package voicecall
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQualityConfigValidate(t *testing.T) {
+	valid := QualityConfig{Threshold: 2.5, ResponseTime: MetricRange{Weight: 1, Good: 100, Bad: 1100}}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("valid config: %v", err)
+	}
+	for _, tc := range []struct {
+		change func(c *QualityConfig)
+		want   string
+	}{
+		{func(c *QualityConfig) { c.CurrentLoad = MetricRange{Weight: -1, Good: 0, Bad: 100} }, "current_load: negative weight"},
+		{func(c *QualityConfig) { c.PurityTTFB = MetricRange{Weight: 1, Good: 300, Bad: 300} }, "purity_ttfb: good and bad values must differ"},
+		{func(c *QualityConfig) { c.ResponseTime.Weight = 0 }, "all weights are zero"},
+		{func(c *QualityConfig) { c.Threshold = 0.5 }, "outside"},
+		{func(c *QualityConfig) { c.Threshold = 5 }, "outside"},
+	} {
+		c := valid
+		tc.change(&c)
+		if err := c.Validate(); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%+v: got %v, want an error containing %q", c, err, tc.want)
+		}
+	}
+	c := valid // у метрики без веса границы могут совпадать
+	c.CallDuration = MetricRange{Good: 60, Bad: 60}
+	if err := c.Validate(); err != nil {
+		t.Errorf("zero-weight metric with equal bounds: %v", err)
+	}
+}
+
+func TestScore(t *testing.T) {
+	c := QualityConfig{
+		ResponseTime: MetricRange{Weight: 1, Good: 100, Bad: 1100}, // чем меньше, тем лучше
+		CurrentLoad:  MetricRange{Weight: 3, Good: 0, Bad: 100},
+	}
+	for _, tc := range []struct {
+		v    VoiceData
+		want float64
+	}{
+		{VoiceData{ResponseTime: 100, CurrentLoad: 0}, maxScore},
+		{VoiceData{ResponseTime: 1100, CurrentLoad: 100}, minScore},
+		{VoiceData{ResponseTime: 600, CurrentLoad: 25}, 3.41},                                    // (0.5*1 + 0.75*3) / 4 = 0.6875
+		{VoiceData{ResponseTime: 600, CurrentLoad: 100}, 1.44},                                   // (0.5*1 + 0*3) / 4 = 0.125
+		{VoiceData{ResponseTime: 50, CurrentLoad: 200}, 1.88},                                    // значения за границами обрезаются: (1*1 + 0*3) / 4
+		{VoiceData{ResponseTime: 100, CurrentLoad: 0, CallDuration: 1, PurityTTFB: 9}, maxScore}, // метрики без веса не учитываются
+	} {
+		if got := c.Score(tc.v); got != tc.want {
+			t.Errorf("%+v: got %v, want %v", tc.v, got, tc.want)
+		}
+	}
+
+	c = QualityConfig{ConnectionStability: MetricRange{Weight: 2, Good: 1, Bad: 0.5}} // чем больше, тем лучше
+	for v, want := range map[float32]float64{1: maxScore, 0.75: 2.75, 0.5: minScore, 0.2: minScore, 0.875: 3.63} {
+		if got := c.Score(VoiceData{ConnectionStability: v}); got != want {
+			t.Errorf("connection_stability %v: got %v, want %v", v, got, want)
+		}
+	}
+}
+
+func TestApplyThreshold(t *testing.T) {
+	c := QualityConfig{Threshold: 2.75, ConnectionStability: MetricRange{Weight: 1, Good: 1, Bad: 0.5}}
+	data := []VoiceData{{ConnectionStability: 0.75}, {ConnectionStability: 0.7}}
+	c.Apply(data)
+	if data[0].QualityScore != 2.75 || data[0].LowQuality {
+		t.Errorf("score at the threshold: %+v", data[0])
+	}
+	if data[1].QualityScore != 2.4 || !data[1].LowQuality {
+		t.Errorf("score below the threshold: %+v", data[1])
+	}
+}
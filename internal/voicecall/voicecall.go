@@ -1,13 +1,17 @@
 package voicecall
 
 import (
+	"errors"
 	"finalwork/internal/countries"
-	"io"
+	"finalwork/internal/input"
+	"finalwork/internal/providers"
 	"strconv"
 	"strings"
+)
 
-	"log"
-	"os"
+var (
+	errNegative   = errors.New("negative value")
+	errOutOfRange = errors.New("value out of range")
 )
 
 type VoiceData struct {
@@ -19,26 +23,17 @@ type VoiceData struct {
 	PurityTTFB          int     `json:"purity_ttfb"`
 	CallDuration        int     `json:"call_duration"`
 	UnknownField        int     `json:"unknown_field"` // обозначил как "неизвестное поле", т.к в описании ("Этап 4 п.5")  в перечне 7 полей, а не 8. Из файла Voicedata видно, что оно является числовым типом.
+	QualityScore        float64 `json:"quality_score"` // оценка качества (1..4.5), заполняется через QualityConfig.Apply
+	LowQuality          bool    `json:"low_quality"`   // true, если оценка ниже порога из настроек
 }
 
 type VoiceCountryRepository countries.CountryRepository // локальная обёртка над типом countries.CountryRepository
 
-var validProviders = map[string]struct{}{ // создадим мап с ключами, которые соответствуют названиям допустимых провайдеров
-	"TransparentCalls": {},
-	"E-Voice":          {},
-	"JustPhone":        {},
-}
-
-func (r *VoiceCountryRepository) GetVoiceData(fileName string) ([]VoiceData, error) { // функция сбора данных о системе Voicecall
+func (r *VoiceCountryRepository) GetVoiceData(files input.Files, fileName string, catalog *providers.Catalog, stats *providers.RowStats) ([]VoiceData, error) { // функция сбора данных о системе Voicecall
 	var voiceDataSlice []VoiceData
-	file, err := os.Open(fileName) // открываем файл
-	if err != nil {
-		log.Panic(err)
-	}
-	defer file.Close()
-	bytes, err := io.ReadAll(file) // читаем, получаем слайс байтов (используем вместо ioutil)
+	bytes, err := files.ReadFile(fileName) // читаем файл, получаем слайс байтов
 	if err != nil {
-		log.Panic(err)
+		return voiceDataSlice, err
 	}
 	sep := "\n"                                      // создаём сепаратор
 	stringSplit := strings.Split(string(bytes), sep) // разделяем весь текст на слайс подстрок по "\n"
@@ -46,11 +41,15 @@ func (r *VoiceCountryRepository) GetVoiceData(fileName string) ([]VoiceData, err
 		sep := ";"                                 // создаём сепаратор
 		singleStringSlice := strings.Split(v, sep) // разделяем подстроку по разделителю ";"
 		if len(singleStringSlice) < 8 {            // проверяем, что кол-во элементов не меньше 8
+			stats.RejectMalformed(v)
 			continue
 		} else {
-			voiceDataStruct, ok := r.parseStringleSlice(singleStringSlice) // парсинг в структуру и проверка требованиям
-			if ok {                                                        //если true
+			voiceDataStruct, ok := r.parseStringleSlice(singleStringSlice, catalog) // парсинг в структуру и проверка требованиям
+			if ok {                                                                 //если true
 				voiceDataSlice = append(voiceDataSlice, voiceDataStruct) // добавление структуры в результирующий срез
+				stats.Accept(voiceDataStruct.Provider)
+			} else {
+				stats.Reject(catalog, singleStringSlice[3])
 			}
 		}
 	}
@@ -58,12 +57,18 @@ func (r *VoiceCountryRepository) GetVoiceData(fileName string) ([]VoiceData, err
 }
 
 // функция создания структуры из слайса строк и проверки поля Country по коду alpha-2
-func (r *VoiceCountryRepository) parseStringleSlice(s []string) (VoiceData, bool) {
+func (r *VoiceCountryRepository) parseStringleSlice(s []string, catalog *providers.Catalog) (VoiceData, bool) {
+	if len(s) < 8 { // обрезанная строка
+		return VoiceData{}, false
+	}
 	fAtoi := func(s string) (int, error) { // функция конвертации строки в int
 		intNumb, err := strconv.Atoi(s)
 		if err != nil {
 			return intNumb, err
 		}
+		if intNumb < 0 { // все числовые поля - величины, которые не бывают отрицательными
+			return intNumb, errNegative
+		}
 		return intNumb, nil
 	}
 	fAtof := func(s string) (float32, error) { // функция конвертации строки в float32
@@ -71,6 +76,9 @@ func (r *VoiceCountryRepository) parseStringleSlice(s []string) (VoiceData, bool
 		if err != nil {
 			return float32(intNumb), err
 		}
+		if !(intNumb >= 0 && intNumb <= 1) { // стабильность соединения - доля от 0 до 1 (NaN и бесконечности не проходят, их не записать в JSON)
+			return float32(intNumb), errOutOfRange
+		}
 		return float32(intNumb), nil
 	}
 
@@ -103,9 +111,10 @@ func (r *VoiceCountryRepository) parseStringleSlice(s []string) (VoiceData, bool
 		return voiceDataStruct, false
 	}
 
-	if _, ok := r.CountryByCode[countries.Code(voiceDataStruct.Country)]; ok { // проверяем по alpha-2, обращаясь к хранилищу VoiceCountryRepository по ключу = значению поля Country у полученной структуры
-		if _, ok := validProviders[voiceDataStruct.Provider]; ok { // проверяем провайдера, обращаясь к мап по ключу = значению поля Provider у полученной структуры
-			return voiceDataStruct, true // если значения по ключам в мап имеются, возвращаем структуру и true
+	if _, ok := r.Lookup(countries.Code(voiceDataStruct.Country)); ok { // проверяем по alpha-2, обращаясь к хранилищу VoiceCountryRepository по ключу = значению поля Country у полученной структуры
+		if name, ok := catalog.Canonical(providers.Voice, voiceDataStruct.Provider); ok { // проверяем провайдера по каталогу (с учетом псевдонимов и без учета регистра)
+			voiceDataStruct.Provider = name // приводим имя к каноническому
+			return voiceDataStruct, true    // если страна и провайдер допустимы, возвращаем структуру и true
 		}
 	}
 	return voiceDataStruct, false // возвращаем структуру и false
@@ -0,0 +1,111 @@
+package voicecall
+
+import (
+	"encoding/json"
+	"finalwork/internal/countries"
+	"finalwork/internal/input/inputtest"
+	"finalwork/internal/providers"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testProviders = []providers.Provider{
+	{Name: "TransparentCalls", Channels: []providers.Channel{providers.Voice}},
+	{Name: "E-Voice", Channels: []providers.Channel{providers.Voice}},
+	{Name: "JustPhone", Channels: []providers.Channel{providers.Voice}},
+}
+
+func TestParseStringleSlice(t *testing.T) {
+	repo, catalog := inputtest.Setup(t, testProviders...)
+	r := VoiceCountryRepository(repo)
+	for _, tc := range []struct {
+		line string
+		want VoiceData
+		ok   bool
+	}{
+		{"US;97;286;E-Voice;0.87;347;22;38", VoiceData{Country: "US", CurrentLoad: 97, ResponseTime: 286, Provider: "E-Voice", ConnectionStability: 0.87, PurityTTFB: 347, CallDuration: 22, UnknownField: 38}, true},
+		{"RU;0;30;justphone;1;2;0;3", VoiceData{Country: "RU", ResponseTime: 30, Provider: "JustPhone", ConnectionStability: 1, PurityTTFB: 2, UnknownField: 3}, true},
+		{"US;97;286;E-Voice;NaN;347;22;38", VoiceData{}, false},
+		{"US;97;286;E-Voice;1.5;347;22;38", VoiceData{}, false},
+		{"US;-1;286;E-Voice;0.87;347;22;38", VoiceData{}, false},
+		{"XX;97;286;E-Voice;0.87;347;22;38", VoiceData{}, false},
+		{"US;97;286;Topolo;0.87;347;22;38", VoiceData{}, false},
+		{"RU;55;83;TransparentCalls;0.", VoiceData{}, false},
+	} {
+		row, ok := r.parseStringleSlice(strings.Split(tc.line, ";"), catalog)
+		if ok != tc.ok || ok && row != tc.want {
+			t.Errorf("%q: got %+v, %v, want %+v, %v", tc.line, row, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestGetVoiceData(t *testing.T) {
+	repo, catalog := inputtest.Setup(t, testProviders...)
+	r := VoiceCountryRepository(repo)
+	for _, tc := range []struct {
+		data  string
+		rows  []VoiceData
+		stats *providers.RowStats
+	}{
+		{
+			"US;97;286;E-Voice;0.87;347;22;38\nRU;55;83;TransparentCalls;0.\nXX;1;1;JustPhone;0.5;1;1;1\n\n",
+			[]VoiceData{{Country: "US", CurrentLoad: 97, ResponseTime: 286, Provider: "E-Voice", ConnectionStability: 0.87, PurityTTFB: 347, CallDuration: 22, UnknownField: 38}},
+			&providers.RowStats{Accepted: map[string]int{"E-Voice": 1}, Rejected: map[string]int{"JustPhone": 1}, Unattributed: 1},
+		},
+		{"", nil, &providers.RowStats{Accepted: map[string]int{}, Rejected: map[string]int{}}},
+	} {
+		stats := providers.NewRowStats()
+		rows, err := r.GetVoiceData(inputtest.Bytes(tc.data), "voice.data", catalog, stats)
+		if err != nil || !reflect.DeepEqual(rows, tc.rows) || !reflect.DeepEqual(stats, tc.stats) {
+			t.Errorf("%q: got %+v, %+v, %v, want %+v, %+v", tc.data, rows, stats, err, tc.rows, tc.stats)
+		}
+	}
+}
+
+// checkRow проверяет строку, которую разбор признал допустимой
+func checkRow(t *testing.T, r VoiceCountryRepository, catalog *providers.Catalog, row VoiceData) {
+	t.Helper()
+	inputtest.CheckRow(t, countries.CountryRepository(r), catalog, providers.Voice, row.Country, row.Provider)
+	s := float64(row.ConnectionStability)
+	if math.IsNaN(s) || s < 0 || s > 1 {
+		t.Fatalf("accepted connection stability %v", row.ConnectionStability)
+	}
+	if row.CurrentLoad < 0 || row.ResponseTime < 0 || row.PurityTTFB < 0 || row.CallDuration < 0 || row.UnknownField < 0 {
+		t.Fatalf("accepted a negative value in %+v", row)
+	}
+	if _, err := json.Marshal(row); err != nil {
+		t.Fatalf("accepted row cannot be written to the response: %v", err)
+	}
+}
+
+func FuzzParseStringleSlice(f *testing.F) {
+	for _, line := range []string{"US;97;286;E-Voice;0.87;347;22;38", "RU;0;30;justphone;1;2;0;3", "RU;55;83;TransparentCalls;0.", ";;;;;;;", "GB;23;1109;Trans", "US;97;286;E-Voice;NaN;347;22;38"} {
+		f.Add(line)
+	}
+	repo, catalog := inputtest.Setup(f, testProviders...)
+	r := VoiceCountryRepository(repo)
+	f.Fuzz(func(t *testing.T, line string) {
+		row, ok := r.parseStringleSlice(strings.Split(line, ";"), catalog)
+		if ok {
+			checkRow(t, r, catalog, row)
+		}
+	})
+}
+
+func FuzzGetVoiceData(f *testing.F) {
+	f.Add([]byte("US;97;286;E-Voice;0.87;347;22;38\nRU;55;83;TransparentCalls;0.\nFR8;1120;TransparentCalls;0.64;240;80;39\n"))
+	f.Add([]byte(""))
+	repo, catalog := inputtest.Setup(f, testProviders...)
+	r := VoiceCountryRepository(repo)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		rows, err := r.GetVoiceData(inputtest.Bytes(data), "voice.data", catalog, providers.NewRowStats())
+		if err != nil {
+			t.Fatalf("error for file contents: %v", err)
+		}
+		for _, row := range rows {
+			checkRow(t, r, catalog, row)
+		}
+	})
+}
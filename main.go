@@ -3,21 +3,41 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"finalwork/internal/archive"
+	"finalwork/internal/auth"
 	"finalwork/internal/billing"
+	"finalwork/internal/config"
+	"finalwork/internal/cors"
 	"finalwork/internal/countries"
 	"finalwork/internal/email"
+	"finalwork/internal/fetch"
+	"finalwork/internal/httpcache"
 	"finalwork/internal/incident"
+	"finalwork/internal/input"
+	"finalwork/internal/maintenance"
 	"finalwork/internal/mms"
+	"finalwork/internal/notify"
+	"finalwork/internal/providers"
+	"finalwork/internal/ratelimit"
+	"finalwork/internal/rules"
+	"finalwork/internal/scorecard"
 	"finalwork/internal/sms"
+	"finalwork/internal/sorting"
 	"finalwork/internal/support"
+	"finalwork/internal/tlsreload"
+	"finalwork/internal/upstream"
 	"finalwork/internal/voicecall"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
+	"golang.org/x/sync/singleflight"
 )
 
 type ( // локальные типы над типами данных из других пакетов
@@ -44,46 +64,287 @@ type ResultSetT struct { // родительская структура (отф
 	Billing   BillingData              `json:"billing"`
 	Support   []int                    `json:"support"`
 	Incidents []IncidentData           `json:"incident"`
+
+	VoiceQuality VoiceQualityT     `json:"voice_quality"`         // рейтинги качества звонков
+	Overall      rules.Result      `json:"overall"`               // общее состояние и состояние систем по правилам из настроек, с пояснениями
+	Maintenance  []MaintenanceRowT `json:"maintenance,omitempty"` // провайдеры в странах, на которые приходятся текущие плановые работы
+
+	UnknownFields map[string][]string `json:"unknown_fields,omitempty"` // поля ответов MMS, Support и Incident, которых нет в структурах сервиса, по системам
+	Stale         []string            `json:"stale,omitempty"`          // системы, источник которых отключен: данные из последнего успешного ответа
 }
 
-// файлы содержащие адреса получения данных из файлов и через API
-const smsFileName = "simulator/skillbox-diploma/sms.data"
-const mmsUrlAddr string = "http://127.0.0.1:8383/mms"
-const voiceFileName = "simulator/skillbox-diploma/voice.data"
-const emailFileName = "simulator/skillbox-diploma/email.data"
-const billingFileName = "simulator/skillbox-diploma/billing.data"
-const supportUrlAddr string = "http://127.0.0.1:8383/support"
-const incidentUrlAddr string = "http://127.0.0.1:8383/accendent"
+type MaintenanceRowT struct { // строки данных системы одного провайдера в одной стране, попавшие в окно плановых работ
+	System   string `json:"system"`
+	Provider string `json:"provider"`
+	Country  string `json:"country"` // как в строках системы: название для sms и mms, код alpha-2 для voice_call и email
+	WindowID string `json:"window_id"`
+}
+
+type VoiceQualityT struct { // рейтинги качества звонков по провайдерам и странам
+	Threshold  float64             `json:"threshold"`   // порог, ниже которого строка считается плохой
+	LowQuality int                 `json:"low_quality"` // количество строк ниже порога
+	ByProvider []voicecall.Ranking `json:"by_provider"`
+	ByCountry  []voicecall.Ranking `json:"by_country"`
+}
+
+var configFileName = flag.String("config", "", "JSON-файл с настройками сервиса. Не указанные в нем параметры берутся по умолчанию")
+var replayFileName = flag.String("replay", "", "файл архива: /systemsstatus строится из записанных в нем данных вместо файлов и API симулятора")
+var countriesFileName = flag.String("countries", "", "CSV-файл со списком стран (\"Название;alpha2\"). По умолчанию используется вшитый список")
+
+var (
+	countryRepo      countries.CountryRepository      // хранилище типа CountryRepository(мапа с ключом==alpha2 и значением==названию страны)
+	smsCountryRepo   sms.SmsCountryRepository         // обертка над countryRepo
+	mmsCountryRepo   mms.MmsCountryRepository         // обертка над countryRepo
+	voiceCountryRepo voicecall.VoiceCountryRepository // обертка над countryRepo
+	emailCountryRepo email.EmailCountryRepository     // обертка над countryRepo
+)
 
 var (
-	countryRepo      = countries.ISOCountryRepository()              // создаем хранилище типа CountryRepository(мапа с ключом==alpha2 и значением==названию страны)
-	smsCountryRepo   = sms.SmsCountryRepository(countryRepo)         // обертка над countryRepo
-	mmsCountryRepo   = mms.MmsCountryRepository(countryRepo)         // обертка над countryRepo
-	voiceCountryRepo = voicecall.VoiceCountryRepository(countryRepo) // обертка над countryRepo
-	emailCountryRepo = email.EmailCountryRepository(countryRepo)     // обертка над countryRepo
+	cfg              config.Config        // настройки сервиса
+	providerCatalog  *providers.Catalog   // каталог допустимых провайдеров по каналам
+	incidentRules    *incident.Classifier // определение системы, серьезности и стран инцидента по теме
+	statusRules      *rules.Engine        // вывод общего состояния из собранных данных
+	maintenanceStore *maintenance.Store   // плановые работы и ручные состояния
+	notifier         *notify.Notifier     // письма подписчикам; nil, если оповещения выключены
+	historyStore     *scorecard.Store     // снимки метрик провайдеров
+	apiSource        fetch.Source         // общий клиент и ограничения для систем MMS, Support и Incident
+	dataFiles        input.Files          // источник файлов систем SMS, Voice, Email и Billing
+	replayArchive    *archive.Archive     // архив в режиме воспроизведения (-replay), иначе nil
+	authenticator    *auth.Authenticator  // проверка API-ключей и JWT
+	rateLimiter      *ratelimit.Limiter   // ограничение частоты запросов
 )
 
+var (
+	collectGroup singleflight.Group // объединение одновременных одинаковых запросов сбора данных
+	collectMu    sync.Mutex         // в каждый момент выполняется только один сбор данных
+)
+
+type collected struct { // результат сбора данных и время его завершения
+	rT ResultT
+	at time.Time
+}
+
+func collectResultT(loc countries.Locale) (ResultT, time.Time) { // функция получения ResultT и времени сбора: ожидающие одного и того же результата получают его от одного сбора
+	v, _, _ := collectGroup.Do(loc.Tag.String(), func() (interface{}, error) {
+		collectMu.Lock()
+		defer collectMu.Unlock()
+		rT := getResultT(loc)
+		return collected{rT: rT, at: time.Now()}, nil
+	})
+	c := v.(collected)
+	return c.rT, c.at
+}
+
+// rateLimitKey - ключ клиента для ограничения частоты. Ограничение стоит перед аутентификацией, чтобы неудачные попытки
+// тоже расходовали токены: клиент с действующим API-ключом, JWT или сертификатом получает свою корзину, остальные - корзину IP
+func rateLimitKey(r *http.Request) string {
+	if p, err := authenticator.Authenticate(r); err == nil && p.Name != "" && p.Name != "anonymous" {
+		return "client:" + p.Name
+	}
+	return "ip:" + ratelimit.ClientIP(r, cfg.RateLimit.TrustProxy)
+}
+
+func newHandler() http.Handler { // функция сборки обработчика сервера: роутер, ограничение частоты, аутентификация и CORS
+	r := newRouter()                            // создаем роутер с обработчиками
+	r.Use(rateLimiter.Middleware(rateLimitKey)) // ограничение частоты запросов по клиенту, до проверки учетных данных
+	r.Use(authenticator.Middleware)             // определение клиента по API-ключу или JWT
+	return cors.New(cfg.CORS).Handler(r)        // CORS снаружи, чтобы предварительные запросы не требовали учетных данных
+}
+
+func internalOnly(h http.HandlerFunc) http.HandlerFunc { // обработчики, доступные только клиентам со scope internal
+	return auth.RequireScope(auth.ScopeInternal, h)
+}
+
+func initConfig(fileName string) error { // функция загрузки настроек и построения зависящих от них структур
+	c, err := config.Load(fileName)
+	if err != nil {
+		return err
+	}
+	return useConfig(c)
+}
+
+func useConfig(c config.Config) error { // функция построения структур по настройкам. При ошибке действуют прежние настройки
+	catalog, err := providers.NewCatalog(c.Providers)
+	if err != nil {
+		return err
+	}
+	if err := c.VoiceQuality.Validate(); err != nil {
+		return err
+	}
+	if err := c.RateLimit.Validate(); err != nil {
+		return err
+	}
+	classifier, err := incident.NewClassifier(c.Incidents)
+	if err != nil {
+		return err
+	}
+	engine, err := rules.New(c.StatusRules, incident.Systems, statusVars(ResultSetT{})) // условия проверяются на пустых данных
+	if err != nil {
+		return err
+	}
+	store, err := maintenance.Open(c.Maintenance.File)
+	if err != nil {
+		return err
+	}
+	var n *notify.Notifier
+	if c.Notify.Enabled {
+		if n, err = notify.New(c.Notify, incident.Systems); err != nil {
+			return err
+		}
+	}
+	a, err := auth.New(c.Auth, c.Server.TLS.AllowedCNs)
+	if err != nil {
+		return err
+	}
+	cfg = c
+	setCoverage(nil) // страны прежнего сбора могли быть получены из других источников
+	dataFiles = input.Disk{}
+	providerCatalog = catalog
+	incidentRules = classifier
+	statusRules = engine
+	maintenanceStore = store
+	notifier = n
+	authenticator = a
+	rateLimiter = ratelimit.New(c.RateLimit)
+	historyStore = scorecard.NewStore(c.History.Dir)
+	header := make(http.Header)
+	for k, v := range c.Upstream.Headers {
+		header.Set(k, v)
+	}
+	apiSource.Options = fetch.Options{
+		MaxBodySize:  c.Upstream.MaxBodySize,
+		ContentTypes: c.Upstream.ContentTypes,
+		Strict:       c.Upstream.StrictDecoding,
+		Header:       header,
+	}
+	apiSource.Client = upstream.New(upstream.Options{
+		ConnectTimeout:  c.Upstream.ConnectTimeout.Duration,
+		ReadTimeout:     c.Upstream.ReadTimeout.Duration,
+		Retries:         c.Upstream.Retries,
+		BackoffBase:     c.Upstream.BackoffBase.Duration,
+		BackoffMax:      c.Upstream.BackoffMax.Duration,
+		BreakerFailures: c.Upstream.BreakerFailures,
+		BreakerCooldown: c.Upstream.BreakerCooldown.Duration,
+	})
+	return nil
+}
+
+func initCountryRepositories(fileName string) error { // функция создания хранилища стран и оберток над ним
+	repo, err := countries.ISOCountryRepository(fileName)
+	if err != nil {
+		return err
+	}
+	useCountryRepository(repo)
+	return nil
+}
+
+func useCountryRepository(repo countries.CountryRepository) { // функция подмены хранилища стран и оберток над ним
+	countryRepo = repo
+	smsCountryRepo = sms.SmsCountryRepository(countryRepo)
+	mmsCountryRepo = mms.MmsCountryRepository(countryRepo)
+	voiceCountryRepo = voicecall.VoiceCountryRepository(countryRepo)
+	emailCountryRepo = email.EmailCountryRepository(countryRepo)
+}
+
+func newRouter() *mux.Router { // роутер со всеми обработчиками, без промежуточных слоев аутентификации и ограничения частоты
+	r := mux.NewRouter()
+	r.HandleFunc("/", handleConnection)                                                                // добавляем к роутеру обработку функции handleConnection
+	r.HandleFunc("/systemsstatus", getSystemsData)                                                     // добавляем к роутеру обработку функции getSystemsData
+	r.HandleFunc("/countries/reload", internalOnly(reloadCountries)).Methods("POST")                   // перечитывание списка стран без перезапуска
+	r.HandleFunc("/providers", internalOnly(getProviders)).Methods("GET")                              // каталог провайдеров с каналами и странами
+	r.HandleFunc("/providers/scorecards", internalOnly(getScorecards)).Methods("GET")                  // карточки провайдеров по текущему или историческим снимкам
+	r.HandleFunc("/providers/{name}", internalOnly(getProvider)).Methods("GET")                        // один провайдер (по имени или псевдониму)
+	r.HandleFunc("/maintenance/windows", internalOnly(getMaintenanceWindows)).Methods("GET")           // текущие и запланированные плановые работы
+	r.HandleFunc("/maintenance/windows", internalOnly(addMaintenanceWindow)).Methods("POST")           // запланировать работы
+	r.HandleFunc("/maintenance/windows/{id}", internalOnly(deleteMaintenanceWindow)).Methods("DELETE") // отменить или завершить работы
+	r.HandleFunc("/maintenance/overrides", internalOnly(getOverrides)).Methods("GET")                  // действующие ручные состояния
+	r.HandleFunc("/maintenance/overrides", internalOnly(addOverride)).Methods("POST")                  // задать состояние вручную
+	r.HandleFunc("/maintenance/overrides/{id}", internalOnly(deleteOverride)).Methods("DELETE")        // снять ручное состояние
+	r.HandleFunc("/subscriptions", subscribe).Methods("POST")                                          // подписка на письма (требует подтверждения)
+	r.HandleFunc("/subscriptions", internalOnly(getSubscribers)).Methods("GET")                        // список подписчиков
+	r.HandleFunc("/subscriptions/confirm", confirmSubscription).Methods("GET", "POST")                 // ссылка подтверждения из письма: страница с кнопкой и подтверждение
+	r.HandleFunc("/subscriptions/unsubscribe", unsubscribe).Methods("GET", "POST")                     // ссылка отписки из письма: страница с кнопкой и отписка (в том числе одним щелчком, RFC 8058)
+	return r
+}
+
 func main() {
-	r := mux.NewRouter()                           // создаем роутер
-	r.HandleFunc("/", handleConnection)            // добавляем к роутеру обработку функции handleConnection
-	r.HandleFunc("/systemsstatus", getSystemsData) // добавляем к роутеру обработку функции getSystemsData
-	server := http.Server{                         // создаем сервер
-		Addr:    "localhost:8282", // адрес для прослушивания
-		Handler: r,                // роутер
+	flag.Parse()
+	if err := initConfig(*configFileName); err != nil {
+		fmt.Println("Error loading config:", err)
+		os.Exit(1)
+	}
+	if key := authenticator.GeneratedKey(); key != "" { // без ключа в настройках служебные обработчики иначе недоступны
+		fmt.Println("No credential with scope internal configured, API key for this run:", key)
+	}
+	if err := initCountryRepositories(*countriesFileName); err != nil {
+		fmt.Println("Error loading countries:", err)
+		os.Exit(1)
+	}
+	if *replayFileName != "" {
+		if err := initReplay(*replayFileName); err != nil {
+			fmt.Println("Error loading archive:", err)
+			os.Exit(1)
+		}
+		if err := checkReplay(); err != nil { // расхождение с архивом - код выхода 1, например для проверки изменений обработки
+			fmt.Println("Replay:", err)
+			os.Exit(1)
+		}
+		fmt.Println("Replay: result matches the archive")
+	}
+	server := http.Server{ // создаем сервер
+		Addr:    cfg.Server.Addr, // адрес для прослушивания
+		Handler: newHandler(),    // роутер с ограничением частоты, аутентификацией и CORS
+	}
+	var certs *tlsreload.Reloader // сертификаты сервера, перечитываются без перезапуска
+	if cfg.Server.TLS.Enabled {
+		var err error
+		certs, err = tlsreload.New(cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile, cfg.Server.TLS.ClientCAFile)
+		if err != nil {
+			fmt.Println("Error loading TLS certificates:", err)
+			os.Exit(1)
+		}
+		server.TLSConfig = certs.TLSConfig(cfg.Server.TLS.RequireClientCert)
+		if cfg.Server.TLS.ReloadInterval.Duration > 0 {
+			go certs.Watch(cfg.Server.TLS.ReloadInterval.Duration, nil) // nil-канал: следим до завершения программы
+		}
 	}
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT)
+	if certs != nil { // SIGHUP перечитывает сертификаты только при включенном TLS, иначе действует как обычно (завершает программу)
+		signal.Notify(sigChan, syscall.SIGHUP)
+	}
 	go func() { // горутина закрывающая
 		for {
-			s := <-sigChan // ожидаем сигнала os.Interrupt
+			s := <-sigChan           // ожидаем сигнала os.Interrupt
+			if s == syscall.SIGHUP { // SIGHUP - перечитать сертификаты, открытые соединения не затрагиваются
+				if err := certs.Reload(); err != nil {
+					fmt.Println("TLS certificates reload failed:", err)
+				} else {
+					fmt.Println("TLS certificates reloaded")
+				}
+				continue
+			}
 			fmt.Println("Сигнал:", s)
 			fmt.Println("Выходим из программы")
+			if notifier != nil {
+				notifier.Wait() // дожидаемся отправки писем
+			}
 			if err := server.Shutdown(context.Background()); err != nil { // закрываем сервер
 				fmt.Printf("Server shutdown error: %s\n", err)
 			}
 		}
 	}()
-	fmt.Println("Starting server on localhost: 8282")
+	if cfg.History.Interval.Duration > 0 && replayArchive == nil {
+		go collectPeriodically(cfg.History.Interval.Duration) // фоновый сбор данных для истории карточек
+	}
+	if certs != nil {
+		fmt.Println("Starting server on https://" + server.Addr)
+		if err := server.ListenAndServeTLS("", ""); err != nil { // сертификаты берутся из server.TLSConfig
+			fmt.Println("ListenAndServeTLS:", err)
+		}
+		return
+	}
+	fmt.Println("Starting server on " + server.Addr)
 	if err := server.ListenAndServe(); err != nil { // запускаем сервер
 		fmt.Println("ListenAndServe:", err)
 	}
@@ -94,134 +355,133 @@ func handleConnection(w http.ResponseWriter, r *http.Request) { // функци
 	fmt.Fprintf(w, "OK") // возвращаем в ответ "OK"
 }
 
+func reloadCountries(w http.ResponseWriter, r *http.Request) { // функция перечитывания списка стран. При ошибке остается прежний список
+	if err := countryRepo.Reload(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]interface{}{"status": false, "error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": true, "countries": countryRepo.Len()})
+}
+
 func getSystemsData(w http.ResponseWriter, r *http.Request) { // функция возвращающая в Response, конечную структуру с отфильтрованными данными в формате json.
-	if r.Method == "GET" {
-		systemData := getResultT() // вызываем функцию получения конечной родительской структуры
-		//fmt.Fprintf(w, "status: %v", systemData)
-		csD, err := json.Marshal(systemData) // конвертация структуры в json ([]byte)
+	if r.Method == "GET" || r.Method == "HEAD" {
+		loc := countries.MatchLocale(r.URL.Query().Get("lang"), r.Header.Get("Accept-Language")) // язык названий стран: lang= или Accept-Language
+		order, err := parseSort(r.URL.Query()["sort"], loc.Collator())                           // порядок списков, запрошенный клиентом (sort=раздел:ключ,-ключ)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, ResultT{Status: false, Error: err.Error()})
+			return
+		}
+		systemData, collectedAt := collectResultT(loc) // вызываем функцию получения конечной родительской структуры (одновременные запросы объединяются)
+		now := time.Now()
+		systemData = applyMaintenance(systemData, loc, now) // плановые работы и ручные состояния действуют на момент запроса, а не сбора
+		if changedAt := maintenanceStore.ChangedAt(now); changedAt.After(collectedAt) {
+			collectedAt = changedAt // Last-Modified: ответ изменился без нового сбора
+		}
+		var response interface{} = systemData
+		if p, _ := auth.FromContext(r.Context()); !p.HasScope(auth.ScopeInternal) && !p.HasScope(auth.ScopeFull) {
+			response = publicResult(systemData) // без scope internal или full отдаем только агрегированное состояние систем
+		} else if systemData.Status && !order.empty() {
+			systemData.Data = sortResult(systemData.Data, order, loc.Collator()) // сортируем копии: собранные данные общие для всех запросов
+			response = systemData
+		}
+		csD, err := json.Marshal(response) // конвертация структуры в json ([]byte)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte(err.Error()))
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write(csD) // записываем данные в Response
+		w.Header().Set("Content-Language", loc.Tag.String())
+		w.Header().Set("Cache-Control", "no-cache")                         // клиент может хранить ответ, но должен проверять его по ETag
+		w.Header().Add("Vary", "Accept-Language, Authorization, X-API-Key") // ответ зависит от языка и scope клиента
+		httpcache.Write(w, r, csD, collectedAt)                             // записываем данные в Response: ETag, 304, сжатие
+		return
 	}
 	w.WriteHeader(http.StatusBadRequest)
 }
 
-func (r *ResultSetT) getAndSortSMS() error { // функция фильтрации данных системы SMS
-	smsData, err := smsCountryRepo.GetSmsData(smsFileName) // получаем данные из системы SMS
+func (r *ResultSetT) getAndSortSMS(in inputs, loc countries.Locale, snap *scorecard.Snapshot) error { // функция фильтрации данных системы SMS
+	stats := providers.NewRowStats()
+	smsData, err := smsCountryRepo.GetSmsData(in.files, cfg.Sources.SMSFile, providerCatalog, stats) // получаем данные из системы SMS
 	if err != nil {
 		return err
 	} else {
-		var smsDSetCountry []SMSData // создаем слайс типа SMSData
-		for _, v := range smsData {  // проходим по слайсу данных системы SMS
-			v.Country = smsCountryRepo.CountryByCode[countries.Code(v.Country)].Name // в каждом элементе заменяем значение поля кода страны на полное название страны из хранилища SmsCountryRepository
-			smsDSetCountry = append(smsDSetCountry, SMSData(v))                      // добавляем в слайс smsDSetCountry каждый обновленный элемент слайса системы SMS, приводя его к типу SMSData
-		}
-		var providerSortedSlice = make([]SMSData, len(smsData)) // инициализируем слайс, для дальнейшей сортировки по имени провайдера
-		copy(providerSortedSlice, smsDSetCountry)               // копируем в него элементы из слайса типа SMSData, с полными именами городов
-		for i := 0; i < len(providerSortedSlice); i++ {         // для сортировки используем "сортировку выбора"
-			var minIdx = i
-			for j := i; j < len(providerSortedSlice); j++ { // для каждого i, ищем минимальный элемент в правой части
-				if providerSortedSlice[j].Provider < providerSortedSlice[minIdx].Provider {
-					minIdx = j
-				}
-			}
-			providerSortedSlice[i], providerSortedSlice[minIdx] = providerSortedSlice[minIdx], providerSortedSlice[i] // переставляем мин.элемент налево
-		}
-		var countrySortedSlice = make([]SMSData, len(smsData)) // инициализируем слайс, для дальнейшей сортировки по имени страны
-		copy(countrySortedSlice, smsDSetCountry)
-		for i := 0; i < len(countrySortedSlice); i++ {
-			var minIdx = i
-			for j := i; j < len(countrySortedSlice); j++ {
-				if countrySortedSlice[j].Country < countrySortedSlice[minIdx].Country {
-					minIdx = j
-				}
-			}
-			countrySortedSlice[i], countrySortedSlice[minIdx] = countrySortedSlice[minIdx], countrySortedSlice[i]
-		}
-		r.SMS = [][]SMSData{ // заполняем поле SMS структуры ResultSetT. Инициализируем двумерный слайс и присваиваем значения
-			providerSortedSlice,
-			countrySortedSlice,
+		snap.Add(providers.SMS, smsRows(smsData), stats) // метрики провайдеров для карточек
+		var smsDSetCountry []SMSData                     // создаем слайс типа SMSData
+		for _, v := range smsData {                      // проходим по слайсу данных системы SMS
+			v.Country = smsCountryRepo.NameIn(countries.Code(v.Country), loc) // в каждом элементе заменяем значение поля кода страны на полное название страны на языке клиента
+			smsDSetCountry = append(smsDSetCountry, SMSData(v))               // добавляем в слайс smsDSetCountry каждый обновленный элемент слайса системы SMS, приводя его к типу SMSData
 		}
+		r.SMS = byProviderAndCountry(smsDSetCountry, smsKeys(loc.Collator()), nil) // заполняем поле SMS структуры ResultSetT: списки по провайдеру и по стране (названия стран сравниваем по правилам языка клиента)
 		// fmt.Println("SMS system data:")
 		// fmt.Println(r.SMS)
 		return nil
 	}
 }
 
-func (r *ResultSetT) getAndSortMMS() error { // функция фильтрации данных системы MMS
-	mmsData, statusCode, err := mmsCountryRepo.GetMmsData(mmsUrlAddr) // получаем данные из системы MMS
-	if statusCode == 200 && err == nil {
+func (r *ResultSetT) getAndSortMMS(in inputs, loc countries.Locale, snap *scorecard.Snapshot) error { // функция фильтрации данных системы MMS
+	stats := providers.NewRowStats()
+	res, err := mmsCountryRepo.GetMmsData(in.api, cfg.Sources.MMSURL, providerCatalog, stats) // получаем данные из системы MMS
+	if err == nil {
+		mmsData := res.Items
+		r.noteSource("mms", res.UnknownFields, res.Stale)
+		snap.Add(providers.MMS, mmsRows(mmsData), stats)   // метрики провайдеров для карточек
 		var mmsDSetCountry = make([]MMSData, len(mmsData)) // создаем слайс типа MMSData
 		for i, v := range mmsData {                        // проходим по слайсу данных системы MMS
-			v.Country = mmsCountryRepo.CountryByCode[countries.Code(v.Country)].Name // в каждом элементе заменяем значение поля кода страны на полное название страны из хранилища MmsCountryRepository
-			mmsDSetCountry[i] = MMSData(v)                                           // добавляем в слайс mmsDSetCountry каждый обновленный элемент слайса системы MMS, приводя его к типу MMSData
-		}
-		var providerSortedSlice = make([]MMSData, len(mmsData)) // инициализируем слайс, для дальнейшей сортировки по имени провайдера
-		copy(providerSortedSlice, mmsDSetCountry)               // копируем в него элементы из слайса типа MMSData, с полными именами городов
-		for i := 0; i < len(providerSortedSlice); i++ {         // для сортировки используем "сортировку выбора"
-			var minIdx = i
-			for j := i; j < len(providerSortedSlice); j++ {
-				if providerSortedSlice[j].Provider < providerSortedSlice[minIdx].Provider {
-					minIdx = j
-				}
-			}
-			providerSortedSlice[i], providerSortedSlice[minIdx] = providerSortedSlice[minIdx], providerSortedSlice[i]
-		}
-		var countrySortedSlice = make([]MMSData, len(mmsData)) // инициализируем слайс, для дальнейшей сортировки по имени страны
-		copy(countrySortedSlice, mmsDSetCountry)
-		for i := 0; i < len(countrySortedSlice); i++ {
-			var minIdx = i
-			for j := i; j < len(countrySortedSlice); j++ {
-				if countrySortedSlice[j].Country < countrySortedSlice[minIdx].Country {
-					minIdx = j
-				}
-			}
-			countrySortedSlice[i], countrySortedSlice[minIdx] = countrySortedSlice[minIdx], countrySortedSlice[i]
-		}
-		r.MMS = [][]MMSData{ // заполняем поле MMS структуры ResultSetT. Инициализируем двумерный слайс и присваиваем значения
-			providerSortedSlice,
-			countrySortedSlice,
+			v.Country = mmsCountryRepo.NameIn(countries.Code(v.Country), loc) // в каждом элементе заменяем значение поля кода страны на полное название страны на языке клиента
+			mmsDSetCountry[i] = MMSData(v)                                    // добавляем в слайс mmsDSetCountry каждый обновленный элемент слайса системы MMS, приводя его к типу MMSData
 		}
+		r.MMS = byProviderAndCountry(mmsDSetCountry, mmsKeys(loc.Collator()), nil) // заполняем поле MMS структуры ResultSetT: списки по провайдеру и по стране
 		// fmt.Println("MMS system data:")
 		// fmt.Println(r.MMS)
 		return nil
-	} else if err != nil {
-		return err
-	} else {
+	} else if statusCode := upstream.StatusCode(err); statusCode != 0 { // источник ответил не кодом 200: система остается пустой, сбор продолжается
 		fmt.Printf("Error receiving data about MMS system: StatusCode %v\n", statusCode)
 		return nil
+	} else {
+		return err
 	}
 }
 
-func (r *ResultSetT) getAndSortVoice() error { // функция фильтрации данных системы Voicecall
-	voiceCallData, err := voiceCountryRepo.GetVoiceData(voiceFileName) // получаем данные из системы VoiceCall
+func (r *ResultSetT) getAndSortVoice(in inputs, snap *scorecard.Snapshot) error { // функция фильтрации данных системы Voicecall
+	stats := providers.NewRowStats()
+	voiceCallData, err := voiceCountryRepo.GetVoiceData(in.files, cfg.Sources.VoiceFile, providerCatalog, stats) // получаем данные из системы VoiceCall
 	if err != nil {
 		return err
 	} else {
-		var vcData []VoiceCallData // создаем слайс типа VoiceCallData
+		snap.Add(providers.Voice, voiceRows(voiceCallData), stats) // метрики провайдеров для карточек
+		cfg.VoiceQuality.Apply(voiceCallData)                      // считаем оценку качества для каждой строки
+		var vcData []VoiceCallData                                 // создаем слайс типа VoiceCallData
 		for _, v := range voiceCallData {
 			vcData = append(vcData, VoiceCallData(v))
 		}
-		r.VoiceCall = vcData // заполняем поле VoiceCall структуры ResultSetT. Данные из системы никак не модифицируются, просто передаем их в поле.
+		r.VoiceCall = vcData // заполняем поле VoiceCall структуры ResultSetT. Исходные поля не модифицируются, добавляется только оценка качества.
+		r.VoiceQuality = VoiceQualityT{
+			Threshold:  cfg.VoiceQuality.Threshold,
+			ByProvider: voicecall.RankBy(voiceCallData, func(v voicecall.VoiceData) string { return v.Provider }),
+			ByCountry:  voicecall.RankBy(voiceCallData, func(v voicecall.VoiceData) string { return v.Country }),
+		}
+		for _, v := range voiceCallData {
+			if v.LowQuality {
+				r.VoiceQuality.LowQuality++
+			}
+		}
 		// fmt.Println("VoiceCall system data:")
 		// fmt.Println(r.VoiceCall)
 		return nil
 	}
 }
 
-func (r *ResultSetT) getAndSortEmail() error { // функция фильтрации данных системы Email
-	emailData, err := emailCountryRepo.GetEmailData(emailFileName) // получаем данные из системы Email
+func (r *ResultSetT) getAndSortEmail(in inputs, snap *scorecard.Snapshot) error { // функция фильтрации данных системы Email
+	stats := providers.NewRowStats()
+	emailData, err := emailCountryRepo.GetEmailData(in.files, cfg.Sources.EmailFile, providerCatalog, stats) // получаем данные из системы Email
 	if err != nil {
 		return err
 	} else {
-		r.Email = make(map[string][][]EmailData) // инициализируем мапу для поля Email структуры ResultSetT
-		var emData []EmailData                   // создаем слайс типа EmailData
-		for _, v := range emailData {            // проходим по слайсу данных системы Email
+		snap.Add(providers.Email, emailRows(emailData), stats) // метрики провайдеров для карточек
+		r.Email = make(map[string][][]EmailData)               // инициализируем мапу для поля Email структуры ResultSetT
+		var emData []EmailData                                 // создаем слайс типа EmailData
+		for _, v := range emailData {                          // проходим по слайсу данных системы Email
 			emData = append(emData, EmailData(v)) // добавляем в слайс vcData каждый элемент слайса системы Email, приводя его к типу EmailData
 		}
 		var emailMapByCountry = make(map[string][]EmailData) // создаем мапу c значениями типа слайса EmailData
@@ -229,18 +489,14 @@ func (r *ResultSetT) getAndSortEmail() error { // функция фильтра
 			emailMapByCountry[v.Country] = append(emailMapByCountry[v.Country], v) // добавляем в мап по ключу == код alpha-2, каждый элемент слайса emData. Для каждого ключа получаем слайс EmailData
 		}
 		for i, v := range emailMapByCountry { // Для сортировки итерируемся по ключам мапы (по слайсу с значениями для одной страны)
-			for i := 0; i < len(v); i++ {
-				var minIdx = i
-				for j := i; j < len(v); j++ {
-					if v[j].DeliveryTime < v[minIdx].DeliveryTime { // сортируем по времени доставки
-						minIdx = j
-					}
-				}
-				v[i], v[minIdx] = v[minIdx], v[i]
+			sorting.Stable(v, emailKeys...) // сортируем по времени доставки, при равенстве - по провайдеру
+			n := 3                          // в стране может быть меньше трех провайдеров
+			if len(v) < n {
+				n = len(v)
 			}
 			r.Email[i] = [][]EmailData{ // для каждой итерации(ключа мапы)
-				v[1:4],       // оставляем 3 элемента с самыми быстрыми провайдерами
-				v[len(v)-3:], // оставляем 3 элемента с самыми медленными провайдерами
+				v[:n],        // оставляем 3 элемента с самыми быстрыми провайдерами
+				v[len(v)-n:], // оставляем 3 элемента с самыми медленными провайдерами
 			}
 		}
 		//fmt.Println("email system data:")
@@ -249,8 +505,8 @@ func (r *ResultSetT) getAndSortEmail() error { // функция фильтра
 	}
 }
 
-func (r *ResultSetT) getAndSortBilling() error { // функция фильтрации данных системы Billing
-	billingData, err := billing.GetBillingData(billingFileName)
+func (r *ResultSetT) getAndSortBilling(in inputs) error { // функция фильтрации данных системы Billing
+	billingData, err := billing.GetBillingData(in.files, cfg.Sources.BillingFile)
 	if err != nil {
 		return err
 	} else {
@@ -261,9 +517,11 @@ func (r *ResultSetT) getAndSortBilling() error { // функция фильтр
 	}
 }
 
-func (r *ResultSetT) getAndSortSupport() error { // функция фильтрации данных системы Support
-	supportData, statusCode, err := support.GetSupportData(supportUrlAddr)
-	if statusCode == 200 && err == nil {
+func (r *ResultSetT) getAndSortSupport(in inputs) error { // функция фильтрации данных системы Support
+	res, err := support.GetSupportData(in.api, cfg.Sources.SupportURL)
+	if err == nil {
+		supportData := res.Items
+		r.noteSource("support", res.UnknownFields, res.Stale)
 		r.Support = make([]int, 0) // инициализируем слайс для поля Support структуры ResultSetT
 		var totalActiveTickets int
 		for _, v := range supportData {
@@ -283,84 +541,110 @@ func (r *ResultSetT) getAndSortSupport() error { // функция фильтр
 		// fmt.Println("Support system data:")
 		// fmt.Println(r.Support)
 		return nil
-	} else if err != nil {
-		return err
-	} else {
+	} else if statusCode := upstream.StatusCode(err); statusCode != 0 {
 		fmt.Printf("Error receiving data about support system: StatusCode %v\n", statusCode)
 		return nil
+	} else {
+		return err
 	}
 }
 
-func (r *ResultSetT) getAndSortIncident() error { // функция фильтрации данных системы Incident
-	incidentData, statusCode, err := incident.GetIncidentData(incidentUrlAddr)
-	if statusCode == 200 && err == nil {
+func (r *ResultSetT) getAndSortIncident(in inputs) error { // функция фильтрации данных системы Incident
+	res, err := incident.GetIncidentData(in.api, cfg.Sources.IncidentURL)
+	if err == nil {
+		incidentData := res.Items
+		r.noteSource("incident", res.UnknownFields, res.Stale)
+		incidentRules.Apply(incidentData, func(code string) bool { // заполняем систему, серьезность и страны по теме инцидента
+			_, ok := countryRepo.Lookup(countries.Code(code))
+			return ok
+		})
 		var incData []IncidentData // создаем слайс типа IncidentData
 		for _, v := range incidentData {
 			incData = append(incData, IncidentData(v))
 		}
-		for i := 1; i < len(incData); i++ { // Сортируем по статусу. "Active" д.б вначале списка. Используем сортировку "вставками".
-			x := incData[i] // значение, для которого будем искать место.
-			j := i
-			for j = i; j > 0; j-- { // запускаем цикл, идём влево от i по сортированной части
-				if incData[j-1].Status > x.Status { // проверяем нужно ли передвигать элементы
-					incData[j] = incData[j-1] // передвигаем элементы вверх
-				} else {
-					break // прерываем цикл, индекс j для вставки x найден
-				}
-			}
-			incData[j] = x // вставляем "x" в нужный элемент с индеком j
-		}
+		sorting.Stable(incData, incidentKeys[0]) // Сортируем по статусу. "Active" д.б вначале списка, остальные в порядке источника.
 		r.Incidents = incData
+		if notifier != nil && replayArchive == nil { // письма подписчикам об открытых и закрытых с прошлого сбора инцидентах
+			now := time.Now()
+			notifier.Incidents(incidentData, func(inc incident.IncidentData) bool { return incidentInMaintenance(inc, now) }) // инциденты, приходящиеся на плановые работы, исключены из оповещений
+		}
 		// fmt.Println("Incident system data:")
 		// fmt.Println(r.Incidents)
 		return nil
-	} else if err != nil {
-		return err
-	} else {
+	} else if statusCode := upstream.StatusCode(err); statusCode != 0 {
 		fmt.Printf("Error receiving data about incident system: StatusCode %v\n", statusCode)
 		return nil
+	} else {
+		return err
+	}
+}
+
+func (r *ResultSetT) noteSource(system string, fields []string, stale bool) { // запоминает незнакомые поля ответа источника (при strict_decoding: false) и устаревшие данные
+	if stale {
+		r.Stale = append(r.Stale, system)
+	}
+	if len(fields) == 0 {
+		return
 	}
+	if r.UnknownFields == nil {
+		r.UnknownFields = make(map[string][]string)
+	}
+	r.UnknownFields[system] = fields
 }
 
-func getResultData() (ResultSetT, error) { // функция получения родительской структуры ResultSetT с отфильтрованными данными всех систем
-	var rSetT ResultSetT // создаем структуру типа ResultSetT
-	if err := rSetT.getAndSortSMS(); err != nil {
+func getResultData(in inputs, loc countries.Locale) (ResultSetT, error) { // функция получения родительской структуры ResultSetT с отфильтрованными данными всех систем
+	var rSetT ResultSetT                                // создаем структуру типа ResultSetT
+	snap := &scorecard.Snapshot{Time: time.Now().UTC()} // снимок метрик провайдеров для карточек
+	if err := rSetT.getAndSortSMS(in, loc, snap); err != nil {
 		fmt.Printf("Error receiving data about SMS system: %v\n", err)
 		return rSetT, err
 	}
-	if err := rSetT.getAndSortMMS(); err != nil {
+	if err := rSetT.getAndSortMMS(in, loc, snap); err != nil {
 		fmt.Printf("Error receiving data about MMS system: %v\n", err)
 		return rSetT, err
 	}
-	if err := rSetT.getAndSortVoice(); err != nil {
+	if err := rSetT.getAndSortVoice(in, snap); err != nil {
 		fmt.Printf("Error receiving data about voiceCall system: %v\n", err)
 		return rSetT, err
 	}
-	if err := rSetT.getAndSortEmail(); err != nil {
+	if err := rSetT.getAndSortEmail(in, snap); err != nil {
 		fmt.Printf("Error receiving data about Email system: %v\n", err)
 		return rSetT, err
 	}
-	if err := rSetT.getAndSortBilling(); err != nil {
+	if err := rSetT.getAndSortBilling(in); err != nil {
 		fmt.Printf("Error receiving data about billing system: %v\n", err)
 		return rSetT, err
 	}
-	if err := rSetT.getAndSortSupport(); err != nil {
+	if err := rSetT.getAndSortSupport(in); err != nil {
 		fmt.Printf("Error receiving data about support system: %v\n", err)
 		return rSetT, err
 	}
-	if err := rSetT.getAndSortIncident(); err != nil {
+	if err := rSetT.getAndSortIncident(in); err != nil {
 		fmt.Printf("Error receiving data about incident system: %v\n", err)
 		return rSetT, err
 	}
+	rSetT.Overall = statusRules.Evaluate(statusVars(rSetT)) // общее состояние по правилам
+	setCoverage(snap)                                       // страны провайдеров для /providers
+	if replayArchive == nil {                               // воспроизведение архива не попадает в историю
+		historyStore.SetLast(*snap) // снимок только успешного сбора; в историю на диске его записывает фоновый сбор
+	}
 	return rSetT, nil
 }
 
-func getResultT() ResultT { // функция получения конечной родительской структуры ResultT
-	rSetT, err := getResultData() // вызываем функцию получения структуры ResultSetT
-	if err != nil {
-		rT := ResultT{false, rSetT, err.Error()}
-		return rT
+func getResultT(loc countries.Locale) ResultT { // функция получения конечной родительской структуры ResultT
+	in := inputs{files: dataFiles, api: apiSource}
+	var rec *archive.Recorder
+	if cfg.Archive.Enabled && replayArchive == nil { // запоминаем все прочитанные данные
+		rec = archive.NewRecorder(in.files, in.api.Client)
+		in.files, in.api.Client = rec, rec
 	}
+	rSetT, err := getResultData(in, loc) // вызываем функцию получения структуры ResultSetT
 	rT := ResultT{true, rSetT, ""}
+	if err != nil {
+		rT = ResultT{false, rSetT, err.Error()}
+	}
+	if rec != nil {
+		saveArchive(rec, loc, rT)
+	}
 	return rT
 }
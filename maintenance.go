@@ -0,0 +1,448 @@
+package main
+
+// Административный API плановых работ и ручных состояний (только scope internal).
+// Системы на плановых работах получают состояние under_maintenance и не влияют на общее состояние, по которому срабатывают оповещения.
+
+import (
+	"encoding/json"
+	"errors"
+	"finalwork/internal/countries"
+	"finalwork/internal/incident"
+	"finalwork/internal/maintenance"
+	"finalwork/internal/notify"
+	"finalwork/internal/providers"
+	"finalwork/internal/rules"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+var channelSystems = map[providers.Channel]string{ // канал провайдера -> система в ответе
+	providers.SMS:   "sms",
+	providers.MMS:   "mms",
+	providers.Voice: "voice_call",
+	providers.Email: "email",
+}
+
+// windowSystems возвращает системы, которых касается окно: указанные явно, иначе каналы провайдеров, иначе (только страны) все системы.
+// По этому списку выбираются получатели объявления о работах и проверяются системы инцидентов окна по провайдерам
+func windowSystems(w maintenance.Window) []string {
+	if len(w.Systems) > 0 {
+		return w.Systems
+	}
+	if len(w.Providers) == 0 {
+		return incident.Systems
+	}
+	var list []string
+	seen := make(map[string]bool)
+	for _, name := range w.Providers {
+		p, _ := providerCatalog.Lookup(name)
+		for _, ch := range p.Channels {
+			if s := channelSystems[ch]; !seen[s] {
+				seen[s] = true
+				list = append(list, s)
+			}
+		}
+	}
+	return list
+}
+
+func maintenanceSystems(w maintenance.Window) []string { // системы, получающие состояние under_maintenance целиком (окно по провайдерам или странам - только если в него попадут все строки системы)
+	if w.SystemWide() {
+		return w.Systems
+	}
+	return []string{}
+}
+
+// incidentCovered сообщает, приходится ли инцидент на окно работ: система инцидента входит в системы окна
+// (для окна по провайдерам - в их каналы), провайдер окна упоминается в теме, все страны инцидента входят в страны окна
+func incidentCovered(inc incident.IncidentData, w maintenance.Window) bool {
+	if len(w.Systems) > 0 && !contains(w.Systems, inc.System) {
+		return false
+	}
+	if len(w.Providers) > 0 {
+		if len(w.Systems) == 0 && inc.System != "" && !contains(windowSystems(w), inc.System) {
+			return false
+		}
+		mentioned := false
+		for _, name := range w.Providers {
+			p, _ := providerCatalog.Lookup(name)
+			for _, n := range append([]string{name}, p.Aliases...) {
+				if regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(n) + `\b`).MatchString(inc.Topic) {
+					mentioned = true
+				}
+			}
+		}
+		if !mentioned {
+			return false
+		}
+	}
+	if len(w.Countries) > 0 {
+		if len(inc.Countries) == 0 { // инцидент во всех странах шире окна
+			return false
+		}
+		for _, c := range inc.Countries {
+			if !contains(w.Countries, c) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func incidentInMaintenance(inc incident.IncidentData, now time.Time) bool { // инцидент приходится на текущие плановые работы
+	for _, w := range maintenanceStore.Windows(now) {
+		if w.Active(now) && incidentCovered(inc, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyMaintenance накладывает на общее состояние текущие плановые работы и ручные состояния.
+// Окно по системам переводит их в under_maintenance. Окно по провайдерам или странам отмечает попавшие в него строки данных,
+// а правила состояния пересчитываются без этих строк и без приходящихся на окна инцидентов; система, все строки которой
+// попали в окна, получает under_maintenance
+func applyMaintenance(rT ResultT, loc countries.Locale, now time.Time) ResultT {
+	if !rT.Status {
+		return rT
+	}
+	var inMaintenance, overrides []rules.Reason
+	var rowWindows []maintenance.Window
+	for _, w := range maintenanceStore.Windows(now) {
+		if !w.Active(now) {
+			continue
+		}
+		if !w.SystemWide() {
+			rowWindows = append(rowWindows, w)
+			continue
+		}
+		for _, s := range w.Systems {
+			inMaintenance = append(inMaintenance, rules.Reason{Component: s, Message: w.Message})
+		}
+	}
+	if len(rowWindows) > 0 {
+		m := newRowMatcher(rowWindows, loc)
+		rT.Data.Maintenance = m.rows(rT.Data)
+		d, covered := m.exclude(rT.Data)
+		for _, s := range incident.Systems {
+			if message, ok := covered[s]; ok {
+				inMaintenance = append(inMaintenance, rules.Reason{Component: s, Message: message})
+			}
+		}
+		rT.Data.Overall = statusRules.Evaluate(statusVars(d))
+	}
+	for _, o := range maintenanceStore.Overrides(now) {
+		overrides = append(overrides, rules.Reason{Component: o.Component, State: o.State, Message: o.Message})
+	}
+	rT.Data.Overall = rT.Data.Overall.Apply(inMaintenance, overrides)
+	return rT
+}
+
+type rowMatcher struct { // строки данных, на которые приходятся окна по провайдерам или странам
+	windows []maintenance.Window
+	codes   map[string]string // название страны на языке клиента -> код (в строках sms и mms стоят названия)
+}
+
+func newRowMatcher(windows []maintenance.Window, loc countries.Locale) rowMatcher {
+	m := rowMatcher{windows: windows, codes: make(map[string]string)}
+	for _, w := range windows {
+		for _, code := range w.Countries {
+			m.codes[countryRepo.NameIn(countries.Code(code), loc)] = code
+		}
+	}
+	return m
+}
+
+func (m rowMatcher) covering(system, provider, country string) []maintenance.Window { // окна, в которые попадает строка
+	if c, ok := m.codes[country]; ok {
+		country = c
+	}
+	var list []maintenance.Window
+	for _, w := range m.windows {
+		if w.Covers(system, provider, country) {
+			list = append(list, w)
+		}
+	}
+	return list
+}
+
+func (m rowMatcher) covered(system, provider, country string) bool {
+	return len(m.covering(system, provider, country)) > 0
+}
+
+// rows возвращает пары провайдер-страна из собранных данных, на которые приходятся окна
+func (m rowMatcher) rows(d ResultSetT) []MaintenanceRowT {
+	var list []MaintenanceRowT
+	seen := make(map[MaintenanceRowT]bool)
+	add := func(system, provider, country string) {
+		for _, w := range m.covering(system, provider, country) {
+			row := MaintenanceRowT{System: system, Provider: provider, Country: country, WindowID: w.ID}
+			if !seen[row] {
+				seen[row] = true
+				list = append(list, row)
+			}
+		}
+	}
+	if len(d.SMS) > 0 {
+		for _, v := range d.SMS[0] {
+			add("sms", v.Provider, v.Country)
+		}
+	}
+	if len(d.MMS) > 0 {
+		for _, v := range d.MMS[0] {
+			add("mms", v.Provider, v.Country)
+		}
+	}
+	for _, v := range d.VoiceCall {
+		add("voice_call", v.Provider, v.Country)
+	}
+	emailCountries := make([]string, 0, len(d.Email))
+	for country := range d.Email {
+		emailCountries = append(emailCountries, country)
+	}
+	sort.Strings(emailCountries) // порядок отметок не зависит от обхода мапы
+	for _, country := range emailCountries {
+		if rows := d.Email[country]; len(rows) > 0 {
+			for _, v := range rows[0] {
+				add("email", v.Provider, v.Country)
+			}
+		}
+	}
+	return list
+}
+
+// exclude возвращает копию данных без строк и инцидентов, на которые приходятся окна, для правил состояния,
+// и системы, у которых в окна попали все строки (с сообщением окна)
+func (m rowMatcher) exclude(d ResultSetT) (ResultSetT, map[string]string) {
+	covered := make(map[string]string)
+	note := func(system string, had, left int, provider, country string) {
+		if had > 0 && left == 0 {
+			covered[system] = m.covering(system, provider, country)[0].Message
+		}
+	}
+	sms := make([][]SMSData, len(d.SMS)) // списки отсортированы по-разному, но состоят из одних строк
+	for i, rows := range d.SMS {
+		for _, v := range rows {
+			if !m.covered("sms", v.Provider, v.Country) {
+				sms[i] = append(sms[i], v)
+			}
+		}
+	}
+	if len(d.SMS) > 0 && len(d.SMS[0]) > 0 {
+		note("sms", len(d.SMS[0]), len(sms[0]), d.SMS[0][0].Provider, d.SMS[0][0].Country)
+	}
+	d.SMS = sms
+	mms := make([][]MMSData, len(d.MMS))
+	for i, rows := range d.MMS {
+		for _, v := range rows {
+			if !m.covered("mms", v.Provider, v.Country) {
+				mms[i] = append(mms[i], v)
+			}
+		}
+	}
+	if len(d.MMS) > 0 && len(d.MMS[0]) > 0 {
+		note("mms", len(d.MMS[0]), len(mms[0]), d.MMS[0][0].Provider, d.MMS[0][0].Country)
+	}
+	d.MMS = mms
+	voice := make([]VoiceCallData, 0, len(d.VoiceCall))
+	d.VoiceQuality.LowQuality = 0
+	for _, v := range d.VoiceCall {
+		if m.covered("voice_call", v.Provider, v.Country) {
+			continue
+		}
+		voice = append(voice, v)
+		if v.LowQuality {
+			d.VoiceQuality.LowQuality++
+		}
+	}
+	if len(d.VoiceCall) > 0 {
+		note("voice_call", len(d.VoiceCall), len(voice), d.VoiceCall[0].Provider, d.VoiceCall[0].Country)
+	}
+	d.VoiceCall = voice
+	email := make(map[string][][]EmailData, len(d.Email))
+	var lastEmail EmailData
+	for country, lists := range d.Email {
+		keep := false
+		for _, rows := range lists {
+			for _, v := range rows {
+				if !m.covered("email", v.Provider, v.Country) {
+					keep = true
+				} else {
+					lastEmail = v
+				}
+			}
+		}
+		if keep { // страна остается в данных, пока в ней есть провайдер вне окон
+			email[country] = lists
+		}
+	}
+	note("email", len(d.Email), len(email), lastEmail.Provider, lastEmail.Country)
+	d.Email = email
+	incidents := make([]IncidentData, 0, len(d.Incidents))
+	for _, inc := range d.Incidents {
+		if !m.incidentCovered(inc) {
+			incidents = append(incidents, inc)
+		}
+	}
+	d.Incidents = incidents
+	return d, covered
+}
+
+func (m rowMatcher) incidentCovered(inc IncidentData) bool {
+	for _, w := range m.windows {
+		if incidentCovered(incident.IncidentData(inc), w) {
+			return true
+		}
+	}
+	return false
+}
+
+func validSystem(s string) bool {
+	return contains(incident.Systems, s)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func validateWindow(w *maintenance.Window) error { // проверка области окна; провайдеры приводятся к каноническим именам, страны - к верхнему регистру
+	if len(w.Systems)+len(w.Providers)+len(w.Countries) == 0 {
+		return fmt.Errorf("at least one of systems, providers or countries is required")
+	}
+	if strings.TrimSpace(w.Message) == "" {
+		return fmt.Errorf("message is required")
+	}
+	for _, s := range w.Systems {
+		if !validSystem(s) {
+			return fmt.Errorf("unknown system %q (allowed: %s)", s, strings.Join(incident.Systems, ", "))
+		}
+	}
+	for i, name := range w.Providers {
+		p, ok := providerCatalog.Lookup(name)
+		if !ok {
+			return fmt.Errorf("unknown provider %q", name)
+		}
+		w.Providers[i] = p.Name
+	}
+	for i, code := range w.Countries {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if _, ok := countryRepo.Lookup(countries.Code(code)); !ok {
+			return fmt.Errorf("unknown country %q", code)
+		}
+		w.Countries[i] = code
+	}
+	return nil
+}
+
+func validateOverride(o *maintenance.Override) error {
+	if o.Component != "" && !validSystem(o.Component) {
+		return fmt.Errorf("unknown component %q (allowed: %s or empty for the overall state)", o.Component, strings.Join(incident.Systems, ", "))
+	}
+	if rules.Rank(o.State) < 0 {
+		return fmt.Errorf("unknown state %q (allowed: operational, degraded, partial_outage, major_outage)", o.State)
+	}
+	if strings.TrimSpace(o.Message) == "" {
+		return fmt.Errorf("message is required")
+	}
+	return nil
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) error { // тело запроса администратора: неизвестные поля - ошибка
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+func getMaintenanceWindows(w http.ResponseWriter, r *http.Request) { // текущие и запланированные окна работ
+	now := time.Now()
+	type windowInfo struct {
+		maintenance.Window
+		Active          bool     `json:"active"`           // работы идут сейчас
+		AffectedSystems []string `json:"affected_systems"` // системы, которые получат состояние under_maintenance (только окна по системам)
+	}
+	list := make([]windowInfo, 0)
+	for _, v := range maintenanceStore.Windows(now) {
+		list = append(list, windowInfo{Window: v, Active: v.Active(now), AffectedSystems: maintenanceSystems(v)})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"windows": list})
+}
+
+func addMaintenanceWindow(w http.ResponseWriter, r *http.Request) { // новое окно работ
+	var v maintenance.Window
+	if err := decodeBody(w, r, &v); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := validateWindow(&v); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	v, err := maintenanceStore.AddWindow(v, time.Now())
+	if err != nil {
+		writeJSON(w, maintenanceStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+	if notifier != nil { // объявление подписчикам затронутых систем
+		notifier.Maintenance(notify.Announcement{Systems: windowSystems(v), Start: v.Start, End: v.End, Message: v.Message})
+	}
+	writeJSON(w, http.StatusCreated, v)
+}
+
+func deleteMaintenanceWindow(w http.ResponseWriter, r *http.Request) { // отмена или досрочное завершение работ
+	if err := maintenanceStore.DeleteWindow(mux.Vars(r)["id"], time.Now()); err != nil {
+		writeJSON(w, maintenanceStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func getOverrides(w http.ResponseWriter, r *http.Request) { // действующие ручные состояния
+	writeJSON(w, http.StatusOK, map[string]interface{}{"overrides": maintenanceStore.Overrides(time.Now())})
+}
+
+func addOverride(w http.ResponseWriter, r *http.Request) { // новое ручное состояние
+	var v maintenance.Override
+	if err := decodeBody(w, r, &v); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := validateOverride(&v); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	v, err := maintenanceStore.AddOverride(v, time.Now())
+	if err != nil {
+		writeJSON(w, maintenanceStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusCreated, v)
+}
+
+func deleteOverride(w http.ResponseWriter, r *http.Request) {
+	if err := maintenanceStore.DeleteOverride(mux.Vars(r)["id"], time.Now()); err != nil {
+		writeJSON(w, maintenanceStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func maintenanceStatus(err error) int { // код ответа для ошибки хранилища
+	switch {
+	case errors.Is(err, maintenance.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, maintenance.ErrInvalid):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError // не удалось сохранить файл
+}
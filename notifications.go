@@ -0,0 +1,149 @@
+package main
+
+// Подписка на письма об инцидентах и плановых работах. Подписка и отписка доступны без scope internal:
+// подписка вступает в силу только после подтверждения по ссылке из письма, отписка - по ссылке из любого письма.
+
+import (
+	"errors"
+	"finalwork/internal/notify"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type subscriberInfo struct { // подписчик без токенов
+	Email      string    `json:"email"`
+	Components []string  `json:"components"` // пусто - все системы
+	Confirmed  bool      `json:"confirmed"`
+	Pending    []string  `json:"pending,omitempty"` // системы из неподтвержденного запроса
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func notificationsEnabled(w http.ResponseWriter) bool {
+	if notifier == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "notifications are disabled"})
+		return false
+	}
+	return true
+}
+
+func subscribe(w http.ResponseWriter, r *http.Request) { // запрос подписки: письмо со ссылкой подтверждения
+	if !notificationsEnabled(w) {
+		return
+	}
+	var v struct {
+		Email      string   `json:"email"`
+		Components []string `json:"components"` // пусто - все системы
+	}
+	if err := decodeBody(w, r, &v); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := notifier.Subscribe(v.Email, v.Components); err != nil {
+		writeJSON(w, notifyStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "confirmation sent"})
+}
+
+// Страница по ссылке из письма: действие выполняется только кнопкой (POST), чтобы его не выполнили
+// сканеры ссылок и предзагрузка почтовых клиентов
+var actionPage = template.Must(template.New("action").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="robots" content="noindex"><title>{{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+{{if .Token}}<form method="post" action="{{.Action}}">
+<input type="hidden" name="token" value="{{.Token}}">
+<button type="submit">{{.Button}}</button>
+</form>{{else}}<p>{{.Text}}</p>{{end}}
+</body>
+</html>
+`))
+
+type actionPageData struct {
+	Title, Text, Action, Token, Button string
+}
+
+func writePage(w http.ResponseWriter, status int, data actionPageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	actionPage.Execute(w, data)
+}
+
+func wantsHTML(r *http.Request) bool { // форма со страницы, а не API-клиент или отписка одним щелчком из почтового клиента
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+func confirmSubscription(w http.ResponseWriter, r *http.Request) { // GET - страница с кнопкой, POST - подтверждение
+	if !notificationsEnabled(w) {
+		return
+	}
+	if r.Method == http.MethodGet {
+		writePage(w, http.StatusOK, actionPageData{Title: "Confirm your subscription", Action: r.URL.Path, Token: r.URL.Query().Get("token"), Button: "Confirm"})
+		return
+	}
+	s, err := notifier.Confirm(r.FormValue("token"))
+	switch {
+	case err != nil && wantsHTML(r):
+		writePage(w, notifyStatus(err), actionPageData{Title: "Subscription not confirmed", Text: "The link is invalid or has already been used."})
+	case err != nil:
+		writeJSON(w, notifyStatus(err), map[string]string{"error": err.Error()})
+	case wantsHTML(r):
+		writePage(w, http.StatusOK, actionPageData{Title: "Subscription confirmed", Text: "You will receive status updates at " + s.Email + "."})
+	default:
+		writeJSON(w, http.StatusOK, publicSubscriber(s))
+	}
+}
+
+func unsubscribe(w http.ResponseWriter, r *http.Request) { // GET - страница с кнопкой, POST - отписка (в том числе одним щелчком по RFC 8058)
+	if !notificationsEnabled(w) {
+		return
+	}
+	if r.Method == http.MethodGet {
+		writePage(w, http.StatusOK, actionPageData{Title: "Unsubscribe from status updates", Action: r.URL.Path, Token: r.URL.Query().Get("token"), Button: "Unsubscribe"})
+		return
+	}
+	s, err := notifier.Unsubscribe(r.FormValue("token"))
+	switch {
+	case err != nil && wantsHTML(r):
+		writePage(w, notifyStatus(err), actionPageData{Title: "Not unsubscribed", Text: "The link is invalid or has already been used."})
+	case err != nil:
+		writeJSON(w, notifyStatus(err), map[string]string{"error": err.Error()})
+	case wantsHTML(r):
+		writePage(w, http.StatusOK, actionPageData{Title: "Unsubscribed", Text: s.Email + " will no longer receive status updates."})
+	default:
+		writeJSON(w, http.StatusOK, map[string]string{"status": "unsubscribed", "email": s.Email})
+	}
+}
+
+func getSubscribers(w http.ResponseWriter, r *http.Request) {
+	if !notificationsEnabled(w) {
+		return
+	}
+	list := make([]subscriberInfo, 0)
+	for _, s := range notifier.Subscribers() {
+		list = append(list, publicSubscriber(s))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"subscribers": list})
+}
+
+func publicSubscriber(s notify.Subscriber) subscriberInfo {
+	info := subscriberInfo{Email: s.Email, Components: s.Components, Confirmed: s.Confirmed, CreatedAt: s.CreatedAt}
+	if s.ConfirmToken != "" {
+		info.Pending = s.Requested
+	}
+	return info
+}
+
+func notifyStatus(err error) int { // код ответа для ошибки подписки
+	switch {
+	case errors.Is(err, notify.ErrUnknownToken):
+		return http.StatusNotFound
+	case errors.Is(err, notify.ErrInvalid):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"finalwork/internal/countries"
+	"finalwork/internal/providers"
+	"finalwork/internal/scorecard"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type ProviderInfo struct { // провайдер из каталога вместе со странами, в которых он сейчас присутствует в данных
+	providers.Provider
+	Countries map[providers.Channel][]string `json:"countries"` // канал -> коды alpha-2 стран
+}
+
+type ProvidersResponse struct {
+	Providers   []ProviderInfo `json:"providers"`
+	CollectedAt time.Time      `json:"collected_at"`     // время сбора, по которому определены страны
+	Errors      []string       `json:"errors,omitempty"` // ошибка сбора, если успешных сборов еще не было (страны не заполнены)
+}
+
+var (
+	coverageMu   sync.Mutex
+	lastCoverage *scorecard.Snapshot // снимок последнего успешного сбора: по нему определяются страны провайдеров
+)
+
+func setCoverage(snap *scorecard.Snapshot) { // функция запоминания снимка успешного сбора (в том числе при воспроизведении архива). nil - сборов не было
+	coverageMu.Lock()
+	lastCoverage = snap
+	coverageMu.Unlock()
+}
+
+func coverageSnapshot() (scorecard.Snapshot, bool) {
+	coverageMu.Lock()
+	defer coverageMu.Unlock()
+	if lastCoverage == nil {
+		return scorecard.Snapshot{}, false
+	}
+	return *lastCoverage, true
+}
+
+// collectProviderCountries возвращает, в каких странах и каналах встречается каждый провайдер, по последнему успешному сбору
+// и время этого сбора. Источники повторно не опрашиваются; сбор выполняется, только если успешных сборов еще не было
+func collectProviderCountries() (map[string]map[providers.Channel]map[string]struct{}, time.Time, []string) {
+	snap, ok := coverageSnapshot()
+	if !ok {
+		rT, _ := collectResultT(countries.DefaultLocale)
+		if snap, ok = coverageSnapshot(); !ok {
+			return nil, time.Time{}, []string{rT.Error}
+		}
+	}
+	coverage := make(map[string]map[providers.Channel]map[string]struct{}) // провайдер -> канал -> множество стран
+	for _, s := range snap.Samples {
+		if coverage[s.Provider] == nil {
+			coverage[s.Provider] = make(map[providers.Channel]map[string]struct{})
+		}
+		if coverage[s.Provider][s.Channel] == nil {
+			coverage[s.Provider][s.Channel] = make(map[string]struct{})
+		}
+		for _, country := range s.Countries {
+			coverage[s.Provider][s.Channel][country] = struct{}{}
+		}
+	}
+	return coverage, snap.Time, nil
+}
+
+func providerInfo(p providers.Provider, coverage map[providers.Channel]map[string]struct{}) ProviderInfo {
+	info := ProviderInfo{Provider: p, Countries: make(map[providers.Channel][]string)}
+	for _, ch := range p.Channels {
+		list := make([]string, 0, len(coverage[ch]))
+		for country := range coverage[ch] {
+			list = append(list, country)
+		}
+		sort.Strings(list)
+		info.Countries[ch] = list
+	}
+	return info
+}
+
+func getProviders(w http.ResponseWriter, r *http.Request) { // функция возвращающая каталог провайдеров с каналами и странами
+	coverage, at, errs := collectProviderCountries()
+	resp := ProvidersResponse{CollectedAt: at, Errors: errs}
+	for _, p := range providerCatalog.List() {
+		resp.Providers = append(resp.Providers, providerInfo(p, coverage[p.Name]))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func getProvider(w http.ResponseWriter, r *http.Request) { // функция возвращающая одного провайдера по имени или псевдониму
+	p, ok := providerCatalog.Lookup(mux.Vars(r)["name"])
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown provider"})
+		return
+	}
+	coverage, at, errs := collectProviderCountries()
+	writeJSON(w, http.StatusOK, ProvidersResponse{
+		Providers:   []ProviderInfo{providerInfo(p, coverage[p.Name])},
+		CollectedAt: at,
+		Errors:      errs,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) { // функция записи ответа в формате json
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(v)
+}
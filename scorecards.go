@@ -0,0 +1,146 @@
+package main
+
+import (
+	"finalwork/internal/countries"
+	"finalwork/internal/email"
+	"finalwork/internal/mms"
+	"finalwork/internal/scorecard"
+	"finalwork/internal/sms"
+	"finalwork/internal/voicecall"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type ScorecardsResponse struct {
+	From       time.Time             `json:"from"`
+	To         time.Time             `json:"to"`
+	Snapshots  int                   `json:"snapshots"` // сколько снимков вошло в расчет
+	Scorecards []scorecard.Scorecard `json:"scorecards"`
+}
+
+func smsRows(data []sms.SMSData) []scorecard.Row { // функции приведения строк систем к общим метрикам
+	rows := make([]scorecard.Row, 0, len(data))
+	for _, v := range data {
+		responseTime, err1 := strconv.Atoi(v.ResponseTime)
+		bandwidth, err2 := strconv.Atoi(v.Bandwidth)
+		if err1 != nil || err2 != nil { // в SMS и MMS числа хранятся строками и при разборе не проверяются: такие строки есть в ответе, но не в карточках
+			continue
+		}
+		rows = append(rows, scorecard.Row{Provider: v.Provider, Country: v.Country, ResponseTime: responseTime, Bandwidth: bandwidth, HasBandwidth: true})
+	}
+	return rows
+}
+
+func mmsRows(data []mms.MMSData) []scorecard.Row {
+	rows := make([]scorecard.Row, 0, len(data))
+	for _, v := range data {
+		responseTime, err1 := strconv.Atoi(v.ResponseTime)
+		bandwidth, err2 := strconv.Atoi(v.Bandwidth)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		rows = append(rows, scorecard.Row{Provider: v.Provider, Country: v.Country, ResponseTime: responseTime, Bandwidth: bandwidth, HasBandwidth: true})
+	}
+	return rows
+}
+
+func voiceRows(data []voicecall.VoiceData) []scorecard.Row {
+	rows := make([]scorecard.Row, 0, len(data))
+	for _, v := range data {
+		rows = append(rows, scorecard.Row{Provider: v.Provider, Country: v.Country, ResponseTime: v.ResponseTime})
+	}
+	return rows
+}
+
+func emailRows(data []email.EmailData) []scorecard.Row {
+	rows := make([]scorecard.Row, 0, len(data))
+	for _, v := range data {
+		rows = append(rows, scorecard.Row{Provider: v.Provider, Country: v.Country, ResponseTime: v.DeliveryTime})
+	}
+	return rows
+}
+
+func collectPeriodically(interval time.Duration) { // функция фонового сбора данных: снимок каждого успешного сбора записывается в историю
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if rT, _ := collectResultT(countries.DefaultLocale); !rT.Status {
+			continue
+		}
+		if err := historyStore.AppendLast(); err != nil {
+			fmt.Printf("Error saving scorecard snapshot: %v\n", err)
+		}
+	}
+}
+
+// scorecardPeriod определяет период отчета по параметрам month=YYYY-MM или from=YYYY-MM-DD&to=YYYY-MM-DD (to включительно)
+func scorecardPeriod(r *http.Request) (from, to time.Time, historical bool, err error) {
+	q := r.URL.Query()
+	if month := q.Get("month"); month != "" {
+		from, err = time.Parse("2006-01", month)
+		if err != nil {
+			return from, to, false, fmt.Errorf("month must be YYYY-MM")
+		}
+		return from, from.AddDate(0, 1, 0), true, nil
+	}
+	if q.Get("from") == "" && q.Get("to") == "" {
+		return from, to, false, nil
+	}
+	from, err = time.Parse("2006-01-02", q.Get("from"))
+	if err != nil {
+		return from, to, false, fmt.Errorf("from must be YYYY-MM-DD")
+	}
+	to, err = time.Parse("2006-01-02", q.Get("to"))
+	if err != nil {
+		return from, to, false, fmt.Errorf("to must be YYYY-MM-DD")
+	}
+	if to.Before(from) {
+		return from, to, false, fmt.Errorf("to is before from")
+	}
+	return from, to.AddDate(0, 0, 1), true, nil
+}
+
+func getScorecards(w http.ResponseWriter, r *http.Request) { // функция возвращающая карточки провайдеров
+	from, to, historical, err := scorecardPeriod(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	var snapshots []scorecard.Snapshot
+	if historical {
+		snapshots, err = historyStore.Load(from, to)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+	} else { // без периода - карточки по текущему (последнему) снимку
+		last, ok := historyStore.Last()
+		if !ok {
+			if rT, _ := collectResultT(countries.DefaultLocale); !rT.Status {
+				writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": rT.Error})
+				return
+			}
+			last, _ = historyStore.Last()
+		}
+		snapshots = []scorecard.Snapshot{last}
+		from, to = last.Time, last.Time
+	}
+	cards := scorecard.Build(snapshots)
+	if name := r.URL.Query().Get("provider"); name != "" { // фильтр по провайдеру (имя или псевдоним)
+		p, ok := providerCatalog.Lookup(name)
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown provider"})
+			return
+		}
+		filtered := cards[:0]
+		for _, c := range cards {
+			if c.Provider == p.Name {
+				filtered = append(filtered, c)
+			}
+		}
+		cards = filtered
+	}
+	writeJSON(w, http.StatusOK, ScorecardsResponse{From: from, To: to, Snapshots: len(snapshots), Scorecards: cards})
+}
@@ -0,0 +1,221 @@
+package simulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// pinned systems were set through the control API: they neither drift nor get corrupted until the next reset.
+var pinned = make(map[string]bool)
+
+func (b Billing) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.String())
+}
+
+// UnmarshalJSON accepts the mask as written to billing.data ("111110", checkout_page first)
+// or an object with flags, e.g. {"checkout_page": false}. Flags missing from the object keep their values.
+func (b *Billing) UnmarshalJSON(data []byte) error {
+	var mask string
+	if err := json.Unmarshal(data, &mask); err == nil {
+		if len(mask) != len(b) || strings.Trim(mask, "01") != "" {
+			return fmt.Errorf("billing mask must be %d characters of 0 and 1", len(b))
+		}
+		for i := range b {
+			b[i] = mask[len(mask)-1-i] == '1'
+		}
+		return nil
+	}
+	var flags map[string]bool
+	if err := json.Unmarshal(data, &flags); err != nil {
+		return fmt.Errorf("billing must be a mask like \"111110\" or an object with flags")
+	}
+	for f, v := range flags {
+		i := billingIndex(f)
+		if i < 0 {
+			return fmt.Errorf("unknown billing flag %q", f)
+		}
+		b[i] = v
+	}
+	return nil
+}
+
+func checkField(values ...string) error { // values are written to ";"-separated lines
+	for _, v := range values {
+		if strings.ContainsAny(v, ";\n") {
+			return fmt.Errorf("value %q must not contain ';' or a line break", v)
+		}
+	}
+	return nil
+}
+
+func decodeBody(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// handleData returns the data the simulator currently serves (without scenarios and corruption).
+func handleData(w http.ResponseWriter, r *http.Request) {
+	worldMu.Lock()
+	defer worldMu.Unlock()
+	response(w, r, struct {
+		Dataset
+		Pinned map[string]bool `json:"pinned"`
+	}{base, pinned})
+}
+
+// handleSystemData replaces (PUT) or updates (PATCH) the data of one system and publishes it at once.
+// Rows are matched by country and provider, support and accendent items by topic; PATCH of a key with several rows is rejected.
+func handleSystemData(w http.ResponseWriter, r *http.Request) {
+	system := mux.Vars(r)["system"]
+	worldMu.Lock()
+	defer worldMu.Unlock()
+	replace := r.Method == http.MethodPut
+	var err error
+	switch system {
+	case "sms", "mms":
+		var rows []SMSRow
+		if err = decodeBody(r, &rows); err == nil {
+			for _, row := range rows {
+				if err = checkField(row.Country, row.Provider); err != nil {
+					break
+				}
+			}
+		}
+		if err == nil {
+			target := &base.SMS
+			if system == "mms" {
+				target = &base.MMS
+			}
+			if replace {
+				*target = rows
+			} else {
+				*target, err = mergeRows(*target, rows, func(r SMSRow) string { return r.Country + "/" + r.Provider })
+			}
+		}
+	case "voice":
+		var rows []VoiceRow
+		if err = decodeBody(r, &rows); err == nil {
+			for _, row := range rows {
+				if err = checkField(row.Country, row.Provider); err != nil {
+					break
+				}
+			}
+		}
+		if err == nil {
+			if replace {
+				base.Voice = rows
+			} else {
+				base.Voice, err = mergeRows(base.Voice, rows, func(r VoiceRow) string { return r.Country + "/" + r.Provider })
+			}
+		}
+	case "email":
+		var rows []EmailRow
+		if err = decodeBody(r, &rows); err == nil {
+			for _, row := range rows {
+				if err = checkField(row.Country, row.Provider); err != nil {
+					break
+				}
+			}
+		}
+		if err == nil {
+			if replace {
+				base.Email = rows
+			} else {
+				base.Email, err = mergeRows(base.Email, rows, func(r EmailRow) string { return r.Country + "/" + r.Provider })
+			}
+		}
+	case "billing":
+		b := base.Billing // PATCH with an object changes only the listed flags
+		if replace {
+			b = Billing{}
+		}
+		if err = decodeBody(r, &b); err == nil {
+			base.Billing = b
+		}
+	case "support":
+		var items []SupportItem
+		if err = decodeBody(r, &items); err == nil {
+			if replace {
+				base.Support = items
+			} else {
+				base.Support, err = mergeRows(base.Support, items, func(i SupportItem) string { return i.Topic })
+			}
+		}
+	case "accendent":
+		var items []AccendentItem
+		if err = decodeBody(r, &items); err == nil {
+			for _, item := range items {
+				if item.Status != accendentStatusActive && item.Status != accendentStatusClosed {
+					err = fmt.Errorf("accendent %q: status must be active or closed", item.Topic)
+					break
+				}
+			}
+		}
+		if err == nil {
+			if replace {
+				base.Accendent = items
+			} else {
+				base.Accendent, err = mergeRows(base.Accendent, items, func(i AccendentItem) string { return i.Topic })
+			}
+		}
+	default:
+		http.Error(w, "unknown system "+system, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	pinned[system] = true
+	publish(applyScenarios(base.clone(), time.Since(started)))
+	fmt.Printf("Data of %s set through the control API\n", system)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// mergeRows replaces the rows with the same key as a patch row and appends the others. A key shared by several
+// rows (e.g. with rows_per_pair > 1) is ambiguous: the patch is rejected and rows are returned unchanged.
+func mergeRows[T any](rows, patch []T, key func(T) string) ([]T, error) {
+	index := make(map[string]int, len(rows))
+	for i, row := range rows {
+		k := key(row)
+		if _, ok := index[k]; ok {
+			index[k] = -1 // several rows
+			continue
+		}
+		index[k] = i
+	}
+	merged := append([]T(nil), rows...)
+	for _, row := range patch {
+		k := key(row)
+		i, ok := index[k]
+		switch {
+		case !ok:
+			index[k] = len(merged)
+			merged = append(merged, row)
+		case i < 0:
+			return rows, fmt.Errorf("%s matches several rows, replace the data with PUT", k)
+		default:
+			merged[i] = row
+		}
+	}
+	return merged, nil
+}
+
+// handleReset generates new random data for all systems and removes the pins.
+func handleReset(w http.ResponseWriter, r *http.Request) {
+	worldMu.Lock()
+	defer worldMu.Unlock()
+	pinned = make(map[string]bool)
+	base = shuffleData()
+	origin = base.clone()
+	publish(applyScenarios(base.clone(), time.Since(started)))
+	fmt.Println("Data reset to random values")
+	printManifest()
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,115 @@
+package simulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+const (
+	corruptSeparators = "separators" // some ";" are removed, so the row has too few fields
+	corruptLetters    = "letters"    // some letters are removed, so country or provider names become unknown
+	corruptTruncate   = "truncate"   // the row is cut in the middle
+	corruptGarbage    = "garbage"    // one field is replaced with junk
+)
+
+type ManifestEntry struct {
+	File      string `json:"file"`
+	Row       int    `json:"row"` // 1-based line number
+	Kind      string `json:"kind"`
+	Detail    string `json:"detail"`
+	Original  string `json:"original"`
+	Corrupted string `json:"corrupted"`
+}
+
+var manifest []ManifestEntry
+
+var corruptors = map[string]func(row string) (string, string){
+	corruptSeparators: func(row string) (string, string) {
+		n := rnd.Intn(3) + 1
+		return strings.Replace(row, ";", "", n), fmt.Sprintf("removed %d separator(s)", n)
+	},
+	corruptLetters: func(row string) (string, string) {
+		var removed []string
+		for _, letter := range []string{"R", "C", "A", "a", "O", "o", "M", "m", "P", "p"} {
+			if n := rnd.Intn(3); n > 0 && strings.Contains(row, letter) {
+				row = strings.Replace(row, letter, "", n)
+				removed = append(removed, fmt.Sprintf("%dx%s", n, letter))
+			}
+		}
+		if len(removed) == 0 && len(row) > 0 { // make sure the row really changes
+			return row[1:], "removed first character"
+		}
+		return row, "removed letters " + strings.Join(removed, ", ")
+	},
+	corruptTruncate: func(row string) (string, string) {
+		end := strings.LastIndex(row, ";") // cut at or before the last separator, so at least one field is lost
+		if end <= 0 {
+			end = len(row) - 1
+		}
+		n := rnd.Intn(end) + 1
+		return row[:n], fmt.Sprintf("truncated to %d characters", n)
+	},
+	corruptGarbage: func(row string) (string, string) {
+		fields := strings.Split(row, ";")
+		i := rnd.Intn(len(fields))
+		fields[i] = "#" + strings.Repeat("?", rnd.Intn(3)+1)
+		return strings.Join(fields, ";"), fmt.Sprintf("field %d replaced with garbage", i+1)
+	},
+}
+
+// corruptRows picks the rows of a file to be damaged according to the settings.
+func corruptRows(file string, total int) map[int]bool {
+	rows := make(map[int]bool)
+	c := settings.Corruption[file]
+	n := c.Rows
+	if pinned[file] { // data set through the control API is written exactly as posted
+		n = 0
+	}
+	if n > total {
+		n = total
+	}
+	for _, i := range rnd.Perm(total)[:n] {
+		rows[i] = true
+	}
+	return rows
+}
+
+// corruptRow damages a row (without the trailing newline) and records it in the manifest.
+func corruptRow(file string, i int, row string) string {
+	kinds := settings.Corruption[file].Kinds
+	kind := kinds[rnd.Intn(len(kinds))]
+	corrupted, detail := corruptors[kind](row)
+	manifest = append(manifest, ManifestEntry{File: file + ".data", Row: i + 1, Kind: kind, Detail: detail, Original: row, Corrupted: corrupted})
+	return corrupted
+}
+
+func sortManifest() {
+	sort.SliceStable(manifest, func(i, j int) bool {
+		if manifest[i].File != manifest[j].File {
+			return manifest[i].File < manifest[j].File
+		}
+		return manifest[i].Row < manifest[j].Row
+	})
+}
+
+func printManifest() {
+	fmt.Printf("Seed %d, %d corrupted row(s)\n", settings.Seed, len(manifest))
+	for _, e := range manifest {
+		fmt.Printf("  %s row %d: %s (%s)\n    %q -> %q\n", e.File, e.Row, e.Kind, e.Detail, e.Original, e.Corrupted)
+	}
+	writeManifest()
+}
+
+// writeManifest writes the manifest of the last generation to settings.ManifestFile, if it is set.
+func writeManifest() {
+	if settings.ManifestFile == "" {
+		return
+	}
+	data, _ := json.MarshalIndent(map[string]interface{}{"seed": settings.Seed, "rows": manifest}, "", "  ")
+	if err := ioutil.WriteFile(settings.ManifestFile, data, 0644); err != nil {
+		fmt.Printf("Error in write manifest: %s\n", err.Error())
+	}
+}
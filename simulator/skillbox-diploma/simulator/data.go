@@ -0,0 +1,204 @@
+package simulator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type SMSRow struct { // also used for MMS
+	Country      string `json:"country"`
+	Provider     string `json:"provider"`
+	Bandwidth    int    `json:"bandwidth"`
+	ResponseTime int    `json:"response_time"`
+}
+
+type VoiceRow struct {
+	Country             string `json:"country"`
+	Provider            string `json:"provider"`
+	Bandwidth           int    `json:"bandwidth"`
+	ResponseTime        int    `json:"response_time"`
+	ConnectionStability int    `json:"connection_stability"` // in thousandths
+	TTFB                int    `json:"ttfb"`
+	VoicePurity         int    `json:"voice_purity"`
+	MedianOfCallsTime   int    `json:"median_of_call_time"`
+}
+
+type EmailRow struct {
+	Country      string `json:"country"`
+	Provider     string `json:"provider"`
+	DeliveryTime int    `json:"delivery_time"`
+}
+
+// Billing holds the flags in the order the service decodes them: bit 0 (the last character of billing.data) is create_customer.
+type Billing [6]bool
+
+var billingFields = []string{"create_customer", "purchase", "payout", "recurring", "fraud_control", "checkout_page"}
+
+func (b Billing) String() string {
+	var sb strings.Builder
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] {
+			sb.WriteByte('1')
+		} else {
+			sb.WriteByte('0')
+		}
+	}
+	return sb.String()
+}
+
+// Dataset is everything the simulator serves: the contents of the data files and of the API collections.
+type Dataset struct {
+	SMS       []SMSRow        `json:"sms"`
+	MMS       []SMSRow        `json:"mms"`
+	Voice     []VoiceRow      `json:"voice"`
+	Email     []EmailRow      `json:"email"`
+	Billing   Billing         `json:"billing"`
+	Support   []SupportItem   `json:"support"`
+	Accendent []AccendentItem `json:"accendent"`
+}
+
+var (
+	base   Dataset      // generated data, drifts over time
+	origin Dataset      // data at start, drift is pulled back towards it
+	dataMu sync.RWMutex // guards the served collections
+)
+
+func (d Dataset) clone() Dataset {
+	c := d
+	c.SMS = append([]SMSRow(nil), d.SMS...)
+	c.MMS = append([]SMSRow(nil), d.MMS...)
+	c.Voice = append([]VoiceRow(nil), d.Voice...)
+	c.Email = append([]EmailRow(nil), d.Email...)
+	c.Support = append([]SupportItem(nil), d.Support...)
+	c.Accendent = append([]AccendentItem(nil), d.Accendent...)
+	return c
+}
+
+// publish writes the data files and replaces the API collections.
+func publish(d Dataset) {
+	manifest = nil
+	writeDataFile(smsFilename, renderRows("sms", len(d.SMS), func(i int) []string {
+		r := d.SMS[i]
+		return []string{r.Country, strconv.Itoa(r.Bandwidth), strconv.Itoa(r.ResponseTime), r.Provider}
+	}))
+	writeDataFile(voiceFilename, renderRows("voice", len(d.Voice), func(i int) []string {
+		r := d.Voice[i]
+		return []string{r.Country, strconv.Itoa(r.Bandwidth), strconv.Itoa(r.ResponseTime), r.Provider,
+			fmt.Sprintf("%.2f", float32(r.ConnectionStability)/1000), strconv.Itoa(r.TTFB), strconv.Itoa(r.VoicePurity), strconv.Itoa(r.MedianOfCallsTime)}
+	}))
+	writeDataFile(emailFilename, renderRows("email", len(d.Email), func(i int) []string {
+		r := d.Email[i]
+		return []string{r.Country, r.Provider, strconv.Itoa(r.DeliveryTime)}
+	}))
+	writeDataFile(billingFilename, d.Billing.String())
+	sortManifest()
+
+	mms := make([]MMSItem, 0, len(d.MMS))
+	for _, r := range d.MMS {
+		mms = append(mms, MMSItem{Country: r.Country, Provider: r.Provider, Bandwidth: strconv.Itoa(r.Bandwidth), ResponseTime: strconv.Itoa(r.ResponseTime)})
+	}
+	dataMu.Lock()
+	MMSCollection = mms
+	SupportCollection = d.Support
+	AccendentCollection = d.Accendent
+	dataMu.Unlock()
+}
+
+func renderRows(file string, n int, fields func(i int) []string) string {
+	var sb strings.Builder
+	corrupt := corruptRows(file, n)
+	for i := 0; i < n; i++ {
+		row := strings.Join(fields(i), ";")
+		if corrupt[i] {
+			row = corruptRow(file, i, row)
+		}
+		sb.WriteString(row)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// writeDataFile replaces the file at once, so the service never reads a half-written file.
+func writeDataFile(filename, data string) {
+	path := getFilapathByFilename(filename)
+	if err := ioutil.WriteFile(path+".tmp", []byte(data), 0644); err != nil {
+		fmt.Printf("Error in write %s: %s\n", filename, err.Error())
+		return
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		fmt.Printf("Error in write %s: %s\n", filename, err.Error())
+	}
+}
+
+// drift moves every value a random step (about settings.Drift of its range) and pulls it back towards its value in from.
+// Rows missing in from (for example, added through the control API) are pulled towards their current value.
+func (d *Dataset) drift(from Dataset) {
+	rs := settings.Ranges
+	for i := 0; i < len(d.SMS) && !pinned["sms"]; i++ {
+		o := d.SMS[i]
+		if i < len(from.SMS) {
+			o = from.SMS[i]
+		}
+		driftValue(&d.SMS[i].Bandwidth, o.Bandwidth, rs.Bandwidth)
+		driftValue(&d.SMS[i].ResponseTime, o.ResponseTime, rs.ResponseTime)
+	}
+	for i := 0; i < len(d.MMS) && !pinned["mms"]; i++ {
+		o := d.MMS[i]
+		if i < len(from.MMS) {
+			o = from.MMS[i]
+		}
+		driftValue(&d.MMS[i].Bandwidth, o.Bandwidth, rs.Bandwidth)
+		driftValue(&d.MMS[i].ResponseTime, o.ResponseTime, rs.ResponseTime)
+	}
+	for i := 0; i < len(d.Voice) && !pinned["voice"]; i++ {
+		v, o := &d.Voice[i], d.Voice[i]
+		if i < len(from.Voice) {
+			o = from.Voice[i]
+		}
+		driftValue(&v.Bandwidth, o.Bandwidth, rs.Bandwidth)
+		driftValue(&v.ResponseTime, o.ResponseTime, rs.ResponseTime)
+		driftValue(&v.ConnectionStability, o.ConnectionStability, rs.ConnectionStability)
+		driftValue(&v.TTFB, o.TTFB, rs.TTFB)
+		driftValue(&v.VoicePurity, o.VoicePurity, rs.VoicePurity)
+		driftValue(&v.MedianOfCallsTime, o.MedianOfCallsTime, rs.VoiceCallMedian)
+	}
+	for i := 0; i < len(d.Email) && !pinned["email"]; i++ {
+		o := d.Email[i]
+		if i < len(from.Email) {
+			o = from.Email[i]
+		}
+		driftValue(&d.Email[i].DeliveryTime, o.DeliveryTime, rs.EmailDeliveryTime)
+	}
+	for i := 0; i < len(d.Support) && !pinned["support"]; i++ {
+		o := d.Support[i]
+		if i < len(from.Support) {
+			o = from.Support[i]
+		}
+		driftValue(&d.Support[i].ActiveTickets, o.ActiveTickets, rs.SupportTickets)
+	}
+	for i := 0; i < len(d.Billing) && !pinned["billing"]; i++ {
+		if rnd.Float64() < settings.Drift/4 { // services fail (and recover) rarely
+			d.Billing[i] = !d.Billing[i]
+		}
+	}
+}
+
+const driftReversion = 0.1 // share of the distance to the starting value recovered on every step
+
+func driftValue(v *int, start int, r Range) {
+	span := float64(r.Max - r.Min)
+	next := float64(*v) + rnd.NormFloat64()*settings.Drift*span + driftReversion*float64(start-*v)
+	next = math.Round(next)
+	if next < float64(r.Min) {
+		next = float64(r.Min)
+	}
+	if next > float64(r.Max-1) {
+		next = float64(r.Max - 1)
+	}
+	*v = int(next)
+}
@@ -0,0 +1,198 @@
+package simulator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	faultBodyTruncated = "truncated" // the body is cut in half
+	faultBodyMalformed = "malformed" // the body is not valid JSON
+	faultBodyEmpty     = "empty"     // no body at all
+)
+
+// Fault describes how the responses of an API endpoint are broken.
+type Fault struct {
+	Latency     Duration `json:"latency"`      // added delay before the response
+	Status      int      `json:"status"`       // response code instead of 200
+	Body        string   `json:"body"`         // truncated, malformed or empty
+	ContentType string   `json:"content_type"` // Content-Type of the response
+	Reset       bool     `json:"reset"`        // the connection is closed without a response
+	Ratio       float64  `json:"ratio"`        // share of requests that are broken; 0 - all
+	Times       int      `json:"times"`        // only this many requests are broken, then the fault is removed; 0 - no limit
+}
+
+var faultEndpoints = map[string]bool{"mms": true, "support": true, "accendent": true}
+
+var (
+	faultsMu sync.Mutex
+	faults   = make(map[string]*Fault)
+	faultRnd *rand.Rand
+)
+
+func (f Fault) validate() error {
+	switch f.Body {
+	case "", faultBodyTruncated, faultBodyMalformed, faultBodyEmpty:
+	default:
+		return fmt.Errorf("unknown body fault %q", f.Body)
+	}
+	if f.Status != 0 && (f.Status < 100 || f.Status > 599) {
+		return fmt.Errorf("invalid status %d", f.Status)
+	}
+	if f.Ratio < 0 || f.Ratio > 1 {
+		return fmt.Errorf("ratio must be between 0 and 1")
+	}
+	if f.Times < 0 {
+		return fmt.Errorf("times must not be negative")
+	}
+	return nil
+}
+
+// faultFor decides whether the current request to the endpoint is broken and how.
+func faultFor(endpoint string) (Fault, bool) {
+	faultsMu.Lock()
+	defer faultsMu.Unlock()
+	f, ok := faults[endpoint]
+	if !ok {
+		return Fault{}, false
+	}
+	if f.Ratio > 0 && faultRnd.Float64() >= f.Ratio {
+		return Fault{}, false
+	}
+	if f.Times > 0 {
+		f.Times--
+		if f.Times == 0 {
+			delete(faults, endpoint)
+		}
+	}
+	return *f, true
+}
+
+type bufferedResponse struct {
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header         { return b.header }
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
+func (b *bufferedResponse) WriteHeader(int)             {}
+
+// withFaults breaks the responses of next according to the fault set for the endpoint.
+func withFaults(endpoint string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f, ok := faultFor(endpoint)
+		if !ok {
+			next(w, r)
+			return
+		}
+		if f.Latency.Duration > 0 {
+			select {
+			case <-time.After(f.Latency.Duration):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if f.Reset {
+			resetConnection(w)
+			return
+		}
+		buf := &bufferedResponse{header: w.Header()}
+		next(buf, r)
+		body := buf.body.Bytes()
+		switch f.Body {
+		case faultBodyTruncated:
+			body = body[:len(body)/2]
+		case faultBodyMalformed:
+			if bytes.Contains(body, []byte(`":`)) {
+				body = bytes.Replace(body, []byte(`":`), []byte(`" `), 1) // a key without a colon
+			} else {
+				body = append([]byte("{"), body...)
+			}
+		case faultBodyEmpty:
+			body = nil
+		}
+		if f.ContentType != "" {
+			w.Header().Set("Content-Type", f.ContentType)
+		}
+		status := http.StatusOK
+		if f.Status != 0 {
+			status = f.Status
+		}
+		w.WriteHeader(status)
+		w.Write(body)
+	}
+}
+
+// resetConnection drops the TCP connection so that the client gets "connection reset by peer".
+func resetConnection(w http.ResponseWriter) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		panic(http.ErrAbortHandler)
+	}
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0) // RST instead of FIN
+	}
+	conn.Close()
+}
+
+func handleFaults(w http.ResponseWriter, r *http.Request) {
+	faultsMu.Lock()
+	defer faultsMu.Unlock()
+	switch r.Method {
+	case http.MethodDelete:
+		faults = make(map[string]*Fault)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		response(w, r, faults)
+	}
+}
+
+func handleFault(w http.ResponseWriter, r *http.Request) {
+	endpoint := mux.Vars(r)["endpoint"]
+	if !faultEndpoints[endpoint] {
+		http.Error(w, "unknown endpoint "+endpoint, http.StatusNotFound)
+		return
+	}
+	faultsMu.Lock()
+	defer faultsMu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		var f Fault
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&f); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := f.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		faults[endpoint] = &f
+		fmt.Printf("Fault set for /%s: %+v\n", endpoint, f)
+		response(w, r, f)
+	case http.MethodDelete:
+		delete(faults, endpoint)
+		fmt.Printf("Fault removed for /%s\n", endpoint)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f, ok := faults[endpoint]
+		if !ok {
+			http.Error(w, "no fault for "+endpoint, http.StatusNotFound)
+			return
+		}
+		response(w, r, f)
+	}
+}
@@ -0,0 +1,215 @@
+package simulator
+
+import (
+	"encoding/json"
+	"finalwork/internal/countries"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strings"
+)
+
+const allCountries = "all"
+
+const maxGeneratedProviders = 1000
+
+const (
+	distributionUniform   = "uniform"   // every value is equally likely within its range
+	distributionRealistic = "realistic" // most values are healthy with a long tail, providers and countries differ in quality
+)
+
+// Scale controls the volume of generated data for load testing. The zero value keeps the classic small dataset.
+type Scale struct {
+	Providers           int    `json:"providers"`             // extra generated providers per channel
+	ProvidersPerCountry int    `json:"providers_per_country"` // providers serving each country in a channel; 0 - one provider (all providers for email)
+	RowsPerPair         int    `json:"rows_per_pair"`         // rows for each country and provider; 0 - one row
+	Distribution        string `json:"distribution"`          // uniform (default) or realistic
+	CatalogFile         string `json:"catalog_file"`          // write the providers as a service config fragment to this file
+}
+
+func (sc Scale) validate() error {
+	if sc.Providers < 0 || sc.ProvidersPerCountry < 0 || sc.RowsPerPair < 0 {
+		return fmt.Errorf("scale: providers, providers_per_country and rows_per_pair must not be negative")
+	}
+	if sc.Providers > maxGeneratedProviders {
+		return fmt.Errorf("scale: at most %d generated providers per channel", maxGeneratedProviders)
+	}
+	switch sc.Distribution {
+	case "", distributionUniform, distributionRealistic:
+	default:
+		return fmt.Errorf("scale: unknown distribution %q", sc.Distribution)
+	}
+	return nil
+}
+
+func (sc Scale) rows() int {
+	if sc.RowsPerPair == 0 {
+		return 1
+	}
+	return sc.RowsPerPair
+}
+
+// knownCountries returns the alpha-2 codes of all known countries: the list the service validates against.
+func knownCountries() []string {
+	var codes []string
+	for _, c := range countries.Embedded() {
+		codes = append(codes, c.Alpha2)
+	}
+	return codes
+}
+
+var (
+	providerQuality = make(map[string]float64) // 0 - the worst, 1 - the best; used by the realistic distribution
+	countryQuality  = make(map[string]float64)
+)
+
+// setupGenerator adds the generated providers to the channels and assigns qualities. Called once after rnd is seeded,
+// so provider names stay the same across resets and the written catalog remains valid.
+func setupGenerator() error {
+	if n := settings.Scale.Providers; n > 0 {
+		taken := make(map[string]bool)
+		for _, list := range [][]string{settings.Providers.SMS, settings.Providers.MMS, settings.Providers.Voice, settings.Providers.Email} {
+			for _, p := range list {
+				taken[strings.ToLower(p)] = true
+			}
+		}
+		messaging := generateProviderNames(n, []string{"tel", "com", "net", "link", "cell"}, taken)
+		settings.Providers.SMS = append(settings.Providers.SMS, messaging...)
+		settings.Providers.MMS = append(settings.Providers.MMS, messaging...)
+		settings.Providers.Voice = append(settings.Providers.Voice, generateProviderNames(n, []string{" Voice", " Calls", "Phone"}, taken)...)
+		settings.Providers.Email = append(settings.Providers.Email, generateProviderNames(n, []string{"mail", " Mail", "box"}, taken)...)
+	}
+	if settings.Scale.Distribution == distributionRealistic {
+		for _, list := range [][]string{settings.Providers.SMS, settings.Providers.MMS, settings.Providers.Voice, settings.Providers.Email} {
+			for _, p := range list {
+				if _, ok := providerQuality[p]; !ok {
+					providerQuality[p] = rnd.Float64()
+				}
+			}
+		}
+		for _, c := range settings.Countries {
+			countryQuality[c] = 0.3 + 0.7*rnd.Float64() // no country is hopeless
+		}
+	}
+	if settings.Scale.CatalogFile != "" {
+		return writeCatalog(settings.Scale.CatalogFile)
+	}
+	return nil
+}
+
+var nameSyllables = []string{"ka", "lo", "ve", "tra", "zen", "mi", "ro", "sol", "nu", "di", "ax", "or", "pel", "qui", "sta", "bri"}
+
+func generateProviderNames(n int, suffixes []string, taken map[string]bool) []string {
+	names := make([]string, 0, n)
+	for len(names) < n {
+		name := nameSyllables[rnd.Intn(len(nameSyllables))] + nameSyllables[rnd.Intn(len(nameSyllables))]
+		if len(names) >= len(nameSyllables)*len(nameSyllables)/2 { // the short names run out
+			name += nameSyllables[rnd.Intn(len(nameSyllables))]
+		}
+		name = strings.ToUpper(name[:1]) + name[1:] + suffixes[rnd.Intn(len(suffixes))]
+		if taken[strings.ToLower(name)] {
+			continue
+		}
+		taken[strings.ToLower(name)] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// writeCatalog writes all providers in the format of the "providers" section of the service config,
+// so the service accepts the generated providers: go run . -config <file>.
+func writeCatalog(file string) error {
+	type entry struct {
+		Name     string   `json:"name"`
+		Channels []string `json:"channels"`
+	}
+	var catalog []*entry
+	byName := make(map[string]*entry)
+	for _, ch := range []struct {
+		name string
+		list []string
+	}{{"sms", settings.Providers.SMS}, {"mms", settings.Providers.MMS}, {"voice", settings.Providers.Voice}, {"email", settings.Providers.Email}} {
+		for _, p := range ch.list {
+			e, ok := byName[p]
+			if !ok {
+				e = &entry{Name: p}
+				byName[p] = e
+				catalog = append(catalog, e)
+			}
+			e.Channels = append(e.Channels, ch.name)
+		}
+	}
+	data, err := json.MarshalIndent(map[string]interface{}{"providers": catalog}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, data, 0644)
+}
+
+// countryProviders returns the providers serving the country in a channel: the assigned one first, then random others.
+func countryProviders(assigned map[string]string, list []string, country string) []string {
+	n := settings.Scale.ProvidersPerCountry
+	if n == 0 {
+		return []string{providerFor(assigned, list, country)}
+	}
+	if n > len(list) {
+		n = len(list)
+	}
+	chosen := make([]string, 0, n)
+	seen := make(map[string]bool)
+	if p, ok := assigned[country]; ok {
+		chosen = append(chosen, p)
+		seen[p] = true
+	}
+	for _, i := range rnd.Perm(len(list)) {
+		if len(chosen) >= n {
+			break
+		}
+		if !seen[list[i]] {
+			chosen = append(chosen, list[i])
+			seen[list[i]] = true
+		}
+	}
+	return chosen
+}
+
+// emailProviders returns the email providers of the country: all of them unless providers_per_country is set.
+func emailProviders(country string) []string {
+	if settings.Scale.ProvidersPerCountry == 0 {
+		return settings.Providers.Email
+	}
+	return countryProviders(nil, settings.Providers.Email, country)
+}
+
+const (
+	shapeLowIsGood  = iota // response, delivery and first byte times: mostly fast with a slow tail
+	shapeHighIsGood        // connection stability and voice purity: mostly high with a poor tail
+	shapeNormal            // load and call duration: spread around the middle
+)
+
+// sample returns a value of the range for the provider's row in the country.
+// With the uniform distribution it is the classic getRandomIntInRange.
+func sample(r Range, shape int, country, provider string) int {
+	if settings.Scale.Distribution != distributionRealistic {
+		return getRandomIntInRange(r)
+	}
+	q := (providerQuality[provider] + countryQuality[country]) / 2
+	var f float64 // share of the range
+	switch shape {
+	case shapeNormal:
+		f = 0.4 + 0.15*rnd.NormFloat64()
+	default:
+		f = math.Exp(0.6*rnd.NormFloat64()) * (0.05 + 0.3*(1-q)) // log-normal, worse providers are slower
+		if shape == shapeHighIsGood {
+			f = 1 - f
+		}
+	}
+	v := r.Min + int(math.Floor(f*float64(r.Max-r.Min)))
+	if v < r.Min {
+		v = r.Min
+	}
+	if v > r.Max-1 {
+		v = r.Max - 1
+	}
+	return v
+}
@@ -0,0 +1,226 @@
+package simulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Duration is a time.Duration written in JSON as a string like "90s" or "5m".
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"5m\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// Scenario changes the generated data for a period of time, for example "Rond degrades in US for 5 minutes".
+// Changes are applied on top of the drifting data and disappear when the scenario ends.
+type Scenario struct {
+	Name     string   `json:"name"`
+	At       Duration `json:"at"`       // start, counted from the simulator start
+	Duration Duration `json:"duration"` // 0 - until the simulator stops
+
+	Channel  string         `json:"channel"`  // sms, mms, voice or email rows are changed
+	Country  string         `json:"country"`  // only rows of this country (empty - all)
+	Provider string         `json:"provider"` // only rows of this provider (empty - all)
+	Set      map[string]int `json:"set"`      // field -> value, e.g. {"response_time": 1900}
+	Outage   bool           `json:"outage"`   // matching rows disappear
+
+	Billing    map[string]bool   `json:"billing"`    // flag -> value, e.g. {"checkout_page": false}
+	Support    map[string]int    `json:"support"`    // topic -> active tickets
+	Accendents map[string]string `json:"accendents"` // topic -> status; unknown topics are added
+}
+
+func (sc Scenario) activeAt(elapsed time.Duration) bool {
+	if elapsed < sc.At.Duration {
+		return false
+	}
+	return sc.Duration.Duration == 0 || elapsed < sc.At.Duration+sc.Duration.Duration
+}
+
+func (sc Scenario) matches(country, provider string) bool {
+	return (sc.Country == "" || strings.EqualFold(sc.Country, country)) && (sc.Provider == "" || strings.EqualFold(sc.Provider, provider))
+}
+
+var rowFields = map[string]map[string]bool{ // fields a scenario may set, by channel
+	"sms":   {"bandwidth": true, "response_time": true},
+	"mms":   {"bandwidth": true, "response_time": true},
+	"voice": {"bandwidth": true, "response_time": true, "connection_stability": true, "ttfb": true, "voice_purity": true, "median_of_call_time": true},
+	"email": {"delivery_time": true},
+}
+
+func (sc Scenario) validate() error {
+	if sc.Channel != "" {
+		fields, ok := rowFields[sc.Channel]
+		if !ok {
+			return fmt.Errorf("scenario %q: unknown channel %q", sc.Name, sc.Channel)
+		}
+		for f := range sc.Set {
+			if !fields[f] {
+				return fmt.Errorf("scenario %q: channel %s has no field %q", sc.Name, sc.Channel, f)
+			}
+		}
+	} else if len(sc.Set) > 0 || sc.Outage {
+		return fmt.Errorf("scenario %q: set and outage need a channel", sc.Name)
+	}
+	for f := range sc.Billing {
+		if billingIndex(f) < 0 {
+			return fmt.Errorf("scenario %q: unknown billing flag %q", sc.Name, f)
+		}
+	}
+	for topic, status := range sc.Accendents {
+		if status != accendentStatusActive && status != accendentStatusClosed {
+			return fmt.Errorf("scenario %q: accendent %q: status must be active or closed", sc.Name, topic)
+		}
+	}
+	return nil
+}
+
+func billingIndex(field string) int {
+	for i, f := range billingFields {
+		if f == field {
+			return i
+		}
+	}
+	return -1
+}
+
+// applyScenarios returns d with the changes of the scenarios active at elapsed.
+func applyScenarios(d Dataset, elapsed time.Duration) Dataset {
+	for _, sc := range settings.Scenarios {
+		if !sc.activeAt(elapsed) {
+			continue
+		}
+		switch sc.Channel {
+		case "sms":
+			d.SMS = applyToSMSRows(sc, d.SMS)
+		case "mms":
+			d.MMS = applyToSMSRows(sc, d.MMS)
+		case "voice":
+			rows := d.Voice[:0:0]
+			for _, r := range d.Voice {
+				if !sc.matches(r.Country, r.Provider) {
+					rows = append(rows, r)
+					continue
+				}
+				if sc.Outage {
+					continue
+				}
+				setField(&r.Bandwidth, sc.Set, "bandwidth")
+				setField(&r.ResponseTime, sc.Set, "response_time")
+				setField(&r.ConnectionStability, sc.Set, "connection_stability")
+				setField(&r.TTFB, sc.Set, "ttfb")
+				setField(&r.VoicePurity, sc.Set, "voice_purity")
+				setField(&r.MedianOfCallsTime, sc.Set, "median_of_call_time")
+				rows = append(rows, r)
+			}
+			d.Voice = rows
+		case "email":
+			rows := d.Email[:0:0]
+			for _, r := range d.Email {
+				if !sc.matches(r.Country, r.Provider) {
+					rows = append(rows, r)
+					continue
+				}
+				if sc.Outage {
+					continue
+				}
+				setField(&r.DeliveryTime, sc.Set, "delivery_time")
+				rows = append(rows, r)
+			}
+			d.Email = rows
+		}
+		for f, v := range sc.Billing {
+			d.Billing[billingIndex(f)] = v
+		}
+		for i := range d.Support {
+			if v, ok := sc.Support[d.Support[i].Topic]; ok {
+				d.Support[i].ActiveTickets = v
+			}
+		}
+		for topic, status := range sc.Accendents {
+			found := false
+			for i := range d.Accendent {
+				if d.Accendent[i].Topic == topic {
+					d.Accendent[i].Status = status
+					found = true
+				}
+			}
+			if !found {
+				d.Accendent = append(d.Accendent, AccendentItem{Topic: topic, Status: status})
+			}
+		}
+	}
+	return d
+}
+
+func applyToSMSRows(sc Scenario, in []SMSRow) []SMSRow {
+	rows := in[:0:0]
+	for _, r := range in {
+		if !sc.matches(r.Country, r.Provider) {
+			rows = append(rows, r)
+			continue
+		}
+		if sc.Outage {
+			continue
+		}
+		setField(&r.Bandwidth, sc.Set, "bandwidth")
+		setField(&r.ResponseTime, sc.Set, "response_time")
+		rows = append(rows, r)
+	}
+	return rows
+}
+
+func setField(v *int, set map[string]int, field string) {
+	if value, ok := set[field]; ok {
+		*v = value
+	}
+}
+
+var (
+	worldMu sync.Mutex // guards base, origin, rnd and the start time
+	started = time.Now()
+)
+
+// runClock regenerates the data every settings.Interval: values drift, scenarios start and end.
+func runClock() {
+	ticker := time.NewTicker(settings.Interval.Duration)
+	defer ticker.Stop()
+	active := make(map[int]bool)
+	for range ticker.C {
+		worldMu.Lock()
+		elapsed := time.Since(started)
+		for i, sc := range settings.Scenarios {
+			if now := sc.activeAt(elapsed); now != active[i] {
+				active[i] = now
+				state := "ended"
+				if now {
+					state = "started"
+				}
+				fmt.Printf("t+%s: scenario %q %s\n", elapsed.Truncate(time.Second), sc.Name, state)
+			}
+		}
+		base.drift(origin)
+		publish(applyScenarios(base.clone(), elapsed))
+		fmt.Printf("t+%s: data regenerated, %d corrupted row(s)\n", elapsed.Truncate(time.Second), len(manifest))
+		writeManifest()
+		worldMu.Unlock()
+	}
+}
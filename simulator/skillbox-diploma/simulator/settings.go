@@ -0,0 +1,261 @@
+package simulator
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Range is a half-open interval [Min, Max), as used by getRandomIntBetweenValues.
+type Range struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+type Ranges struct {
+	ResponseTime        Range `json:"response_time"`
+	ConnectionStability Range `json:"connection_stability"` // in thousandths, 600 means 0.60
+	VoicePurity         Range `json:"voice_purity"`
+	VoiceCallMedian     Range `json:"voice_call_median"`
+	TTFB                Range `json:"ttfb"`
+	Bandwidth           Range `json:"bandwidth"`
+	EmailDeliveryTime   Range `json:"email_delivery_time"`
+	SupportTickets      Range `json:"support_tickets"`
+}
+
+// Corruption describes how many rows of a data file are damaged and in which ways.
+type Corruption struct {
+	Rows  int      `json:"rows"`
+	Kinds []string `json:"kinds"` // separators, letters, truncate, garbage
+}
+
+type Providers struct {
+	SMS   []string `json:"sms"`
+	MMS   []string `json:"mms"`
+	Voice []string `json:"voice"`
+	Email []string `json:"email"`
+}
+
+type Assignments struct { // country -> provider; countries without an assignment get a random provider of the channel
+	SMS   map[string]string `json:"sms"`
+	MMS   map[string]string `json:"mms"`
+	Voice map[string]string `json:"voice"`
+}
+
+type Settings struct {
+	Seed            int64                 `json:"seed"`      // 0 - seed from the clock
+	Countries       []string              `json:"countries"` // ["all"] - all known countries
+	Providers       Providers             `json:"providers"`
+	Assignments     Assignments           `json:"assignments"`
+	SupportTopics   []string              `json:"support_topics"`
+	AccendentTopics []string              `json:"accendent_topics"`
+	Ranges          Ranges                `json:"ranges"`
+	Corruption      map[string]Corruption `json:"corruption"` // by file: sms, voice, email
+	ManifestFile    string                `json:"manifest_file"`
+
+	Interval  Duration   `json:"interval"`  // regenerate the data this often; 0 - the data is generated once
+	Drift     float64    `json:"drift"`     // typical change of a value per regeneration, as a share of its range
+	Scenarios []Scenario `json:"scenarios"` // scripted changes, see Scenario
+
+	Scale Scale `json:"scale"` // volume of data for load testing
+
+	SMTPAddr string `json:"smtp_addr"` // address of the SMTP stand-in; "" - disabled
+}
+
+var settings Settings
+var rnd *rand.Rand
+
+func defaultSettings() Settings {
+	return Settings{
+		Countries: getCountriesList(),
+		Providers: Providers{
+			SMS:   []string{"Topolo", "Rond", "Kildy"},
+			MMS:   []string{"Topolo", "Rond", "Kildy"},
+			Voice: []string{"TransparentCalls", "E-Voice", "JustPhone"},
+			Email: getEmailProvidersList(),
+		},
+		Assignments: Assignments{
+			SMS:   getSmsProviderMap(),
+			MMS:   getMMSProviderMap(),
+			Voice: getVoiceCallProviderMap(),
+		},
+		SupportTopics:   getSupportTopicsList(),
+		AccendentTopics: AccendentTopics,
+		Ranges: Ranges{
+			ResponseTime:        Range{minResponseTime, maxResponseTime},
+			ConnectionStability: Range{minConnectionStability, maxConnectionStability},
+			VoicePurity:         Range{minVoicePurity, maxVoicePurity},
+			VoiceCallMedian:     Range{minVoiceCallMedian, maxVoiceCallMedian},
+			TTFB:                Range{minTTFB, maxTTFB},
+			Bandwidth:           Range{minBandwidth, maxBandwidth},
+			EmailDeliveryTime:   Range{minEmailDeliveryTime, maxEmailDeliveryTime},
+			SupportTickets:      Range{0, 8},
+		},
+		Corruption: map[string]Corruption{
+			"sms":   {Rows: 2, Kinds: []string{corruptSeparators, corruptLetters}},
+			"voice": {Rows: 2, Kinds: []string{corruptSeparators, corruptLetters}},
+			"email": {Rows: 2, Kinds: []string{corruptSeparators, corruptLetters}},
+		},
+		Drift:    0.02,
+		SMTPAddr: "127.0.0.1:2525",
+	}
+}
+
+// loadSettings builds settings from defaults, an optional JSON config file and command line flags (flags win).
+func loadSettings(args []string) (Settings, error) {
+	s := defaultSettings()
+	fs := flag.NewFlagSet("simulator", flag.ContinueOnError)
+	configFile := fs.String("config", "", "JSON file with simulator settings")
+	seed := fs.Int64("seed", 0, "random seed; the same seed and settings produce the same data (0 - seed from the clock)")
+	rows := fs.Int("corrupt-rows", -1, "number of corrupted rows in each of sms.data, voice.data and email.data")
+	kinds := fs.String("corrupt-kinds", "", "comma separated corruption kinds: separators, letters, truncate, garbage")
+	countries := fs.String("countries", "", "comma separated alpha-2 country codes or \"all\" for all known countries")
+	manifest := fs.String("manifest", "", "also write the corruption manifest as JSON to this file")
+	interval := fs.Duration("interval", 0, "regenerate the data this often, e.g. 30s (0 - generate once)")
+	drift := fs.Float64("drift", -1, "typical change of a value per regeneration, as a share of its range")
+	scenarios := fs.String("scenarios", "", "JSON file with a list of scenarios (replaces scenarios from -config)")
+	providers := fs.Int("providers", -1, "generate this many extra providers per channel")
+	perCountry := fs.Int("providers-per-country", -1, "providers serving each country in a channel")
+	rowsPerPair := fs.Int("rows-per-pair", -1, "rows for each country and provider")
+	distribution := fs.String("distribution", "", "value distribution: uniform or realistic")
+	catalog := fs.String("catalog", "", "write all providers as a service config fragment to this file")
+	smtpAddr := fs.String("smtp", "", "address of the SMTP stand-in, e.g. 127.0.0.1:2525 (\"off\" - disabled)")
+	if err := fs.Parse(args); err != nil {
+		return s, err
+	}
+	if *configFile != "" {
+		data, err := ioutil.ReadFile(*configFile)
+		if err != nil {
+			return s, err
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&s); err != nil {
+			return s, fmt.Errorf("%s: %w", *configFile, err)
+		}
+	}
+	if *seed != 0 {
+		s.Seed = *seed
+	}
+	for file, c := range s.Corruption {
+		if *rows >= 0 {
+			c.Rows = *rows
+		}
+		if *kinds != "" {
+			c.Kinds = strings.Split(*kinds, ",")
+		}
+		s.Corruption[file] = c
+	}
+	if *countries != "" {
+		s.Countries = strings.Split(*countries, ",")
+	}
+	if *manifest != "" {
+		s.ManifestFile = *manifest
+	}
+	if *interval > 0 {
+		s.Interval.Duration = *interval
+	}
+	if *drift >= 0 {
+		s.Drift = *drift
+	}
+	if *scenarios != "" {
+		data, err := ioutil.ReadFile(*scenarios)
+		if err != nil {
+			return s, err
+		}
+		s.Scenarios = nil
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&s.Scenarios); err != nil {
+			return s, fmt.Errorf("%s: %w", *scenarios, err)
+		}
+	}
+	if *providers >= 0 {
+		s.Scale.Providers = *providers
+	}
+	if *perCountry >= 0 {
+		s.Scale.ProvidersPerCountry = *perCountry
+	}
+	if *rowsPerPair >= 0 {
+		s.Scale.RowsPerPair = *rowsPerPair
+	}
+	if *distribution != "" {
+		s.Scale.Distribution = *distribution
+	}
+	if *catalog != "" {
+		s.Scale.CatalogFile = *catalog
+	}
+	switch *smtpAddr {
+	case "":
+	case "off":
+		s.SMTPAddr = ""
+	default:
+		s.SMTPAddr = *smtpAddr
+	}
+	if len(s.Countries) == 1 && s.Countries[0] == allCountries {
+		s.Countries = knownCountries()
+	}
+	if len(s.Scenarios) > 0 && s.Interval.Duration == 0 {
+		s.Interval.Duration = 10 * time.Second // scenarios need a clock
+	}
+	if s.Seed == 0 {
+		s.Seed = time.Now().UnixNano()
+	}
+	return s, s.validate()
+}
+
+func (s Settings) validate() error {
+	for name, r := range map[string]Range{
+		"response_time": s.Ranges.ResponseTime, "connection_stability": s.Ranges.ConnectionStability,
+		"voice_purity": s.Ranges.VoicePurity, "voice_call_median": s.Ranges.VoiceCallMedian, "ttfb": s.Ranges.TTFB,
+		"bandwidth": s.Ranges.Bandwidth, "email_delivery_time": s.Ranges.EmailDeliveryTime, "support_tickets": s.Ranges.SupportTickets,
+	} {
+		if r.Max <= r.Min {
+			return fmt.Errorf("range %s: max must be greater than min", name)
+		}
+	}
+	for file, c := range s.Corruption {
+		if file != "sms" && file != "voice" && file != "email" {
+			return fmt.Errorf("corruption: unknown file %q", file)
+		}
+		if c.Rows > 0 && len(c.Kinds) == 0 {
+			return fmt.Errorf("corruption %s: no kinds", file)
+		}
+		for _, k := range c.Kinds {
+			if _, ok := corruptors[k]; !ok {
+				return fmt.Errorf("corruption %s: unknown kind %q", file, k)
+			}
+		}
+	}
+	for _, sc := range s.Scenarios {
+		if err := sc.validate(); err != nil {
+			return err
+		}
+	}
+	if err := s.Scale.validate(); err != nil {
+		return err
+	}
+	if s.Drift < 0 || s.Drift > 1 {
+		return fmt.Errorf("drift must be between 0 and 1")
+	}
+	if len(s.Countries) == 0 {
+		return fmt.Errorf("countries: empty list")
+	}
+	if len(s.Providers.SMS) == 0 || len(s.Providers.MMS) == 0 || len(s.Providers.Voice) == 0 || len(s.Providers.Email) == 0 {
+		return fmt.Errorf("providers: every channel needs at least one provider")
+	}
+	return nil
+}
+
+// providerFor returns the assigned provider of the country or a random one from the channel's list.
+func providerFor(assigned map[string]string, list []string, country string) string {
+	if p, ok := assigned[country]; ok {
+		return p
+	}
+	return list[rnd.Intn(len(list))]
+}
@@ -0,0 +1,447 @@
+package simulator
+
+import (
+	"math/rand"
+	"fmt"
+	"path/filepath"
+	"time"
+	"encoding/json"
+	"net/http"
+	"github.com/gorilla/mux"
+)
+
+const minResponseTime = 30
+const maxResponseTime = 2000
+
+const minConnectionStability = 600
+const maxConnectionStability = 1000
+
+const minVoicePurity = 0
+const maxVoicePurity = 92
+
+const minVoiceCallMedian = 3
+const maxVoiceCallMedian = 60
+
+const minTTFB = 2
+const maxTTFB = 980
+
+const minBandwidth = 0
+const maxBandwidth = 100
+
+const minEmailDeliveryTime = 0
+const maxEmailDeliveryTime = 600
+
+const smsFilename = "sms.data"
+const mmsApiUrl = "http://localhost:8282/mms" // to params
+const voiceFilename = "voice.data"
+const emailFilename = "email.data"
+const billingFilename = "billing.data"
+const supportApiUrl = "http://localhost:8282/support"
+const accendentListFilename = "accendents.data"
+
+var dataDir string // where the data files are written
+
+var MMSCollection []MMSItem
+var SupportCollection []SupportItem
+var AccendentCollection []AccendentItem
+
+type MMSItem struct {
+	Country  string `json:"country"`
+	Provider string `json:"provider"`
+	Bandwidth string `json:"bandwidth"`
+	ResponseTime string `json:"response_time"`
+}
+
+type SupportItem struct {
+	Topic string `json:"topic"`
+	ActiveTickets int `json:"active_tickets"`
+}
+
+type AccendentItem struct {
+	Topic string  `json:"topic"`
+	Status string `json:"status"`
+	Severity string `json:"severity,omitempty"` // optional, set through the control API
+	Updates []AccendentUpdate `json:"updates,omitempty"`
+}
+
+type AccendentUpdate struct {
+	At time.Time `json:"at"`
+	Text string `json:"text"`
+}
+
+const accendentStatusActive = "active"
+const accendentStatusClosed = "closed"
+
+var AccendentTopics = []string{
+	"SMS delivery in EU",
+	"MMS connection stability",
+	"Voice call connection purity",
+	"Checkout page is down",
+	"Support overload",
+	"Buy phone number not working in US",
+	"API Slow latency",
+}
+
+
+// Setup loads the settings from args (the command line flags), generates the data and writes the data files into dir ("" - the current directory).
+// It may be called again, e.g. by tests: the previous data, pins, faults and received mail are dropped.
+func Setup(args []string, dir string) error {
+	s, err := loadSettings(args)
+	if err != nil {
+		return fmt.Errorf("settings: %w", err)
+	}
+	worldMu.Lock()
+	defer worldMu.Unlock()
+	settings = s
+	dataDir = dir
+	rnd = rand.New(rand.NewSource(settings.Seed))
+	faultRnd = rand.New(rand.NewSource(settings.Seed))
+	pinned = make(map[string]bool)
+	faultsMu.Lock()
+	faults = make(map[string]*Fault)
+	faultsMu.Unlock()
+	providerQuality = make(map[string]float64)
+	countryQuality = make(map[string]float64)
+	ClearMailbox()
+	if err = setupGenerator(); err != nil {
+		return fmt.Errorf("generator: %w", err)
+	}
+
+	base = shuffleData()
+	origin = base.clone()
+	started = time.Now()
+	publish(applyScenarios(base.clone(), 0))
+
+	printManifest()
+	return nil
+}
+
+// Run works as the simulator command: generates the data, starts the clock and serves the API until the server fails.
+func Run(args []string) error {
+	if err := Setup(args, ""); err != nil {
+		return err
+	}
+
+	if settings.Interval.Duration > 0 {
+		go runClock()
+	}
+
+	if settings.SMTPAddr != "" {
+		l, err := ListenSMTP(settings.SMTPAddr)
+		if err != nil {
+			return fmt.Errorf("smtp: %w", err)
+		}
+		defer l.Close()
+		fmt.Printf("SMTP stand-in listening on %s\n", l.Addr())
+	}
+
+	return listenAndServeHTTP()
+}
+
+func shuffleData() Dataset {
+	return Dataset{
+		SMS:       shuffleSmsData(),
+		MMS:       shuffleMMSData(),
+		Voice:     shuffleVoiceData(),
+		Email:     shuffleEmailData(),
+		Billing:   shuffleBillingData(),
+		Support:   shuffleSupportData(),
+		Accendent: shuffleAccendentData(),
+	}
+}
+
+func shuffleSmsData() []SMSRow {
+	data := make([]SMSRow, 0)
+	for _, country := range(settings.Countries) {
+		for _, provider := range getSmsProvidersByCountry(country) {
+			for i := 0; i < settings.Scale.rows(); i++ {
+				data = append(data, SMSRow{
+					Country: country,
+					Bandwidth: sample(settings.Ranges.Bandwidth, shapeNormal, country, provider),
+					ResponseTime: sample(settings.Ranges.ResponseTime, shapeLowIsGood, country, provider),
+					Provider: provider,
+				})
+			}
+		}
+	}
+
+	return data
+}
+
+func shuffleMMSData() []SMSRow {
+	data := make([]SMSRow, 0)
+	for _, country := range(settings.Countries) {
+		for _, provider := range getMMSProvidersByCountry(country) {
+			for i := 0; i < settings.Scale.rows(); i++ {
+				data = append(
+					data,
+					SMSRow{
+						Country: country,
+						Provider: provider,
+						Bandwidth: sample(settings.Ranges.Bandwidth, shapeNormal, country, provider),
+						ResponseTime: sample(settings.Ranges.ResponseTime, shapeLowIsGood, country, provider),
+					},
+				)
+			}
+		}
+	}
+
+	return data
+}
+
+func shuffleVoiceData() []VoiceRow {
+	data := make([]VoiceRow, 0)
+	for _, country := range(settings.Countries) {
+		for _, provider := range getVoiceCallProvidersByCountry(country) {
+			for i := 0; i < settings.Scale.rows(); i++ {
+				data = append(data, VoiceRow{
+					Country: country,
+					Bandwidth: sample(settings.Ranges.Bandwidth, shapeNormal, country, provider),
+					ResponseTime: sample(settings.Ranges.ResponseTime, shapeLowIsGood, country, provider),
+					Provider: provider,
+					ConnectionStability: sample(settings.Ranges.ConnectionStability, shapeHighIsGood, country, provider),
+					TTFB: sample(settings.Ranges.TTFB, shapeLowIsGood, country, provider),
+					VoicePurity: sample(settings.Ranges.VoicePurity, shapeHighIsGood, country, provider),
+					MedianOfCallsTime: sample(settings.Ranges.VoiceCallMedian, shapeNormal, country, provider),
+				})
+			}
+		}
+	}
+
+	return data
+}
+
+func shuffleEmailData() []EmailRow {
+	data := make([]EmailRow, 0)
+	for _, country := range settings.Countries {
+		for _, provider := range emailProviders(country) {
+			for i := 0; i < settings.Scale.rows(); i++ {
+				data = append(data, EmailRow{
+					Country: country,
+					Provider: provider,
+					DeliveryTime: sample(settings.Ranges.EmailDeliveryTime, shapeLowIsGood, country, provider),
+				})
+			}
+		}
+	}
+
+	return data
+}
+
+func shuffleBillingData() Billing {
+	var data Billing
+	for i := 0; i < 6; i++ {
+		value := getRandomIntBetweenValues(0, 150)
+		data[i] = value > 50
+		// create customer
+		// purchase
+		// payout
+		// recurring
+		// fraud control
+		// checkout page
+	}
+
+	return data
+}
+
+func shuffleSupportData() []SupportItem {
+	data := make([]SupportItem, 0)
+	for _, topic := range settings.SupportTopics {
+		data = append(data, SupportItem{Topic: topic, ActiveTickets: getRandomSupportTickets()})
+	}
+
+	return data
+}
+
+func shuffleAccendentData() []AccendentItem {
+	collection := make([]AccendentItem, 0)
+	status := ""
+	for _, topic := range settings.AccendentTopics {
+		if getRandomIntBetweenValues(0, 1) == 1 {
+			status = accendentStatusActive
+		} else {
+			status = accendentStatusClosed
+		}
+
+		collection = append(collection, AccendentItem{Topic: topic, Status: status})
+	}
+
+	return collection
+}
+
+func getCountriesList() []string {
+	return []string{"RU", "US", "GB", "FR", "BL", "AT", "BG", "DK", "CA", "ES", "CH", "TR", "PE", "NZ", "MC"}
+}
+
+func getSmsProvidersByCountry(country string) []string {
+	return countryProviders(settings.Assignments.SMS, settings.Providers.SMS, country)
+}
+
+func getSmsProviderMap() map[string]string {
+	return map[string]string{
+		"RU": "Topolo",
+		"US": "Rond",
+		"GB": "Topolo",
+		"FR": "Topolo",
+		"BL": "Kildy",
+		"AT": "Topolo",
+		"BG": "Rond",
+		"DK": "Topolo",
+		"CA": "Rond",
+		"ES": "Topolo",
+		"CH": "Topolo",
+		"TR": "Rond",
+		"PE": "Topolo",
+		"NZ": "Kildy",
+		"MC": "Kildy",
+	}
+}
+
+func getMMSProvidersByCountry(country string) []string {
+	return countryProviders(settings.Assignments.MMS, settings.Providers.MMS, country)
+}
+
+func getMMSProviderMap() map[string]string {
+	return map[string]string{
+		"RU": "Topolo",
+		"US": "Rond",
+		"GB": "Topolo",
+		"FR": "Topolo",
+		"BL": "Kildy",
+		"AT": "Topolo",
+		"BG": "Rond",
+		"DK": "Topolo",
+		"CA": "Rond",
+		"ES": "Topolo",
+		"CH": "Topolo",
+		"TR": "Rond",
+		"PE": "Topolo",
+		"NZ": "Kildy",
+		"MC": "Kildy",
+	}
+}
+
+func getVoiceCallProvidersByCountry(country string) []string {
+	return countryProviders(settings.Assignments.Voice, settings.Providers.Voice, country)
+}
+
+func getVoiceCallProviderMap() map[string]string {
+	return map[string]string{
+		"RU": "TransparentCalls",
+		"US": "E-Voice",
+		"GB": "TransparentCalls",
+		"FR": "TransparentCalls",
+		"BL": "E-Voice",
+		"AT": "TransparentCalls",
+		"BG": "E-Voice",
+		"DK": "JustPhone",
+		"CA": "JustPhone",
+		"ES": "E-Voice",
+		"CH": "JustPhone",
+		"TR": "TransparentCalls",
+		"PE": "JustPhone",
+		"NZ": "JustPhone",
+		"MC": "E-Voice",
+	}
+}
+
+func getEmailProvidersList() []string {
+	return []string{
+		"Gmail",
+		"Yahoo",
+		"Hotmail",
+		"MSN",
+		"Orange",
+		"Comcast",
+		"AOL",
+		"Live",
+		"RediffMail",
+		"GMX",
+		"Protonmail",
+		"Yandex",
+		"Mail.ru",
+	}
+}
+
+func getSupportTopicsList() []string {
+	return []string{
+		"SMS",
+		"MMS",
+		"Email",
+		"Billing",
+		"Create account",
+		"API",
+		"Marketing",
+		"Privacy",
+		"GDPR",
+		"Other",
+	}
+}
+
+func getRandomSupportTickets() int {
+	return getRandomIntInRange(settings.Ranges.SupportTickets)
+}
+
+func getFilapathByFilename(filename string) string {
+	return filepath.Join(dataDir, filename)
+}
+
+func getRandomIntBetweenValues(min int, max int) int {
+	return rnd.Intn(max - min) + min
+}
+
+func getRandomIntInRange(r Range) int {
+	return getRandomIntBetweenValues(r.Min, r.Max)
+}
+
+func listenAndServeHTTP() error {
+	return http.ListenAndServe("127.0.0.1:8383", Handler())
+}
+
+// Handler returns the simulator API: the data endpoints and the control API.
+func Handler() http.Handler {
+	router := mux.NewRouter()
+
+	router.HandleFunc("/mms", withFaults("mms", handleMMS))
+	router.HandleFunc("/support", withFaults("support", handleSupport))
+	router.HandleFunc("/accendent", withFaults("accendent", handleAccendent))
+	router.HandleFunc("/data", handleData).Methods("GET")
+	router.HandleFunc("/data/reset", handleReset).Methods("POST")
+	router.HandleFunc("/data/{system}", handleSystemData).Methods("PUT", "PATCH")
+	router.HandleFunc("/faults", handleFaults).Methods("GET", "DELETE")
+	router.HandleFunc("/faults/{endpoint}", handleFault).Methods("GET", "PUT", "DELETE")
+	router.HandleFunc("/mail", handleMail).Methods("GET", "DELETE")
+	router.HandleFunc("/test", handleTest).Methods("GET", "OPTIONS")
+
+	return router
+}
+
+func handleMMS(w http.ResponseWriter, r *http.Request) {
+	dataMu.RLock()
+	defer dataMu.RUnlock()
+	response(w, r, MMSCollection)
+}
+
+func handleSupport(w http.ResponseWriter, r *http.Request) {
+	dataMu.RLock()
+	defer dataMu.RUnlock()
+	response(w, r, SupportCollection)
+}
+
+func handleAccendent(w http.ResponseWriter, r *http.Request) {
+	dataMu.RLock()
+	defer dataMu.RUnlock()
+	response(w, r, AccendentCollection)
+}
+
+func handleTest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Write([]byte("{\n  \"status\": true,\n  \"data\": {\n    \"sms\": [\n      [\n        {\n          \"country\": \"Canada\",\n          \"bandwidth\": \"12\",\n          \"response_time\": \"67\",\n          \"provider\": \"Rond\"\n        },\n        {\n          \"country\": \"Great Britain\",\n          \"bandwidth\": \"98\",\n          \"response_time\": \"593\",\n          \"provider\": \"Kildy\"\n        },\n        {\n          \"country\": \"Russian Federation\",\n          \"bandwidth\": \"77\",\n          \"response_time\": \"1734\",\n          \"provider\": \"Topolo\"\n        }\n      ],\n      [\n        {\n          \"country\": \"Great Britain\",\n          \"bandwidth\": \"98\",\n          \"response_time\": \"593\",\n          \"provider\": \"Kildy\"\n        },\n        {\n          \"country\": \"Canada\",\n          \"bandwidth\": \"12\",\n          \"response_time\": \"67\",\n          \"provider\": \"Rond\"\n        },\n        {\n          \"country\": \"Russian Federation\",\n          \"bandwidth\": \"77\",\n          \"response_time\": \"1734\",\n          \"provider\": \"Topolo\"\n        }\n      ]\n    ],\n    \"mms\": [\n      [\n        {\n          \"country\": \"Great Britain\",\n          \"bandwidth\": \"98\",\n          \"response_time\": \"593\",\n          \"provider\": \"Kildy\"\n        },\n        {\n          \"country\": \"Canada\",\n          \"bandwidth\": \"12\",\n          \"response_time\": \"67\",\n          \"provider\": \"Rond\"\n        },\n        {\n          \"country\": \"Russian Federation\",\n          \"bandwidth\": \"77\",\n          \"response_time\": \"1734\",\n          \"provider\": \"Topolo\"\n        }\n      ],\n      [\n        {\n          \"country\": \"Canada\",\n          \"bandwidth\": \"12\",\n          \"response_time\": \"67\",\n          \"provider\": \"Rond\"\n        },\n        {\n          \"country\": \"Great Britain\",\n          \"bandwidth\": \"98\",\n          \"response_time\": \"593\",\n          \"provider\": \"Kildy\"\n        },\n        {\n          \"country\": \"Russian Federation\",\n          \"bandwidth\": \"77\",\n          \"response_time\": \"1734\",\n          \"provider\": \"Topolo\"\n        }\n      ]\n    ],\n    \"voice_call\": [\n      {\n        \"country\": \"US\",\n        \"bandwidth\": \"53\",\n        \"response_time\": \"321\",\n        \"provider\": \"TransparentCalls\",\n        \"connection_stability\": 0.72,\n        \"ttfb\": 442,\n        \"voice_purity\": 20,\n        \"median_of_call_time\": 5\n      },\n      {\n        \"country\": \"US\",\n        \"bandwidth\": \"53\",\n        \"response_time\": \"321\",\n        \"provider\": \"TransparentCalls\",\n        \"connection_stability\": 0.72,\n        \"ttfb\": 442,\n        \"voice_purity\": 20,\n        \"median_of_call_time\": 5\n      },\n      {\n        \"country\": \"US\",\n        \"bandwidth\": \"53\",\n        \"response_time\": \"321\",\n        \"provider\": \"E-Voice\",\n        \"connection_stability\": 0.72,\n        \"ttfb\": 442,\n        \"voice_purity\": 20,\n        \"median_of_call_time\": 5\n      },\n      {\n        \"country\": \"US\",\n        \"bandwidth\": \"53\",\n        \"response_time\": \"321\",\n        \"provider\": \"E-Voice\",\n        \"connection_stability\": 0.72,\n        \"ttfb\": 442,\n        \"voice_purity\": 20,\n        \"median_of_call_time\": 5\n      }\n    ],\n    \"email\": [\n      [\n        {\n          \"country\": \"RU\",\n          \"provider\": \"Gmail\",\n          \"delivery_time\": 195\n        },\n        {\n          \"country\": \"RU\",\n          \"provider\": \"Gmail\",\n          \"delivery_time\": 393\n        },\n        {\n          \"country\": \"RU\",\n          \"provider\": \"Gmail\",\n          \"delivery_time\": 393\n        }\n      ],\n      [\n        {\n          \"country\": \"RU\",\n          \"provider\": \"Gmail\",\n          \"delivery_time\": 393\n        },\n        {\n          \"country\": \"RU\",\n          \"provider\": \"Gmail\",\n          \"delivery_time\": 393\n        },\n        {\n          \"country\": \"RU\",\n          \"provider\": \"Gmail\",\n          \"delivery_time\": 393\n        }\n      ]\n    ],\n    \"billing\": {\n      \"create_customer\": true,\n      \"purchase\": true,\n      \"payout\": true,\n      \"recurring\": false,\n      \"fraud_control\": true,\n      \"checkout_page\": false\n    },\n    \"support\": [\n      3,\n      62\n    ],\n    \"incident\": [\n      {\"topic\":  \"Topic 1\", \"status\": \"active\"},\n      {\"topic\":  \"Topic 2\", \"status\": \"active\"},\n      {\"topic\":  \"Topic 3\", \"status\": \"closed\"},\n      {\"topic\":  \"Topic 4\", \"status\": \"closed\"}\n    ]\n  },\n  \"error\": \"\"\n}"))
+}
+
+func response(w http.ResponseWriter, r *http.Request, responseStruct interface{}) {
+	response, _ := json.Marshal(responseStruct)
+
+	w.Write(response)
+}
\ No newline at end of file
@@ -0,0 +1,185 @@
+package simulator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A stand-in for the mail server of the status service: accepts any message over plain SMTP
+// (no TLS, no authentication) and keeps it in memory. GET /mail lists the messages, DELETE /mail clears them.
+
+const maxMailSize = 1 << 20
+
+// MailMessage is a message received by the SMTP stand-in.
+type MailMessage struct {
+	From       string            `json:"from"`
+	To         []string          `json:"to"`
+	Subject    string            `json:"subject"`
+	Headers    map[string]string `json:"headers"`
+	Body       string            `json:"body"`
+	ReceivedAt time.Time         `json:"received_at"`
+}
+
+var (
+	mailMu  sync.Mutex
+	mailbox []MailMessage
+)
+
+// Mailbox returns the messages received so far.
+func Mailbox() []MailMessage {
+	mailMu.Lock()
+	defer mailMu.Unlock()
+	return append(make([]MailMessage, 0, len(mailbox)), mailbox...)
+}
+
+// ClearMailbox drops the received messages.
+func ClearMailbox() {
+	mailMu.Lock()
+	mailbox = nil
+	mailMu.Unlock()
+}
+
+// ListenSMTP starts the SMTP stand-in on addr ("127.0.0.1:0" - any free port) and returns its listener;
+// closing the listener stops it.
+func ListenSMTP(addr string) (net.Listener, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn)
+		}
+	}()
+	return l, nil
+}
+
+func serveSMTP(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(format string, args ...interface{}) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+	reply("220 simulator SMTP ready")
+	var from string
+	var to []string
+	for {
+		conn.SetReadDeadline(time.Now().Add(time.Minute))
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(verb, "EHLO"), strings.HasPrefix(verb, "HELO"):
+			reply("250 simulator")
+		case strings.HasPrefix(verb, "MAIL FROM:"):
+			from, to = address(line[len("MAIL FROM:"):]), nil
+			reply("250 OK")
+		case strings.HasPrefix(verb, "RCPT TO:"):
+			to = append(to, address(line[len("RCPT TO:"):]))
+			reply("250 OK")
+		case verb == "DATA":
+			if from == "" || len(to) == 0 {
+				reply("503 MAIL FROM and RCPT TO first")
+				continue
+			}
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			data, err := readData(r)
+			if err != nil {
+				reply("552 %v", err)
+				return
+			}
+			msg, err := parseMail(from, to, data)
+			if err != nil {
+				reply("554 %v", err)
+				continue
+			}
+			mailMu.Lock()
+			mailbox = append(mailbox, msg)
+			mailMu.Unlock()
+			from, to = "", nil
+			reply("250 OK: queued")
+		case verb == "RSET":
+			from, to = "", nil
+			reply("250 OK")
+		case verb == "NOOP":
+			reply("250 OK")
+		case verb == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func address(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, ' '); i >= 0 { // parameters like SIZE= or BODY=8BITMIME
+		s = s[:i]
+	}
+	return strings.Trim(s, "<>")
+}
+
+// readData reads the message up to the line with a single dot and removes the dot-stuffing.
+func readData(r *bufio.Reader) (string, error) {
+	var b strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		if line == ".\r\n" || line == ".\n" {
+			return b.String(), nil
+		}
+		if b.Len()+len(line) > maxMailSize {
+			return "", fmt.Errorf("message is larger than %d bytes", maxMailSize)
+		}
+		b.WriteString(strings.TrimPrefix(line, "."))
+	}
+}
+
+func parseMail(from string, to []string, data string) (MailMessage, error) {
+	m, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		return MailMessage{}, err
+	}
+	body, err := io.ReadAll(m.Body)
+	if err != nil {
+		return MailMessage{}, err
+	}
+	msg := MailMessage{From: from, To: to, Headers: make(map[string]string), Body: strings.ReplaceAll(string(body), "\r\n", "\n"), ReceivedAt: time.Now()}
+	dec := new(mime.WordDecoder) // encoded words, e.g. a non-ASCII subject
+	for name := range m.Header {
+		value, err := dec.DecodeHeader(m.Header.Get(name))
+		if err != nil {
+			value = m.Header.Get(name)
+		}
+		msg.Headers[name] = value
+	}
+	msg.Subject = msg.Headers["Subject"]
+	return msg, nil
+}
+
+func handleMail(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodDelete {
+		ClearMailbox()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	response(w, r, Mailbox())
+}
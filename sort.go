@@ -0,0 +1,126 @@
+package main
+
+// Порядок списков в ответе /systemsstatus. SMS и MMS отдаются двумя списками: по провайдеру, затем по стране,
+// и по стране, затем по провайдеру. Параметр sort= заменяет вторичные ключи этих списков и задает порядок voice_call и incident:
+// /systemsstatus?sort=sms:-response_time,bandwidth&sort=voice_call:-quality_score,country
+
+import (
+	"finalwork/internal/incident"
+	"finalwork/internal/sorting"
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/collate"
+)
+
+func rowKeys[T any](coll *collate.Collator, country, provider, bandwidth, responseTime func(T) string) []sorting.Key[T] { // ключи строк SMS и MMS
+	return []sorting.Key[T]{
+		{Name: "country", Compare: sorting.Collated(coll, country)}, // названия стран - по правилам языка клиента
+		{Name: "provider", Compare: sorting.Strings(provider)},
+		{Name: "bandwidth", Compare: sorting.NumericStrings(bandwidth)},
+		{Name: "response_time", Compare: sorting.NumericStrings(responseTime)},
+	}
+}
+
+func smsKeys(coll *collate.Collator) []sorting.Key[SMSData] {
+	return rowKeys(coll,
+		func(v SMSData) string { return v.Country }, func(v SMSData) string { return v.Provider },
+		func(v SMSData) string { return v.Bandwidth }, func(v SMSData) string { return v.ResponseTime })
+}
+
+func mmsKeys(coll *collate.Collator) []sorting.Key[MMSData] {
+	return rowKeys(coll,
+		func(v MMSData) string { return v.Country }, func(v MMSData) string { return v.Provider },
+		func(v MMSData) string { return v.Bandwidth }, func(v MMSData) string { return v.ResponseTime })
+}
+
+var voiceKeys = []sorting.Key[VoiceCallData]{
+	{Name: "country", Compare: sorting.Strings(func(v VoiceCallData) string { return v.Country })}, // в Voice Call страна - код alpha-2
+	{Name: "provider", Compare: sorting.Strings(func(v VoiceCallData) string { return v.Provider })},
+	{Name: "current_load", Compare: sorting.Ints(func(v VoiceCallData) int { return v.CurrentLoad })},
+	{Name: "response_time", Compare: sorting.Ints(func(v VoiceCallData) int { return v.ResponseTime })},
+	{Name: "connection_stability", Compare: sorting.Floats(func(v VoiceCallData) float64 { return float64(v.ConnectionStability) })},
+	{Name: "purity_ttfb", Compare: sorting.Ints(func(v VoiceCallData) int { return v.PurityTTFB })},
+	{Name: "call_duration", Compare: sorting.Ints(func(v VoiceCallData) int { return v.CallDuration })},
+	{Name: "quality_score", Compare: sorting.Floats(func(v VoiceCallData) float64 { return v.QualityScore })},
+}
+
+var emailKeys = []sorting.Key[EmailData]{
+	{Name: "delivery_time", Compare: sorting.Ints(func(v EmailData) int { return v.DeliveryTime })},
+	{Name: "provider", Compare: sorting.Strings(func(v EmailData) string { return v.Provider })},
+}
+
+var incidentKeys = []sorting.Key[IncidentData]{
+	{Name: "status", Compare: sorting.Strings(func(v IncidentData) string { return v.Status })}, // "active" раньше "closed"
+	{Name: "topic", Compare: sorting.Strings(func(v IncidentData) string { return v.Topic })},
+	{Name: "severity", Compare: sorting.Ints(func(v IncidentData) int { return incident.SeverityRank(v.Severity) })}, // от minor к critical
+	{Name: "system", Compare: sorting.Strings(func(v IncidentData) string { return v.System })},
+}
+
+// byProviderAndCountry возвращает два списка: по провайдеру и по стране. Следующие ключи - then, по умолчанию страна и провайдер соответственно
+func byProviderAndCountry[T any](data []T, keys []sorting.Key[T], then []sorting.Key[T]) [][]T {
+	provider, _ := sorting.Find(keys, "provider")
+	country, _ := sorting.Find(keys, "country")
+	byProvider, byCountry := []sorting.Key[T]{provider, country}, []sorting.Key[T]{country, provider}
+	if len(then) > 0 {
+		byProvider = append([]sorting.Key[T]{provider}, then...)
+		byCountry = append([]sorting.Key[T]{country}, then...)
+	}
+	return [][]T{sorting.Sorted(data, byProvider...), sorting.Sorted(data, byCountry...)}
+}
+
+type sortOptions struct { // порядок, запрошенный клиентом параметрами sort=
+	SMS       []sorting.Key[SMSData]
+	MMS       []sorting.Key[MMSData]
+	VoiceCall []sorting.Key[VoiceCallData]
+	Incidents []sorting.Key[IncidentData]
+}
+
+func (o sortOptions) empty() bool {
+	return o.SMS == nil && o.MMS == nil && o.VoiceCall == nil && o.Incidents == nil
+}
+
+// parseSort разбирает значения параметров sort= вида "раздел:ключ,-ключ"
+func parseSort(values []string, coll *collate.Collator) (sortOptions, error) {
+	var o sortOptions
+	for _, v := range values {
+		section, spec, ok := strings.Cut(v, ":")
+		if !ok || spec == "" {
+			return o, fmt.Errorf("sort: expected \"section:key,-key\", got %q", v)
+		}
+		var err error
+		switch section {
+		case "sms":
+			o.SMS, err = sorting.Parse(spec, smsKeys(coll))
+		case "mms":
+			o.MMS, err = sorting.Parse(spec, mmsKeys(coll))
+		case "voice_call":
+			o.VoiceCall, err = sorting.Parse(spec, voiceKeys)
+		case "incident":
+			o.Incidents, err = sorting.Parse(spec, incidentKeys)
+		default:
+			return o, fmt.Errorf("sort: unknown section %q (allowed: sms, mms, voice_call, incident)", section)
+		}
+		if err != nil {
+			return o, fmt.Errorf("sort: %s: %w", section, err)
+		}
+	}
+	return o, nil
+}
+
+// sortResult возвращает данные в порядке, запрошенном клиентом. Собранные данные общие для всех запросов, поэтому сортируются копии
+func sortResult(d ResultSetT, o sortOptions, coll *collate.Collator) ResultSetT {
+	if o.SMS != nil && len(d.SMS) > 0 {
+		d.SMS = byProviderAndCountry(d.SMS[0], smsKeys(coll), o.SMS)
+	}
+	if o.MMS != nil && len(d.MMS) > 0 {
+		d.MMS = byProviderAndCountry(d.MMS[0], mmsKeys(coll), o.MMS)
+	}
+	if o.VoiceCall != nil {
+		d.VoiceCall = sorting.Sorted(d.VoiceCall, o.VoiceCall...)
+	}
+	if o.Incidents != nil {
+		d.Incidents = sorting.Sorted(d.Incidents, append([]sorting.Key[IncidentData]{incidentKeys[0]}, o.Incidents...)...) // активные инциденты остаются первыми
+	}
+	return d
+}
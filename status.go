@@ -0,0 +1,81 @@
+package main
+
+// Переменные собранных данных для правил общего состояния (раздел status_rules настроек).
+// У каждой системы есть <система>.available, <система>.incidents (активные инциденты) и <система>.incident_severity
+// (наибольшая серьезность активного инцидента: 0 - нет, minor, major, critical - константы 1, 2, 3).
+
+import (
+	"finalwork/internal/incident"
+	"finalwork/internal/rules"
+)
+
+func statusVars(d ResultSetT) rules.Vars {
+	v := rules.Vars{
+		"minor":    float64(incident.SeverityRank(incident.SeverityMinor)),
+		"major":    float64(incident.SeverityRank(incident.SeverityMajor)),
+		"critical": float64(incident.SeverityRank(incident.SeverityCritical)),
+	}
+	var smsRows, mmsRows int
+	if len(d.SMS) > 0 {
+		smsRows = len(d.SMS[0])
+	}
+	if len(d.MMS) > 0 {
+		mmsRows = len(d.MMS[0])
+	}
+	v["sms.rows"] = float64(smsRows)
+	v["sms.available"] = smsRows > 0
+	v["mms.rows"] = float64(mmsRows)
+	v["mms.available"] = mmsRows > 0
+	v["voice_call.rows"] = float64(len(d.VoiceCall))
+	v["voice_call.available"] = len(d.VoiceCall) > 0
+	v["voice_call.low_quality"] = float64(d.VoiceQuality.LowQuality)
+	v["voice_call.low_quality_share"] = 0.0 // доля строк ниже порога качества (0..1)
+	if len(d.VoiceCall) > 0 {
+		v["voice_call.low_quality_share"] = float64(d.VoiceQuality.LowQuality) / float64(len(d.VoiceCall))
+	}
+	v["email.countries"] = float64(len(d.Email))
+	v["email.available"] = len(d.Email) > 0
+
+	b := d.Billing
+	flags := map[string]bool{
+		"create_customer": b.CreateCustomer, "purchase": b.Purchase, "payout": b.Payout,
+		"recurring": b.Recurring, "fraud_control": b.FraudControl, "checkout_page": b.CheckoutPage,
+	}
+	failed := 0
+	for name, ok := range flags {
+		v["billing."+name] = ok
+		if !ok {
+			failed++
+		}
+	}
+	v["billing.failed"] = float64(failed) // количество выключенных флагов
+	v["billing.available"] = true         // флаги читаются из файла: при ошибке чтения сбор данных не состоится
+
+	v["support.available"] = len(d.Support) == 2
+	v["support.load"], v["support.wait"] = 0.0, 0.0
+	if len(d.Support) == 2 {
+		v["support.load"] = float64(d.Support[0]) // загрузка 1..3
+		v["support.wait"] = float64(d.Support[1]) // время ожидания ответа, минуты
+	}
+
+	active := 0
+	for _, s := range incident.Systems {
+		v[s+".incidents"] = 0.0
+		v[s+".incident_severity"] = 0.0
+	}
+	for _, inc := range d.Incidents {
+		if inc.Status != "active" {
+			continue
+		}
+		active++
+		if _, ok := v[inc.System+".incidents"]; !ok {
+			continue // система не определена
+		}
+		v[inc.System+".incidents"] = v[inc.System+".incidents"].(float64) + 1
+		if rank := float64(incident.SeverityRank(inc.Severity)); rank > v[inc.System+".incident_severity"].(float64) {
+			v[inc.System+".incident_severity"] = rank
+		}
+	}
+	v["incidents.active"] = float64(active)
+	return v
+}